@@ -0,0 +1,120 @@
+package faulttest
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Atom holds a value that is replaced, never modified: the pattern of
+// copying state under RLock, computing the next one, and assigning it under
+// Lock, without the locks. T should be immutable, like *State or any
+// ImmutableStore; readers then share snapshots with no copying and no lock.
+//
+// Update retries its function until it applies to the latest value, so
+// concurrent updates are never lost, and Watch delivers every value the
+// Atom takes, in order.
+//
+// The zero Atom holds the zero value of T.
+type Atom[T any] struct {
+	current atomic.Pointer[snapshot[T]]
+}
+
+// snapshot is one value of an Atom. Snapshots form a list, newest last,
+// which watchers follow: the write replacing a snapshot links it to its
+// successor and closes replaced. Nothing else is ever written.
+type snapshot[T any] struct {
+	value    T
+	next     atomic.Pointer[snapshot[T]]
+	replaced chan struct{}
+}
+
+func newSnapshot[T any](value T) *snapshot[T] {
+	return &snapshot[T]{value: value, replaced: make(chan struct{})}
+}
+
+// NewAtom creates a new Atom holding value.
+func NewAtom[T any](value T) *Atom[T] {
+	a := &Atom[T]{}
+	a.current.Store(newSnapshot(value))
+	return a
+}
+
+func (a *Atom[T]) load() *snapshot[T] {
+	for {
+		if s := a.current.Load(); s != nil {
+			return s
+		}
+		var zero T
+		a.current.CompareAndSwap(nil, newSnapshot(zero))
+	}
+}
+
+// replace publishes next if current is still the Atom's snapshot.
+func (a *Atom[T]) replace(current *snapshot[T], value T) bool {
+	next := newSnapshot(value)
+	if !a.current.CompareAndSwap(current, next) {
+		return false
+	}
+	// Only the write that replaced current gets here, once
+	current.next.Store(next)
+	close(current.replaced)
+	return true
+}
+
+// Load returns the current value.
+func (a *Atom[T]) Load() T {
+	return a.load().value
+}
+
+// Swap replaces the value, returning the old one.
+func (a *Atom[T]) Swap(value T) (old T) {
+	for {
+		current := a.load()
+		if a.replace(current, value) {
+			return current.value
+		}
+	}
+}
+
+// Update replaces the value with fn of it, and returns the new value. If
+// another update lands while fn runs, fn is called again on the newer
+// value, so no update is lost; fn must therefore have no side effects and
+// must not modify its argument.
+//
+// A panic in fn propagates, with the value unchanged: a crash leaves no
+// partial update behind.
+func (a *Atom[T]) Update(fn func(T) T) T {
+	for {
+		current := a.load()
+		value := fn(current.value)
+		if a.replace(current, value) {
+			return value
+		}
+	}
+}
+
+// Watch returns a channel receiving every value the Atom takes after the
+// call, in order, until ctx is done; the channel is then closed. Writers
+// never wait for watchers: a watcher falling behind keeps the values it has
+// not received yet in memory, so receive until ctx is done.
+func (a *Atom[T]) Watch(ctx context.Context) <-chan T {
+	ch := make(chan T)
+	s := a.load()
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-s.replaced:
+			case <-ctx.Done():
+				return
+			}
+			s = s.next.Load()
+			select {
+			case ch <- s.value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,134 @@
+package faulttest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAtom checks that concurrent updates of an Atom are never lost, and
+// that a crash in one leaves nothing behind.
+func TestAtom(t *testing.T) {
+	printSection("ATOM - Shared immutable state without lock choreography")
+
+	printInfo("What we're testing: Load, Swap and Update from many goroutines at once")
+	printInfo("How: Update retries on the latest value until its compare-and-swap succeeds")
+	fmt.Println()
+
+	t.Run("NoLostUpdates", func(t *testing.T) {
+		printStep("Test 1", "20 goroutines updating the same State 50 times each")
+
+		state := NewAtom(NewState(nil))
+		counter := NewAtom(0)
+		var wg sync.WaitGroup
+		for g := range 20 {
+			wg.Go(func() {
+				for i := range 50 {
+					state.Update(func(s *State) *State {
+						return s.Set(fmt.Sprintf("g%d-%d", g, i), "value")
+					})
+					counter.Update(func(n int) int { return n + 1 })
+				}
+			})
+		}
+		wg.Wait()
+
+		if n := state.Load().Len(); n != 1000 {
+			t.Errorf("State holds %d keys, want 1000: updates were lost", n)
+		}
+		if n := counter.Load(); n != 1000 {
+			t.Errorf("counter = %d, want 1000: updates were lost", n)
+		}
+		printSuccess("All 1000 updates landed, without a lock")
+	})
+
+	t.Run("Swap", func(t *testing.T) {
+		var a Atom[*State]
+		if s := a.Load(); s != nil {
+			t.Errorf("zero Atom holds %v, want nil", s)
+		}
+		first := NewState(map[string]string{"v": "1"})
+		if old := a.Swap(first); old != nil {
+			t.Errorf("Swap() = %v, want nil", old)
+		}
+		if old := a.Swap(NewState(nil)); old != first {
+			t.Errorf("Swap() = %v, want %v", old, first)
+		}
+	})
+
+	t.Run("PanicInUpdate", func(t *testing.T) {
+		printStep("Test 2", "The MutateAndPanic crash, inside Update")
+
+		target := Target[*Atom[*State]]{
+			New: func() *Atom[*State] {
+				return NewAtom(NewState(map[string]string{"base": "value"}))
+			},
+			Op: func(a *Atom[*State], faults *Faults) {
+				a.Update(func(s *State) *State {
+					faults.Write(func() { s = s.Set("key", "value_PARTIAL") })
+					faults.Write(func() { s = s.Set("key", "value") })
+					return s
+				})
+			},
+			Observe: func(a *Atom[*State]) any {
+				return a.Load().String()
+			},
+			Calls: 2,
+		}
+		report := Inject(target, BeforeWrite(1), AfterWrite(1), BeforeWrite(2), AfterWrite(2), OnCall(2))
+		printInfo(report.String())
+		if unsafe := report.Unsafe(); len(unsafe) > 0 {
+			t.Errorf("Atom corrupted: %s", report.Summary())
+		}
+		printSuccess(report.Summary())
+	})
+}
+
+// TestAtomWatch checks that a watcher receives every value, in order, even
+// when the writes race.
+func TestAtomWatch(t *testing.T) {
+	printStep("Watch", "A subscriber sees every snapshot of 1000 racing updates")
+
+	a := NewAtom(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watches := []<-chan int{a.Watch(ctx), a.Watch(ctx)}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			for range 100 {
+				a.Update(func(n int) int { return n + 1 })
+			}
+		})
+	}
+
+	for i, ch := range watches {
+		for want := 1; want <= 1000; want++ {
+			select {
+			case got := <-ch:
+				if got != want {
+					t.Fatalf("watcher %d received %d, want %d", i, got, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("watcher %d stalled waiting for %d", i, want)
+			}
+		}
+	}
+	wg.Wait()
+
+	cancel()
+	for i, ch := range watches {
+		select {
+		case v, ok := <-ch:
+			if ok {
+				t.Errorf("watcher %d received %d after cancel", i, v)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("watcher %d not closed after cancel", i)
+		}
+	}
+	printSuccess("Both watchers received 1 to 1000 in order, then closed")
+}
@@ -0,0 +1,289 @@
+package faulttest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Chaos is a state implementation to hammer from many goroutines at once
+// with random reads, writes, merges and injected panics. Where Inject
+// crashes one operation at chosen points, Chaos crashes many at random,
+// concurrently, and checks the invariants a reader relies on:
+//
+//   - no partial values: a read never sees part of a merge applied
+//   - no lost updates: a goroutine always reads back its last write
+//   - no deadlock: the run ends within Timeout, panics and all
+//
+// Each goroutine owns its keys, so every value read has a known writer and
+// a stale one is a lost update, not a race the harness lost.
+type Chaos[S any] struct {
+	// New returns the shared state, empty.
+	New func() S
+
+	// Read returns a copy of what a reader sees of the state, consistent:
+	// taken under the lock, or from one immutable snapshot.
+	Read func(state S) map[string]string
+
+	// Write sets key to value, doing each write to shared state through
+	// faults.Write. An error or a panic fails the call.
+	Write func(state S, key, value string, faults *Faults) error
+
+	// Merge sets every key of data, all or nothing, doing each write to
+	// shared state through faults.Write. An error or a panic fails the
+	// call.
+	Merge func(state S, data map[string]string, faults *Faults) error
+
+	// Goroutines is the number of goroutines, 8 if zero.
+	Goroutines int
+
+	// Ops is the number of operations per goroutine, 1000 if zero.
+	Ops int
+
+	// PanicRate is the probability of an injected panic before each write;
+	// zero injects none.
+	PanicRate float64
+
+	// Seed seeds the operations and faults of each goroutine; zero picks
+	// one at random. Rerunning with the reported seed replays them, though
+	// not their interleaving.
+	Seed uint64
+
+	// Timeout bounds the whole run; past it, the state deadlocked. 10s if
+	// zero.
+	Timeout time.Duration
+}
+
+// mergeWidth is the number of keys each merge sets.
+const mergeWidth = 4
+
+// ChaosReport is the outcome of a RunChaos run.
+type ChaosReport struct {
+	Seed       uint64
+	Goroutines int
+	Ops        int // operations run, failed ones included
+	Failures   int // calls that panicked or returned an error
+	Partial    int // reads that saw part of a merge
+	Lost       int // reads missing the reader's own last write
+	Deadlocked bool
+
+	// Violations describes the first few distinct partial values and lost
+	// updates.
+	Violations []string
+}
+
+// maxViolations is the number of violations a report describes.
+const maxViolations = 10
+
+// Failed reports whether any invariant was broken.
+func (r ChaosReport) Failed() bool {
+	return r.Partial > 0 || r.Lost > 0 || r.Deadlocked
+}
+
+// Summary sums up the run, with the seed to replay it:
+//
+//	8 goroutines, 8000 ops, 64 failed: 12 partial values, 0 lost updates (seed 42)
+func (r ChaosReport) Summary() string {
+	verdict := fmt.Sprintf("%d partial values, %d lost updates", r.Partial, r.Lost)
+	if r.Deadlocked {
+		verdict = "deadlocked, " + verdict
+	}
+	return fmt.Sprintf("%d goroutines, %d ops, %d failed: %s (seed %d)",
+		r.Goroutines, r.Ops, r.Failures, verdict, r.Seed)
+}
+
+func (r ChaosReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Summary())
+	for _, v := range r.Violations {
+		sb.WriteString("\n  ")
+		sb.WriteString(v)
+	}
+	return sb.String()
+}
+
+// RunChaos runs chaos and reports the invariants broken. A deadlocked run
+// leaves its goroutines behind.
+func RunChaos[S any](chaos Chaos[S]) ChaosReport {
+	r := &chaosRun[S]{Chaos: chaos}
+	if r.Goroutines == 0 {
+		r.Goroutines = 8
+	}
+	if r.Ops == 0 {
+		r.Ops = 1000
+	}
+	if r.Timeout == 0 {
+		r.Timeout = 10 * time.Second
+	}
+	if r.Seed == 0 {
+		r.Seed = rand.Uint64()
+	}
+	r.report = ChaosReport{Seed: r.Seed, Goroutines: r.Goroutines}
+	r.state = r.New()
+	r.owned = make([]owned, r.Goroutines)
+
+	deadline := time.Now().Add(r.Timeout)
+	var wg sync.WaitGroup
+	for g := range r.Goroutines {
+		wg.Go(func() { r.hammer(g) })
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		// Every goroutine is done: check the last writes of all
+		read := func(state S) any { return r.Read(state) }
+		if data, ok := observe(read, r.state, time.Until(deadline)); ok {
+			r.check(data.(map[string]string), -1)
+		} else {
+			r.deadlocked()
+		}
+	case <-time.After(time.Until(deadline)):
+		r.deadlocked()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.Ops = int(r.ops.Load())
+	report.Failures = int(r.failures.Load())
+	report.Violations = slices.Clone(report.Violations)
+	return report
+}
+
+// chaosRun is the state of a RunChaos run.
+type chaosRun[S any] struct {
+	Chaos[S]
+	state    S
+	owned    []owned // by goroutine, each written by its own only
+	ops      atomic.Int64
+	failures atomic.Int64
+
+	mu     sync.Mutex // guards report
+	report ChaosReport
+}
+
+// owned holds the values the keys of a goroutine may hold: the value of
+// its last successful call, and those of the calls failed since, which
+// may or may not have landed. "" stands for no value.
+type owned struct {
+	write, merge []string
+}
+
+// settle updates the values keys may hold after a call writing value.
+func settle(values []string, value string, ok bool) []string {
+	if ok {
+		return []string{value}
+	}
+	return append(values, value)
+}
+
+func writeKey(g int) string {
+	return fmt.Sprintf("g%d/w", g)
+}
+
+func mergeKey(g, i int) string {
+	return fmt.Sprintf("g%d/m%d", g, i)
+}
+
+// hammer runs the operations of goroutine g.
+func (r *chaosRun[S]) hammer(g int) {
+	rng := rand.New(rand.NewPCG(r.Seed, uint64(2*g)))
+	faults := &Faults{point: Randomly(r.PanicRate), rng: rand.New(rand.NewPCG(r.Seed, uint64(2*g+1)))}
+	own := &r.owned[g]
+	own.write, own.merge = []string{""}, []string{""}
+
+	for op := range r.Ops {
+		r.ops.Add(1)
+		value := fmt.Sprintf("g%d-%d", g, op)
+		switch rng.IntN(4) {
+		case 0, 1:
+			var data map[string]string
+			if r.call(func() error { data = r.Read(r.state); return nil }) {
+				r.check(data, g)
+			}
+		case 2:
+			ok := r.call(func() error {
+				faults.rearm()
+				return r.Write(r.state, writeKey(g), value, faults)
+			})
+			own.write = settle(own.write, value, ok)
+		case 3:
+			data := make(map[string]string, mergeWidth)
+			for i := range mergeWidth {
+				data[mergeKey(g, i)] = value
+			}
+			ok := r.call(func() error {
+				faults.rearm()
+				return r.Merge(r.state, data, faults)
+			})
+			own.merge = settle(own.merge, value, ok)
+		}
+	}
+}
+
+// rearm lets the injection point fire again: each call of a chaos run is
+// an experiment of its own.
+func (f *Faults) rearm() {
+	f.mu.Lock()
+	f.fired = false
+	f.mu.Unlock()
+	f.start()
+}
+
+// call runs fn, reporting whether it succeeded.
+func (r *chaosRun[S]) call(fn func() error) bool {
+	var err error
+	ok, _ := IsolatedOperation(func() { err = fn() })
+	if !ok || err != nil {
+		r.failures.Add(1)
+	}
+	return ok && err == nil
+}
+
+// check checks a read of goroutine g for partial merges, and for lost
+// updates of g's own keys, or of every goroutine's if g is -1.
+func (r *chaosRun[S]) check(data map[string]string, g int) {
+	for h := range r.Goroutines {
+		merged := make([]string, mergeWidth)
+		for i := range merged {
+			merged[i] = data[mergeKey(h, i)]
+		}
+		if len(slices.Compact(slices.Clone(merged))) > 1 {
+			r.violation(&r.report.Partial, fmt.Sprintf("partial: g%d/m0..m%d = %v", h, mergeWidth-1, merged))
+			continue
+		}
+		if g != -1 && g != h {
+			continue
+		}
+		own := &r.owned[h]
+		if v := data[writeKey(h)]; !slices.Contains(own.write, v) {
+			r.violation(&r.report.Lost, fmt.Sprintf("lost: %s = %q, want one of %q", writeKey(h), v, own.write))
+		}
+		if v := merged[0]; !slices.Contains(own.merge, v) {
+			r.violation(&r.report.Lost, fmt.Sprintf("lost: %s = %q, want one of %q", mergeKey(h, 0), v, own.merge))
+		}
+	}
+}
+
+func (r *chaosRun[S]) violation(count *int, description string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*count++
+	if len(r.report.Violations) < maxViolations && !slices.Contains(r.report.Violations, description) {
+		r.report.Violations = append(r.report.Violations, description)
+	}
+}
+
+func (r *chaosRun[S]) deadlocked() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Deadlocked = true
+}
@@ -0,0 +1,180 @@
+package faulttest
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var chaosSeed = flag.Uint64("chaos.seed", 0, "seed of the chaos runs, random if 0")
+
+// lockedChaos writes CriticalState in place under its lock, releasing it
+// with or without defer.
+func lockedChaos(deferUnlock bool) Chaos[*CriticalState] {
+	write := func(state *CriticalState, data map[string]string, faults *Faults) error {
+		state.Lock.Lock()
+		if deferUnlock {
+			defer state.Lock.Unlock()
+		}
+		for k, v := range data {
+			faults.Write(func() { state.Config[k] = v })
+		}
+		if !deferUnlock {
+			state.Lock.Unlock()
+		}
+		return nil
+	}
+	return Chaos[*CriticalState]{
+		New: NewCriticalState,
+		Read: func(state *CriticalState) map[string]string {
+			state.Lock.Lock()
+			defer state.Lock.Unlock()
+			return maps.Clone(state.Config)
+		},
+		Write: func(state *CriticalState, key, value string, faults *Faults) error {
+			return write(state, map[string]string{key: value}, faults)
+		},
+		Merge: write,
+	}
+}
+
+// transactChaos updates CriticalState with Transact.
+func transactChaos() Chaos[*CriticalState] {
+	c := lockedChaos(true)
+	c.Merge = func(state *CriticalState, data map[string]string, faults *Faults) error {
+		return Transact(state, func(draft map[string]string) error {
+			for k, v := range data {
+				faults.Write(func() { draft[k] = v })
+			}
+			return nil
+		})
+	}
+	c.Write = func(state *CriticalState, key, value string, faults *Faults) error {
+		return c.Merge(state, map[string]string{key: value}, faults)
+	}
+	return c
+}
+
+// pointerChaos publishes immutable States with a plain Store, without
+// checking that the State it started from is still current.
+func pointerChaos() Chaos[*atomic.Pointer[State]] {
+	write := func(p *atomic.Pointer[State], data map[string]string, faults *Faults) error {
+		next := p.Load()
+		runtime.Gosched() // as a longer computation would
+		for k, v := range data {
+			next = next.Set(k, v)
+		}
+		faults.Write(func() { p.Store(next) })
+		return nil
+	}
+	return Chaos[*atomic.Pointer[State]]{
+		New: func() *atomic.Pointer[State] {
+			var p atomic.Pointer[State]
+			p.Store(NewState(nil))
+			return &p
+		},
+		Read: func(p *atomic.Pointer[State]) map[string]string {
+			return maps.Collect(p.Load().All())
+		},
+		Write: func(p *atomic.Pointer[State], key, value string, faults *Faults) error {
+			return write(p, map[string]string{key: value}, faults)
+		},
+		Merge: write,
+	}
+}
+
+// atomChaos updates an Atom holding store.
+func atomChaos(store ImmutableStore) Chaos[*Atom[ImmutableStore]] {
+	merge := func(a *Atom[ImmutableStore], data map[string]string, faults *Faults) error {
+		a.Update(func(s ImmutableStore) ImmutableStore {
+			for k, v := range data {
+				faults.Write(func() { s = s.Set(k, v) })
+			}
+			return s
+		})
+		return nil
+	}
+	return Chaos[*Atom[ImmutableStore]]{
+		New: func() *Atom[ImmutableStore] { return NewAtom(store) },
+		Read: func(a *Atom[ImmutableStore]) map[string]string {
+			return maps.Collect(a.Load().All())
+		},
+		Write: func(a *Atom[ImmutableStore], key, value string, faults *Faults) error {
+			return merge(a, map[string]string{key: value}, faults)
+		},
+		Merge: merge,
+	}
+}
+
+// TestChaos hammers each way of sharing state with 8 goroutines and 5%
+// of writes panicking, and checks which invariants break.
+func TestChaos(t *testing.T) {
+	printSection("CHAOS - Many goroutines, random operations, random crashes")
+
+	printInfo("What we're testing: reads, writes and merges racing while 5% of writes panic")
+	printInfo("Why it matters: hand-written scenarios only cover the interleavings we thought of")
+	printInfo("Replay a run with: go test -run TestChaos -chaos.seed=<seed>")
+	fmt.Println()
+
+	tests := []struct {
+		name                    string
+		run                     func(seed uint64) ChaosReport
+		partial, lost, deadlock bool
+	}{
+		{"LockWithoutDefer", func(seed uint64) ChaosReport {
+			c := lockedChaos(false)
+			c.Seed, c.PanicRate, c.Timeout = seed, 0.05, 500*time.Millisecond
+			return RunChaos(c)
+		}, false, false, true},
+		{"LockInPlace", func(seed uint64) ChaosReport {
+			c := lockedChaos(true)
+			c.Seed, c.PanicRate = seed, 0.05
+			return RunChaos(c)
+		}, true, false, false},
+		{"PointerWithoutCAS", func(seed uint64) ChaosReport {
+			c := pointerChaos()
+			c.Seed, c.PanicRate = seed, 0.05
+			return RunChaos(c)
+		}, false, true, false},
+		{"Transact", func(seed uint64) ChaosReport {
+			c := transactChaos()
+			c.Seed, c.PanicRate = seed, 0.05
+			return RunChaos(c)
+		}, false, false, false},
+		{"AtomState", func(seed uint64) ChaosReport {
+			c := atomChaos(NewStateWrapper(nil))
+			c.Seed, c.PanicRate = seed, 0.05
+			return RunChaos(c)
+		}, false, false, false},
+		{"AtomPersistentStore", func(seed uint64) ChaosReport {
+			c := atomChaos(NewPersistentStore(nil))
+			c.Seed, c.PanicRate = seed, 0.05
+			return RunChaos(c)
+		}, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := tt.run(*chaosSeed)
+			if report.Failures == 0 {
+				t.Errorf("no faults injected (seed %d)", report.Seed)
+			}
+			if report.Partial > 0 != tt.partial || report.Lost > 0 != tt.lost || report.Deadlocked != tt.deadlock {
+				t.Errorf("got %v\nwant partial values %v, lost updates %v, deadlock %v",
+					report, tt.partial, tt.lost, tt.deadlock)
+			}
+
+			switch {
+			case !report.Failed():
+				printSuccess(fmt.Sprintf("%s: %s", tt.name, report.Summary()))
+			case report.Deadlocked:
+				printFailure(fmt.Sprintf("%s: %s", tt.name, report.Summary()))
+			default:
+				printFailure(fmt.Sprintf("%s: %s", tt.name, report))
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package faulttest
+
+import "iter"
+
+// Entries is what Diff reads of a store. State and every ImmutableStore
+// provide it.
+type Entries interface {
+	Get(key string) (string, bool)
+	All() iter.Seq2[string, string]
+}
+
+// Changes lists the keys that differ between two stores, each list in key
+// order.
+type Changes struct {
+	Added   []string // keys only the second store has
+	Removed []string // keys only the first store has
+	Changed []string // keys both have, with different values
+}
+
+// Empty reports whether the stores hold the same entries.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// Diff returns what changed from a to b. Tombstones aren't entries: a key
+// deleted in b is Removed if a has it, and not listed otherwise.
+func Diff(a, b Entries) Changes {
+	var c Changes
+	for k, v := range a.All() {
+		switch bv, ok := b.Get(k); {
+		case !ok:
+			c.Removed = append(c.Removed, k)
+		case bv != v:
+			c.Changed = append(c.Changed, k)
+		}
+	}
+	for k := range b.All() {
+		if _, ok := a.Get(k); !ok {
+			c.Added = append(c.Added, k)
+		}
+	}
+	return c
+}
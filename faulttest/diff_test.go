@@ -0,0 +1,183 @@
+package faulttest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/alexshd/lawtest"
+)
+
+// implementations are the ImmutableStore implementations every law is
+// checked for.
+var implementations = []struct {
+	name string
+	new  func(map[string]string) ImmutableStore
+}{
+	{"StateWrapper", func(data map[string]string) ImmutableStore { return NewStateWrapper(data) }},
+	{"PersistentStore", func(data map[string]string) ImmutableStore { return NewPersistentStore(data) }},
+}
+
+// randomStore builds a store with a few random sets and deletes of
+// overlapping keys, so tombstones meet values when stores are merged.
+func randomStore(newStore func(map[string]string) ImmutableStore) ImmutableStore {
+	s := newStore(randomData(4))
+	for range rand.IntN(3) {
+		s = s.Delete(fmt.Sprint("key", rand.IntN(8)))
+	}
+	return s
+}
+
+// TestDelete checks that deletions are immutable and survive Merge.
+func TestDelete(t *testing.T) {
+	printSection("DELETE - Tombstones that survive Merge")
+
+	printInfo("What we're testing: a deleted key stays deleted when the state is merged")
+	printInfo("Why it matters: merging a replica that never saw the key must not bring it back")
+	fmt.Println()
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			printStep(impl.name, "Delete, then merge")
+
+			a := impl.new(map[string]string{"a": "1", "b": "2"})
+			deleted := a.Delete("a")
+			if _, ok := a.Get("a"); !ok || a.Len() != 2 {
+				t.Errorf("Delete changed the original: %v", a)
+			}
+			if _, ok := deleted.Get("a"); ok || deleted.Len() != 1 {
+				t.Errorf("a.Delete(a) = %v, still holding a", deleted)
+			}
+
+			// A replica that still has a, merged either way
+			replica := impl.new(map[string]string{"a": "1", "c": "3"})
+			if _, ok := replica.Merge(deleted).Get("a"); ok {
+				t.Error("replica.Merge(deleted) brought a back")
+			}
+			if _, ok := impl.new(nil).Merge(replica.Merge(deleted)).Get("a"); ok {
+				t.Error("the tombstone was lost when merging the merged state again")
+			}
+			if v, ok := deleted.Merge(replica).Get("a"); !ok || v != "1" {
+				t.Errorf("deleted.Merge(replica) has a = %q, %v: the later value should win", v, ok)
+			}
+			if v, ok := deleted.Set("a", "again").Get("a"); !ok || v != "again" {
+				t.Errorf("Set after Delete = %q, %v", v, ok)
+			}
+			printSuccess("Deleted keys stay deleted through Merge; later writes still win")
+		})
+	}
+}
+
+// TestDeleteLaws checks the laws of Merge and Delete with tombstones, with
+// stores compared by content.
+func TestDeleteLaws(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			c := storeContents()
+			gen := c.gen(func() ImmutableStore { return randomStore(impl.new) })
+			merge := c.op(ImmutableStore.Merge)
+
+			t.Run("MergeAssociative", func(t *testing.T) {
+				lawtest.Associative(t, merge, gen)
+			})
+			t.Run("MergeIdentity", func(t *testing.T) {
+				lawtest.Identity(t, merge, c.of(impl.new(nil)), gen)
+			})
+			t.Run("DeleteIdempotent", func(t *testing.T) {
+				del := func(s string) string { return c.of(c.value(s).Delete("key1")) }
+				lawtest.Idempotent(t, del, gen)
+			})
+			t.Run("DeleteSurvivesMerge", func(t *testing.T) {
+				// a.Merge(b.Delete(k)) = a.Delete(k).Merge(b.Delete(k))
+				for range 100 {
+					a, b := randomStore(impl.new), randomStore(impl.new)
+					k := fmt.Sprint("key", rand.IntN(8))
+					left := a.Merge(b.Delete(k))
+					right := a.Delete(k).Merge(b.Delete(k))
+					if fmt.Sprint(left) != fmt.Sprint(right) {
+						t.Fatalf("%v.Merge(%v.Delete(%s)) = %v, want %v", a, b, k, left, right)
+					}
+				}
+			})
+		})
+	}
+}
+
+// TestAll checks that All iterates the entries in key order.
+func TestAll(t *testing.T) {
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			for range 50 {
+				s := randomStore(impl.new)
+				var keys []string
+				for k, v := range s.All() {
+					if got, ok := s.Get(k); !ok || got != v {
+						t.Fatalf("All yielded %s=%s, Get = %q, %v", k, v, got, ok)
+					}
+					keys = append(keys, k)
+				}
+				if !slices.IsSorted(keys) || len(keys) != s.Len() {
+					t.Fatalf("All yielded %v for %v, want the %d keys in order", keys, s, s.Len())
+				}
+			}
+
+			s := impl.new(map[string]string{"a": "1", "b": "2", "c": "3"})
+			for k := range s.All() {
+				if k != "a" {
+					t.Errorf("All went on after break, at %s", k)
+				}
+				break
+			}
+		})
+	}
+}
+
+// TestDiff checks Diff against updates of known effect, and its laws.
+func TestDiff(t *testing.T) {
+	printStep("Diff", "What changed between two versions of a state")
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			a := impl.new(map[string]string{"kept": "1", "changed": "2", "removed": "3"})
+			b := a.Set("changed", "two").Set("added", "4").Delete("removed").Delete("absent")
+			got := Diff(a, b)
+			want := Changes{Added: []string{"added"}, Removed: []string{"removed"}, Changed: []string{"changed"}}
+			if !slices.Equal(got.Added, want.Added) || !slices.Equal(got.Removed, want.Removed) || !slices.Equal(got.Changed, want.Changed) {
+				t.Errorf("Diff() = %+v, want %+v", got, want)
+			}
+
+			for range 100 {
+				a, b := randomStore(impl.new), randomStore(impl.new)
+				if d := Diff(a, a); !d.Empty() {
+					t.Fatalf("Diff(a, a) = %+v for %v", d, a)
+				}
+				ab, ba := Diff(a, b), Diff(b, a)
+				if !slices.Equal(ab.Added, ba.Removed) || !slices.Equal(ab.Removed, ba.Added) || !slices.Equal(ab.Changed, ba.Changed) {
+					t.Fatalf("Diff(a, b) = %+v is not the reverse of Diff(b, a) = %+v", ab, ba)
+				}
+
+				// Applying the diff to a gives b
+				applied := a
+				for _, k := range ab.Removed {
+					applied = applied.Delete(k)
+				}
+				for _, k := range slices.Concat(ab.Added, ab.Changed) {
+					v, _ := b.Get(k)
+					applied = applied.Set(k, v)
+				}
+				if d := Diff(applied, b); !d.Empty() {
+					t.Fatalf("applying Diff(%v, %v) left %+v", a, b, d)
+				}
+			}
+		})
+	}
+
+	t.Run("AcrossTypes", func(t *testing.T) {
+		data := randomData(6)
+		if d := Diff(NewState(data), NewPersistentStore(data)); !d.Empty() {
+			t.Errorf("Diff of a State and a PersistentStore with the same data = %+v", d)
+		}
+	})
+	printSuccess("Diff lists exactly the keys an update added, removed or changed")
+}
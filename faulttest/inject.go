@@ -0,0 +1,329 @@
+package faulttest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Point is where a fault is injected into an operation: before or after
+// its N-th write to shared state, at the start of its k-th call, or at any
+// write with probability p.
+//
+// MutateAndPanic hard-wires one such point, a panic after the first write.
+// Points make the experiment repeatable for any operation.
+type Point struct {
+	kind pointKind
+	n    int     // the write or call, counted from 1
+	p    float64 // the probability of a panic at each write
+}
+
+type pointKind int
+
+const (
+	beforeWrite pointKind = iota
+	afterWrite
+	onCall
+	randomly
+)
+
+// BeforeWrite panics just before the n-th write, leaving n-1 writes done.
+func BeforeWrite(n int) Point {
+	return Point{kind: beforeWrite, n: n}
+}
+
+// AfterWrite panics just after the n-th write.
+func AfterWrite(n int) Point {
+	return Point{kind: afterWrite, n: n}
+}
+
+// OnCall panics at the start of the k-th call of the operation, before it
+// writes anything.
+func OnCall(k int) Point {
+	return Point{kind: onCall, n: k}
+}
+
+// Randomly panics before any write with probability p, drawn from the
+// seeded source of the Target so a failing experiment can be replayed.
+func Randomly(p float64) Point {
+	return Point{kind: randomly, p: p}
+}
+
+func (p Point) String() string {
+	switch p.kind {
+	case beforeWrite:
+		return fmt.Sprintf("before write %d", p.n)
+	case afterWrite:
+		return fmt.Sprintf("after write %d", p.n)
+	case onCall:
+		return fmt.Sprintf("on call %d", p.n)
+	}
+	return fmt.Sprintf("randomly (p=%.2f)", p.p)
+}
+
+// Fault is the value an injected panic carries, telling it apart from a
+// panic of the operation itself.
+type Fault struct {
+	Point Point
+	Call  int // the call of the operation that panicked, counted from 1
+	Write int // the writes done in that call when it panicked
+}
+
+func (f Fault) String() string {
+	return fmt.Sprintf("injected fault %s (call %d, %d writes done)", f.Point, f.Call, f.Write)
+}
+
+// Faults is handed to the operation under test, which announces each
+// write to shared state by doing it through Write. That is where faults
+// are injected.
+type Faults struct {
+	mu     sync.Mutex
+	point  Point
+	rng    *rand.Rand
+	call   int // the current call
+	writes int // writes done in the current call
+	total  int // writes done in the experiment
+	fired  bool
+}
+
+// Write performs write, panicking before or after it when the injection
+// point says so. A point fires at most once per experiment.
+func (f *Faults) Write(write func()) {
+	f.check(beforeWrite)
+	write()
+	f.mu.Lock()
+	f.writes++
+	f.total++
+	f.mu.Unlock()
+	f.check(afterWrite)
+}
+
+// check panics with a Fault if the point fires at this stage of a write.
+func (f *Faults) check(stage pointKind) {
+	f.mu.Lock()
+	fire := false
+	if !f.fired {
+		switch f.point.kind {
+		case beforeWrite:
+			fire = stage == beforeWrite && f.total+1 == f.point.n
+		case afterWrite:
+			fire = stage == afterWrite && f.total == f.point.n
+		case randomly:
+			fire = stage == beforeWrite && f.rng.Float64() < f.point.p
+		}
+	}
+	f.fired = f.fired || fire
+	fault := Fault{f.point, f.call, f.writes}
+	f.mu.Unlock()
+	if fire {
+		panic(fault)
+	}
+}
+
+// start begins the next call, panicking if it is the one an OnCall point
+// names.
+func (f *Faults) start() {
+	f.mu.Lock()
+	f.call++
+	f.writes = 0
+	fire := !f.fired && f.point.kind == onCall && f.call == f.point.n
+	f.fired = f.fired || fire
+	fault := Fault{f.point, f.call, 0}
+	f.mu.Unlock()
+	if fire {
+		panic(fault)
+	}
+}
+
+// Target is an operation to inject faults into, with the shared state it
+// transforms.
+type Target[S any] struct {
+	// New returns fresh shared state; each injection point gets its own.
+	New func() S
+
+	// Op transforms the state, doing each write to shared state through
+	// faults.Write. It must be deterministic: a run without faults tells
+	// what a completed call leaves.
+	Op func(state S, faults *Faults)
+
+	// Observe returns what other goroutines can see of the state, compared
+	// with reflect.DeepEqual before and after a failed call. It must copy
+	// maps and slices the operation writes to.
+	Observe func(state S) any
+
+	// Calls is the number of calls per injection point, 1 if zero.
+	Calls int
+
+	// Seed seeds the source of Randomly points.
+	Seed uint64
+
+	// Timeout bounds Observe after a failed call; if it doesn't return, the
+	// panic left a lock held and the state deadlocked. 1s if zero.
+	Timeout time.Duration
+}
+
+// Result is the outcome of injecting faults at one point.
+type Result struct {
+	Point    Point
+	Injected bool // the point was reached; BeforeWrite(3) isn't in an operation writing twice
+	Panic    any  // the recovered panic, a Fault if injected, or nil
+
+	// Corrupted reports that a call panicked and left the observable state
+	// neither as it was before the call nor as a completed call leaves it:
+	// a partial write persisted.
+	Corrupted bool
+	// Deadlocked reports that the state couldn't be observed after a
+	// panic.
+	Deadlocked bool
+	Before     any // the observed state before the failed call
+	After      any // and after it
+}
+
+// Safe reports whether the state survived the fault whole.
+func (r Result) Safe() bool {
+	return !r.Corrupted && !r.Deadlocked
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Deadlocked:
+		return fmt.Sprintf("%s: deadlocked after %v", r.Point, r.Panic)
+	case r.Corrupted:
+		return fmt.Sprintf("%s: corrupted, %v became %v", r.Point, r.Before, r.After)
+	case r.Panic != nil:
+		return fmt.Sprintf("%s: contained %v", r.Point, r.Panic)
+	case !r.Injected:
+		return fmt.Sprintf("%s: not reached", r.Point)
+	}
+	return fmt.Sprintf("%s: no panic", r.Point)
+}
+
+// Report collects the results of an Inject run, one per point.
+type Report struct {
+	Results []Result
+}
+
+// Unsafe returns the results where the fault corrupted or deadlocked the
+// state.
+func (r Report) Unsafe() []Result {
+	var unsafe []Result
+	for _, res := range r.Results {
+		if !res.Safe() {
+			unsafe = append(unsafe, res)
+		}
+	}
+	return unsafe
+}
+
+// Summary sums up which injection points broke the state:
+//
+//	2 of 4 injection points corrupted state: after write 1, before write 2
+func (r Report) Summary() string {
+	unsafe := r.Unsafe()
+	if len(unsafe) == 0 {
+		return fmt.Sprintf("all %d injection points contained", len(r.Results))
+	}
+	points := make([]string, len(unsafe))
+	for i, res := range unsafe {
+		points[i] = res.Point.String()
+		if res.Deadlocked {
+			points[i] += " (deadlock)"
+		}
+	}
+	return fmt.Sprintf("%d of %d injection points corrupted state: %s",
+		len(unsafe), len(r.Results), strings.Join(points, ", "))
+}
+
+func (r Report) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Summary())
+	for _, res := range r.Results {
+		sb.WriteString("\n  ")
+		sb.WriteString(res.String())
+	}
+	return sb.String()
+}
+
+// Inject runs target once per point on fresh state, panicking where the
+// point says, and reports for each whether the panic was contained. This
+// generalizes the MutateAndPanic experiment to any operation: Law I holds
+// when every point leaves the observable state as it was before the
+// failed call, or as it would be had the call completed.
+func Inject[S any](target Target[S], points ...Point) Report {
+	var report Report
+	for _, p := range points {
+		report.Results = append(report.Results, inject(target, p))
+	}
+	return report
+}
+
+func inject[S any](target Target[S], p Point) Result {
+	calls := max(target.Calls, 1)
+	timeout := target.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	completed := complete(target, calls)
+	faults := &Faults{point: p, rng: rand.New(rand.NewPCG(target.Seed, target.Seed))}
+	state := target.New()
+	res := Result{Point: p}
+
+	for i := range calls {
+		before := target.Observe(state)
+		_, res.Panic = IsolatedOperation(func() {
+			faults.start()
+			target.Op(state, faults)
+		})
+		if res.Panic == nil {
+			continue
+		}
+		// The call failed: the state must be as it was, or as if the call
+		// completed
+		after, ok := observe(target.Observe, state, timeout)
+		res.Before, res.After = before, after
+		res.Deadlocked = !ok
+		res.Corrupted = ok && !reflect.DeepEqual(before, after) &&
+			(completed[i] == nil || !reflect.DeepEqual(*completed[i], after))
+		break
+	}
+	res.Injected = faults.fired
+	return res
+}
+
+// complete runs target without faults, returning the state observed after
+// each call, or nil for calls that panicked on their own.
+func complete[S any](target Target[S], calls int) []*any {
+	faults := &Faults{fired: true}
+	state := target.New()
+	completed := make([]*any, calls)
+	for i := range calls {
+		ok, _ := IsolatedOperation(func() {
+			faults.start()
+			target.Op(state, faults)
+		})
+		if !ok {
+			break
+		}
+		after := target.Observe(state)
+		completed[i] = &after
+	}
+	return completed
+}
+
+// observe calls fn, giving up after timeout. The goroutine of a call that
+// deadlocked is left behind.
+func observe[S any](fn func(S) any, state S, timeout time.Duration) (any, bool) {
+	done := make(chan any, 1)
+	go func() {
+		done <- fn(state)
+	}()
+	select {
+	case v := <-done:
+		return v, true
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
@@ -0,0 +1,183 @@
+package faulttest
+
+import (
+	"fmt"
+	"maps"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// criticalTarget updates CriticalState in place, the way MutateAndPanic
+// does, writing a partial value before the final one.
+func criticalTarget() Target[*CriticalState] {
+	return Target[*CriticalState]{
+		New: NewCriticalState,
+		Op: func(state *CriticalState, faults *Faults) {
+			state.Lock.Lock()
+			defer state.Lock.Unlock()
+			faults.Write(func() { state.Config["key"] = "value_PARTIAL" })
+			faults.Write(func() { state.Config["key"] = "value" })
+		},
+		Observe: func(state *CriticalState) any {
+			state.Lock.Lock()
+			defer state.Lock.Unlock()
+			return maps.Clone(state.Config)
+		},
+	}
+}
+
+// immutableTarget builds the next State aside and publishes it with a
+// single atomic write, so no partial state is ever visible.
+func immutableTarget() Target[*atomic.Pointer[State]] {
+	return Target[*atomic.Pointer[State]]{
+		New: func() *atomic.Pointer[State] {
+			var p atomic.Pointer[State]
+			p.Store(NewState(map[string]string{"base": "value"}))
+			return &p
+		},
+		Op: func(state *atomic.Pointer[State], faults *Faults) {
+			next := state.Load().Set("key", "value_PARTIAL").Set("key", "value")
+			faults.Write(func() { state.Store(next) })
+		},
+		Observe: func(state *atomic.Pointer[State]) any {
+			return state.Load().String()
+		},
+	}
+}
+
+// TestInject runs the MutateAndPanic experiment at every injection point,
+// against mutable shared state and against immutable state.
+func TestInject(t *testing.T) {
+	printSection("FAULT INJECTION API - Which crash points corrupt state?")
+
+	printInfo("What we're testing: the same operation crashed at each write and call")
+	printInfo("Why it matters: a single safe run proves nothing about the next crash point")
+	fmt.Println()
+
+	points := []Point{BeforeWrite(1), AfterWrite(1), BeforeWrite(2), AfterWrite(2), BeforeWrite(3), AfterWrite(3), OnCall(2)}
+
+	t.Run("CriticalState", func(t *testing.T) {
+		printStep("Experiment 1", "Mutable shared state with a lock")
+
+		target := criticalTarget()
+		target.Calls = 2
+		report := Inject(target, points...)
+		printInfo(report.String())
+
+		// Before a call's first write nothing changed, and after its
+		// second the call is complete; in between value_PARTIAL shows.
+		// Writes 3 and 4 are those of call 2.
+		want := map[string]bool{
+			"before write 1": false,
+			"after write 1":  true,
+			"before write 2": true,
+			"after write 2":  false,
+			"before write 3": false,
+			"after write 3":  true,
+			"on call 2":      false,
+		}
+		for _, res := range report.Results {
+			if !res.Injected {
+				t.Errorf("%s: not injected", res.Point)
+			}
+			if _, ok := res.Panic.(Fault); !ok {
+				t.Errorf("%s: panic = %v, want a Fault", res.Point, res.Panic)
+			}
+			if res.Deadlocked {
+				t.Errorf("%s: deadlocked, though the lock is released by defer", res.Point)
+			}
+			if res.Corrupted != want[res.Point.String()] {
+				t.Errorf("%s: corrupted = %v, want %v", res.Point, res.Corrupted, want[res.Point.String()])
+			}
+		}
+		if got, want := report.Summary(), "3 of 7 injection points corrupted state: after write 1, before write 2, after write 3"; got != want {
+			t.Errorf("Summary() = %q, want %q", got, want)
+		}
+		printWarning(report.Summary())
+	})
+
+	t.Run("ImmutableState", func(t *testing.T) {
+		printStep("Experiment 2", "Immutable state published with one write")
+
+		target := immutableTarget()
+		target.Calls = 2
+		report := Inject(target, BeforeWrite(1), AfterWrite(1), BeforeWrite(2), OnCall(2), Randomly(0.5))
+		printInfo(report.String())
+
+		if unsafe := report.Unsafe(); len(unsafe) > 0 {
+			t.Errorf("immutable state corrupted: %s", report.Summary())
+		}
+		printSuccess(report.Summary())
+	})
+
+	t.Run("NotReached", func(t *testing.T) {
+		report := Inject(criticalTarget(), BeforeWrite(3), OnCall(2))
+		for _, res := range report.Results {
+			if res.Injected || res.Panic != nil || !res.Safe() {
+				t.Errorf("%s: got %+v, want a clean run", res.Point, res)
+			}
+		}
+	})
+
+	t.Run("OwnPanic", func(t *testing.T) {
+		printStep("Experiment 3", "The operation's own panic is checked too")
+
+		target := Target[*CriticalState]{
+			New: NewCriticalState,
+			Op: func(state *CriticalState, faults *Faults) {
+				MutateAndPanic(state, "key1", "value_corrupt")
+			},
+			Observe: criticalTarget().Observe,
+		}
+		res := Inject(target, OnCall(2)).Results[0]
+		if res.Injected {
+			t.Error("OnCall(2) injected on a single call")
+		}
+		if !res.Corrupted {
+			t.Errorf("MutateAndPanic not reported as corrupting: %v", res)
+		}
+	})
+
+	t.Run("Deadlock", func(t *testing.T) {
+		printStep("Experiment 4", "A crash while holding the lock, without defer")
+
+		target := criticalTarget()
+		target.Op = func(state *CriticalState, faults *Faults) {
+			state.Lock.Lock()
+			faults.Write(func() { state.Config["key"] = "value" })
+			state.Lock.Unlock()
+		}
+		target.Timeout = 50 * time.Millisecond
+		res := Inject(target, BeforeWrite(1)).Results[0]
+		if !res.Deadlocked {
+			t.Errorf("deadlock not detected: %v", res)
+		}
+		printWarning(res.String())
+	})
+}
+
+// TestInjectRandomly checks that random injection is reproducible from the
+// seed.
+func TestInjectRandomly(t *testing.T) {
+	target := criticalTarget()
+	target.Calls = 20
+	injected := 0
+	for seed := range uint64(10) {
+		target.Seed = seed
+		first := Inject(target, Randomly(0.3)).Results[0]
+		again := Inject(target, Randomly(0.3)).Results[0]
+		if first.String() != again.String() {
+			t.Errorf("seed %d: %v, then %v", seed, first, again)
+		}
+		if first.Injected {
+			injected++
+			if f, ok := first.Panic.(Fault); !ok || f.Write > 1 {
+				t.Errorf("seed %d: panic = %v, want a Fault before write 1 or 2", seed, first.Panic)
+			}
+		}
+	}
+	if injected == 0 {
+		t.Error("Randomly(0.3) never fired over 10 seeds of 20 calls")
+	}
+}
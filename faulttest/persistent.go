@@ -0,0 +1,325 @@
+package faulttest
+
+import (
+	"cmp"
+	"fmt"
+	"hash/maphash"
+	"iter"
+	"maps"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+// PersistentStore is an ImmutableStore backed by a hash array mapped trie
+// (HAMT). Set copies only the path to the key, O(log n) nodes of at most 32
+// slots, and shares every other subtree with the original store. Merge
+// descends only where both stores have entries, reusing the subtrees just
+// one of them has.
+//
+// Deleted keys stay in the trie as tombstones, which Merge carries over
+// like values, so deletions survive it as they do for State.
+//
+// State copies the whole map on every write, which is fine for small
+// configurations; PersistentStore keeps updates cheap as the state grows.
+type PersistentStore struct {
+	root *hamtNode
+}
+
+// NewPersistentStore creates a new PersistentStore with the given data.
+func NewPersistentStore(data map[string]string) *PersistentStore {
+	root := emptyNode
+	for k, v := range data {
+		root = root.set(hashKey(k), 0, k, v)
+	}
+	return &PersistentStore{root: root}
+}
+
+// hamtBits is the number of hash bits consumed per level, for 32-way
+// branching.
+const hamtBits = 5
+
+var (
+	hashSeed  = maphash.MakeSeed()
+	emptyNode = &hamtNode{}
+)
+
+func hashKey(key string) uint64 {
+	return maphash.String(hashSeed, key)
+}
+
+// hamtNode holds the slots present among the 32 possible at its level,
+// compacted: bitmap has bit i set if slot i is present, at index
+// popcount(bitmap & (1<<i - 1)) in slots. Nodes are never modified once
+// built.
+type hamtNode struct {
+	bitmap uint32
+	slots  []hamtSlot
+	size   int // entries in the subtree, not counting tombstones
+}
+
+// hamtSlot is either a subtree or a leaf.
+type hamtSlot struct {
+	node *hamtNode
+	leaf *hamtLeaf
+}
+
+func (s hamtSlot) size() int {
+	if s.node != nil {
+		return s.node.size
+	}
+	size := 0
+	for _, e := range s.leaf.entries {
+		if !e.deleted {
+			size++
+		}
+	}
+	return size
+}
+
+// hamtLeaf holds the entries of one hash, sorted by key. There is more than
+// one only when full 64-bit hashes collide.
+type hamtLeaf struct {
+	hash    uint64
+	entries []hamtEntry
+}
+
+type hamtEntry struct {
+	key, value string
+	deleted    bool // a tombstone
+}
+
+func newNode(bitmap uint32, slots []hamtSlot) *hamtNode {
+	n := &hamtNode{bitmap: bitmap, slots: slots}
+	for _, s := range slots {
+		n.size += s.size()
+	}
+	return n
+}
+
+// index returns the bit of hash h at shift, and the position of its slot.
+func (n *hamtNode) index(h uint64, shift uint) (bit uint32, pos int) {
+	bit = 1 << ((h >> shift) & (1<<hamtBits - 1))
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *hamtNode) get(h uint64, shift uint, key string) (string, bool) {
+	for {
+		bit, pos := n.index(h, shift)
+		if n.bitmap&bit == 0 {
+			return "", false
+		}
+		s := n.slots[pos]
+		if s.node == nil {
+			return s.leaf.get(h, key)
+		}
+		n, shift = s.node, shift+hamtBits
+	}
+}
+
+func (l *hamtLeaf) get(h uint64, key string) (string, bool) {
+	if l.hash != h {
+		return "", false
+	}
+	if i, ok := l.search(key); ok && !l.entries[i].deleted {
+		return l.entries[i].value, true
+	}
+	return "", false
+}
+
+func (l *hamtLeaf) search(key string) (int, bool) {
+	return slices.BinarySearchFunc(l.entries, key, func(e hamtEntry, key string) int {
+		return strings.Compare(e.key, key)
+	})
+}
+
+// set returns a copy of n with key set, sharing all subtrees off the path
+// to the key.
+func (n *hamtNode) set(h uint64, shift uint, key, value string) *hamtNode {
+	return n.insert(&hamtLeaf{hash: h, entries: []hamtEntry{{key: key, value: value}}}, shift)
+}
+
+// delete returns a copy of n with a tombstone for key.
+func (n *hamtNode) delete(h uint64, shift uint, key string) *hamtNode {
+	return n.insert(&hamtLeaf{hash: h, entries: []hamtEntry{{key: key, deleted: true}}}, shift)
+}
+
+// insert returns a copy of n with the entries of l added, those of l
+// winning.
+func (n *hamtNode) insert(l *hamtLeaf, shift uint) *hamtNode {
+	bit, pos := n.index(l.hash, shift)
+	if n.bitmap&bit == 0 {
+		return newNode(n.bitmap|bit, slices.Insert(slices.Clone(n.slots), pos, hamtSlot{leaf: l}))
+	}
+	return n.replace(pos, mergeSlots(n.slots[pos], hamtSlot{leaf: l}, shift+hamtBits))
+}
+
+// replace returns a copy of n with the slot at pos replaced.
+func (n *hamtNode) replace(pos int, s hamtSlot) *hamtNode {
+	slots := slices.Clone(n.slots)
+	slots[pos] = s
+	return newNode(n.bitmap, slots)
+}
+
+// merge returns the union of a and b, those of b winning, at the level of
+// shift. Slots only one side has are shared, not copied.
+func merge(a, b *hamtNode, shift uint) *hamtNode {
+	switch {
+	case a == b || b.bitmap == 0:
+		return a
+	case a.bitmap == 0:
+		return b
+	}
+	union := a.bitmap | b.bitmap
+	slots := make([]hamtSlot, 0, bits.OnesCount32(union))
+	for rest := union; rest != 0; rest &= rest - 1 {
+		bit := rest & -rest
+		ina, inb := a.bitmap&bit != 0, b.bitmap&bit != 0
+		pa := bits.OnesCount32(a.bitmap & (bit - 1))
+		pb := bits.OnesCount32(b.bitmap & (bit - 1))
+		switch {
+		case ina && inb:
+			slots = append(slots, mergeSlots(a.slots[pa], b.slots[pb], shift+hamtBits))
+		case ina:
+			slots = append(slots, a.slots[pa])
+		default:
+			slots = append(slots, b.slots[pb])
+		}
+	}
+	return newNode(union, slots)
+}
+
+// mergeSlots merges two slots at the same position, whose contents are at
+// the level of shift.
+func mergeSlots(a, b hamtSlot, shift uint) hamtSlot {
+	switch {
+	case a.node != nil && b.node != nil:
+		return hamtSlot{node: merge(a.node, b.node, shift)}
+	case a.node != nil:
+		return hamtSlot{node: a.node.insert(b.leaf, shift)}
+	case b.node != nil:
+		return hamtSlot{node: merge(emptyNode.insert(a.leaf, shift), b.node, shift)}
+	case a.leaf.hash == b.leaf.hash:
+		return hamtSlot{leaf: mergeLeaves(a.leaf, b.leaf)}
+	}
+	// Different hashes sharing a prefix: push both a level down, where
+	// they part eventually since the hashes differ
+	return hamtSlot{node: emptyNode.insert(a.leaf, shift).insert(b.leaf, shift)}
+}
+
+// mergeLeaves merges the entries of two leaves of the same hash, those of
+// b winning.
+func mergeLeaves(a, b *hamtLeaf) *hamtLeaf {
+	if a == b {
+		return a
+	}
+	entries := slices.Clone(a.entries)
+	for _, e := range b.entries {
+		i, ok := slices.BinarySearchFunc(entries, e.key, func(e hamtEntry, key string) int {
+			return strings.Compare(e.key, key)
+		})
+		if ok {
+			entries[i] = e
+		} else {
+			entries = slices.Insert(entries, i, e)
+		}
+	}
+	return &hamtLeaf{hash: a.hash, entries: entries}
+}
+
+// each calls fn for the entries of n, tombstones included, in hash order.
+func (n *hamtNode) each(fn func(e hamtEntry)) {
+	for _, s := range n.slots {
+		if s.node != nil {
+			s.node.each(fn)
+			continue
+		}
+		for _, e := range s.leaf.entries {
+			fn(e)
+		}
+	}
+}
+
+// Get retrieves a value from the store.
+func (ps *PersistentStore) Get(key string) (string, bool) {
+	if ps == nil || ps.root == nil {
+		return "", false
+	}
+	return ps.root.get(hashKey(key), 0, key)
+}
+
+// Set returns a new store with the key-value pair added, copying O(log n)
+// nodes. The original store is unchanged.
+func (ps *PersistentStore) Set(key, value string) ImmutableStore {
+	return &PersistentStore{root: ps.node().set(hashKey(key), 0, key, value)}
+}
+
+// Merge combines two stores, with the other store's values taking
+// precedence. Subtrees only one store has are shared with the result.
+func (ps *PersistentStore) Merge(other ImmutableStore) ImmutableStore {
+	o, ok := other.(*PersistentStore)
+	if !ok {
+		return ps
+	}
+	return &PersistentStore{root: merge(ps.node(), o.node(), 0)}
+}
+
+// Delete returns a new store without the key, leaving a tombstone so the
+// deletion survives Merge. The original store is unchanged.
+func (ps *PersistentStore) Delete(key string) ImmutableStore {
+	return &PersistentStore{root: ps.node().delete(hashKey(key), 0, key)}
+}
+
+// All returns an iterator over the entries of the store in key order.
+// The trie is in hash order, so the entries are sorted first, in O(n log n).
+func (ps *PersistentStore) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		entries := make([]hamtEntry, 0, ps.Len())
+		ps.node().each(func(e hamtEntry) {
+			if !e.deleted {
+				entries = append(entries, e)
+			}
+		})
+		slices.SortFunc(entries, func(a, b hamtEntry) int {
+			return cmp.Compare(a.key, b.key)
+		})
+		for _, e := range entries {
+			if !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of entries in the store.
+func (ps *PersistentStore) Len() int {
+	return ps.node().size
+}
+
+// String implements fmt.Stringer for debugging, printing the entries
+// sorted by key and the tombstones like State does.
+func (ps *PersistentStore) String() string {
+	data := make(map[string]string, ps.Len())
+	deleted := make(map[string]bool)
+	ps.node().each(func(e hamtEntry) {
+		if e.deleted {
+			deleted[e.key] = true
+		} else {
+			data[e.key] = e.value
+		}
+	})
+	if len(deleted) > 0 {
+		return fmt.Sprintf("%v deleted %v", data, slices.Sorted(maps.Keys(deleted)))
+	}
+	return fmt.Sprintf("%v", data)
+}
+
+func (ps *PersistentStore) node() *hamtNode {
+	if ps == nil || ps.root == nil {
+		return emptyNode
+	}
+	return ps.root
+}
+
+// Ensure PersistentStore implements ImmutableStore at compile time
+var _ ImmutableStore = (*PersistentStore)(nil)
@@ -0,0 +1,283 @@
+package faulttest
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/alexshd/lawtest"
+)
+
+// contents lets lawtest, which compares with ==, check the laws of
+// operations on stores by what they hold rather than by pointer: values
+// are passed around as a key rendering their contents, mapped back to a
+// value holding them.
+type contents[T any] struct {
+	key    func(T) string
+	mu     sync.Mutex
+	values map[string]T
+}
+
+func newContents[T any](key func(T) string) *contents[T] {
+	return &contents[T]{key: key, values: make(map[string]T)}
+}
+
+// storeContents compares stores by their String(), which lists entries
+// and tombstones.
+func storeContents() *contents[ImmutableStore] {
+	return newContents(func(s ImmutableStore) string { return fmt.Sprint(s) })
+}
+
+func (c *contents[T]) of(v T) string {
+	key := c.key(v)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = v
+	return key
+}
+
+func (c *contents[T]) value(key string) T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+// op lifts an operation to contents.
+func (c *contents[T]) op(fn func(a, b T) T) lawtest.BinaryOp[string] {
+	return func(a, b string) string {
+		return c.of(fn(c.value(a), c.value(b)))
+	}
+}
+
+// gen lifts a generator to contents.
+func (c *contents[T]) gen(fn func() T) lawtest.Generator[string] {
+	return func() string {
+		return c.of(fn())
+	}
+}
+
+// randomData returns up to n entries drawn from a few keys and values, so
+// that generated stores overlap.
+func randomData(n int) map[string]string {
+	data := make(map[string]string)
+	for range rand.IntN(n + 1) {
+		data[fmt.Sprint("key", rand.IntN(8))] = fmt.Sprint("value", rand.IntN(4))
+	}
+	return data
+}
+
+// storeData reads back the entries of keys from s.
+func storeData(s ImmutableStore, keys []string) map[string]string {
+	data := make(map[string]string)
+	for _, k := range keys {
+		if v, ok := s.Get(k); ok {
+			data[k] = v
+		}
+	}
+	return data
+}
+
+// TestPersistentStore checks PersistentStore against a map, keeping every
+// version to prove none is changed by later updates.
+func TestPersistentStore(t *testing.T) {
+	printSection("PERSISTENT STORE - Immutability without copying")
+
+	printInfo("What we're testing: a HAMT store behaves like State, sharing structure instead of copying")
+	printInfo("Why it matters: State.Set copies the whole map, O(n) per update")
+	fmt.Println()
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+	}
+
+	t.Run("Versions", func(t *testing.T) {
+		printStep("Test 1", "2000 random updates, every version checked at the end")
+
+		var stores []ImmutableStore
+		var models []map[string]string
+		var store ImmutableStore = NewPersistentStore(nil)
+		model := map[string]string{}
+		for i := range 2000 {
+			if i%100 == 99 {
+				// Merge with an earlier version now and then
+				j := rand.IntN(len(stores))
+				store = store.Merge(stores[j])
+				model = SafeMerge(model, models[j])
+			} else {
+				k, v := keys[rand.IntN(len(keys))], fmt.Sprint("value", i)
+				store = store.Set(k, v)
+				model = SafeUpdate(model, k, v)
+			}
+			stores = append(stores, store)
+			models = append(models, model)
+		}
+
+		for i, s := range stores {
+			if got := storeData(s, keys); !maps.Equal(got, models[i]) || s.Len() != len(models[i]) {
+				t.Fatalf("version %d holds %d entries %v, want %d entries %v", i, s.Len(), got, len(models[i]), models[i])
+			}
+		}
+		printSuccess("Every version still holds exactly what it held when created")
+	})
+
+	t.Run("Laws", func(t *testing.T) {
+		printStep("Test 2", "Merge obeys the laws State.Merge does")
+
+		gen := func() *PersistentStore { return NewPersistentStore(randomData(6)) }
+		merge := func(a, b *PersistentStore) *PersistentStore { return a.Merge(b).(*PersistentStore) }
+		lawtest.ImmutableOp(t, merge, gen)
+		if !lawtest.ParallelSafe(t, merge, gen, 20) {
+			t.Error("PersistentStore.Merge has race conditions")
+		}
+
+		c := storeContents()
+		op := c.op(ImmutableStore.Merge)
+		storeGen := c.gen(func() ImmutableStore { return gen() })
+		lawtest.Associative(t, op, storeGen)
+		lawtest.Identity(t, op, c.of(NewPersistentStore(nil)), storeGen)
+		printSuccess("Immutable, parallel safe, associative, with the empty store as identity")
+	})
+
+	t.Run("StructuralSharing", func(t *testing.T) {
+		printStep("Test 3", "Updates copy a path, not the store")
+
+		data := make(map[string]string)
+		for i := range 10_000 {
+			data[fmt.Sprint("key", i)] = "value"
+		}
+		big := NewPersistentStore(data)
+
+		updated := big.Set("key42", "changed").(*PersistentStore)
+		depth := pathLen(updated.root, "key42")
+		if n := newNodes(big.root, updated.root); n > depth {
+			t.Errorf("Set copied %d nodes, want at most the %d of the path", n, depth)
+		}
+		if v, _ := big.Get("key42"); v != "value" {
+			t.Errorf("Set changed the original: key42 = %q", v)
+		}
+
+		small := NewPersistentStore(map[string]string{"key1": "changed", "new": "value"})
+		merged := big.Merge(small).(*PersistentStore)
+		paths := pathLen(merged.root, "key1") + pathLen(merged.root, "new")
+		if n := newNodes(big.root, merged.root); n > paths {
+			t.Errorf("Merge copied %d nodes, want at most the %d of two paths", n, paths)
+		}
+		if merged.Len() != 10_001 {
+			t.Errorf("merged Len() = %d, want 10001", merged.Len())
+		}
+		if big.Merge(NewPersistentStore(nil)).(*PersistentStore).root != big.root {
+			t.Error("merging with the empty store copied the store")
+		}
+		printSuccess(fmt.Sprintf("One update of 10k entries copies at most %d nodes of 32 slots", depth))
+	})
+
+	t.Run("HashCollisions", func(t *testing.T) {
+		// Hashes sharing all bits, and all but the top ones
+		root := emptyNode.set(42, 0, "a", "1").set(42, 0, "b", "2").set(42|1<<63, 0, "c", "3")
+		root = merge(root, emptyNode.set(42, 0, "a", "4").set(7, 0, "d", "5"), 0)
+
+		for _, e := range []struct {
+			hash       uint64
+			key, value string
+		}{{42, "a", "4"}, {42, "b", "2"}, {42 | 1<<63, "c", "3"}, {7, "d", "5"}} {
+			if v, ok := root.get(e.hash, 0, e.key); !ok || v != e.value {
+				t.Errorf("get(%s) = %q, %v, want %q", e.key, v, ok, e.value)
+			}
+		}
+		if _, ok := root.get(42|1<<63, 0, "a"); ok {
+			t.Error("a found under the hash of c")
+		}
+		if root.size != 4 {
+			t.Errorf("size = %d, want 4", root.size)
+		}
+	})
+}
+
+// newNodes counts the nodes of b not shared with a.
+// pathLen returns the number of nodes from root down to the slot of key.
+// Hashes are seeded per process, so the path differs between runs.
+func pathLen(root *hamtNode, key string) int {
+	h, shift := hashKey(key), uint(0)
+	depth := 1
+	for n := root; ; depth++ {
+		bit, pos := n.index(h, shift)
+		if n.bitmap&bit == 0 || n.slots[pos].node == nil {
+			return depth
+		}
+		n, shift = n.slots[pos].node, shift+hamtBits
+	}
+}
+
+func newNodes(a, b *hamtNode) int {
+	shared := make(map[*hamtNode]bool)
+	var mark func(n *hamtNode)
+	mark = func(n *hamtNode) {
+		shared[n] = true
+		for _, s := range n.slots {
+			if s.node != nil {
+				mark(s.node)
+			}
+		}
+	}
+	mark(a)
+	count := 0
+	var walk func(n *hamtNode)
+	walk = func(n *hamtNode) {
+		if shared[n] {
+			return
+		}
+		count++
+		for _, s := range n.slots {
+			if s.node != nil {
+				walk(s.node)
+			}
+		}
+	}
+	walk(b)
+	return count
+}
+
+// BenchmarkStores compares the copying StateWrapper with PersistentStore as
+// the state grows. Merge merges in 10 entries, like an update message.
+func BenchmarkStores(b *testing.B) {
+	stores := []struct {
+		name string
+		new  func(map[string]string) ImmutableStore
+	}{
+		{"StateWrapper", func(data map[string]string) ImmutableStore { return NewStateWrapper(data) }},
+		{"PersistentStore", func(data map[string]string) ImmutableStore { return NewPersistentStore(data) }},
+	}
+	for _, size := range []int{10, 10_000, 1_000_000} {
+		data := make(map[string]string, size)
+		for i := range size {
+			data[fmt.Sprint("key", i)] = "value"
+		}
+		update := make(map[string]string, 10)
+		for i := range 10 {
+			update[fmt.Sprint("key", i*size/10)] = "changed"
+		}
+
+		for _, s := range stores {
+			store, other := s.new(data), s.new(update)
+
+			b.Run(fmt.Sprintf("Set/%s/%d", s.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					store.Set("key", "newValue")
+				}
+			})
+			b.Run(fmt.Sprintf("Get/%s/%d", s.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					store.Get("key1")
+				}
+			})
+			b.Run(fmt.Sprintf("Merge/%s/%d", s.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					store.Merge(other)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package faulttest
+
+import (
+	"iter"
+	"slices"
+	"time"
+)
+
+// MergePolicy resolves the conflicts of MergeWith: the keys both states
+// hold, or deleted. A deletion is an Entry with no values, written at the
+// time of DeleteAt, so a policy weighs it against writes like any other
+// entry; resolving to no values deletes the key. The laws MergeWith obeys
+// are those of the policy:
+//
+//	                  associative  commutative  idempotent
+//	RightWins         yes          no           yes
+//	LastWriterWins    yes          yes          yes
+//	LexicographicMax  yes          yes          yes
+//	MultiValue        yes          yes          yes
+//
+// A commutative policy lets replicas merge in any order and still agree.
+// Custom policies made with Resolve obey whatever laws their function
+// does; TestMergePolicies shows how to check them.
+type MergePolicy struct {
+	name    string
+	resolve func(key string, left, right Entry) Entry
+}
+
+func (p MergePolicy) String() string {
+	return p.name
+}
+
+var (
+	// RightWins keeps the other state's entry, as Merge does. Replicas
+	// merging in different orders diverge.
+	RightWins = MergePolicy{"right wins", func(_ string, _, right Entry) Entry {
+		return right
+	}}
+
+	// LastWriterWins keeps the entry written last, by the times of SetAt
+	// and DeleteAt. Ties go to the greater values, so that all replicas
+	// pick the same: at equal times, a write wins over a deletion.
+	LastWriterWins = MergePolicy{"last writer wins", func(_ string, left, right Entry) Entry {
+		if c := left.Time.Compare(right.Time); c > 0 || c == 0 && slices.Compare(left.Values, right.Values) > 0 {
+			return left
+		}
+		return right
+	}}
+
+	// LexicographicMax keeps the greatest value, written at the later time.
+	// A value wins over a deletion, whenever it was written.
+	LexicographicMax = MergePolicy{"lexicographic max", func(_ string, left, right Entry) Entry {
+		e := Entry{Time: later(left.Time, right.Time)}
+		if values := slices.Concat(left.Values, right.Values); len(values) > 0 {
+			e.Values = []string{slices.Max(values)}
+		}
+		return e
+	}}
+
+	// MultiValue keeps all the values, as siblings: Get returns the least,
+	// Values returns them all. Set resolves the conflict, replacing them
+	// with one value. A deletion adds no value, so any value wins over it.
+	MultiValue = MergePolicy{"multi-value", func(_ string, left, right Entry) Entry {
+		return Entry{
+			Values: slices.Compact(slices.Sorted(slices.Values(slices.Concat(left.Values, right.Values)))),
+			Time:   later(left.Time, right.Time),
+		}
+	}}
+)
+
+// Resolve returns a policy resolving conflicts with fn, named name.
+func Resolve(name string, fn func(key string, left, right Entry) Entry) MergePolicy {
+	return MergePolicy{name, fn}
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// SetAt returns a new State with the key-value pair added, written at the
+// given time, which LastWriterWins decides conflicts on.
+func (s *State) SetAt(key, value string, at time.Time) *State {
+	next := s.Set(key, value)
+	return &State{data: next.data, deleted: next.deleted, meta: withEntry(s.meta, key, Entry{Values: []string{value}, Time: at})}
+}
+
+// DeleteAt returns a new State without the key, deleted at the given time,
+// which LastWriterWins decides conflicts on.
+func (s *State) DeleteAt(key string, at time.Time) *State {
+	next := s.Delete(key)
+	return &State{data: next.data, deleted: next.deleted, meta: withEntry(s.meta, key, Entry{Time: at})}
+}
+
+// Values returns all the values of the key, several if MultiValue merged
+// conflicting ones.
+func (s *State) Values(key string) []string {
+	if _, ok := s.Get(key); !ok {
+		return nil
+	}
+	return slices.Clone(s.entry(key).Values)
+}
+
+// entry returns the entry of key, which s holds or deleted.
+func (s *State) entry(key string) Entry {
+	if e, ok := s.meta[key]; ok {
+		return e
+	}
+	if v, ok := s.data[key]; ok {
+		return Entry{Values: []string{v}}
+	}
+	return Entry{}
+}
+
+// has reports whether s holds key or deleted it.
+func (s *State) has(key string) bool {
+	_, set := s.data[key]
+	_, deleted := s.deleted[key]
+	return set || deleted
+}
+
+// MergeWith combines two states like Merge, resolving the keys both hold or
+// deleted with policy instead of letting the other state win.
+func (s *State) MergeWith(other *State, policy MergePolicy) *State {
+	if other == nil {
+		return s
+	}
+	merged := s.Merge(other)
+	for k := range s.keys() {
+		if !other.has(k) {
+			continue
+		}
+		e := policy.resolve(k, s.entry(k), other.entry(k))
+		e.Values = slices.Compact(slices.Sorted(slices.Values(e.Values)))
+		// merged is ours until returned: update it in place
+		if len(e.Values) == 0 {
+			delete(merged.data, k)
+			if merged.deleted == nil {
+				merged.deleted = make(map[string]struct{})
+			}
+			merged.deleted[k] = struct{}{}
+		} else {
+			merged.data[k] = e.Values[0]
+			delete(merged.deleted, k)
+		}
+		if len(e.Values) <= 1 && e.Time.IsZero() {
+			delete(merged.meta, k)
+			continue
+		}
+		if merged.meta == nil {
+			merged.meta = make(map[string]Entry)
+		}
+		merged.meta[k] = e
+	}
+	return merged
+}
+
+// keys returns the keys s holds or deleted.
+func (s *State) keys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for k := range s.data {
+			if !yield(k) {
+				return
+			}
+		}
+		for k := range s.deleted {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
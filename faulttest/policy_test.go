@@ -0,0 +1,210 @@
+package faulttest
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexshd/lawtest"
+)
+
+// stateContents compares states by everything MergeWith reads: entries,
+// tombstones, times and sibling values.
+func stateContents() *contents[*State] {
+	return newContents(func(s *State) string {
+		return fmt.Sprint(s.data, slices.Sorted(maps.Keys(s.deleted)), s.meta)
+	})
+}
+
+// randomReplica builds a state of a few keys, some written or deleted at
+// one of a few times, so that conflicts and ties between replicas, writes
+// and deletions alike, are common. Values are numbers for the sum policy.
+func randomReplica() *State {
+	s := NewState(nil)
+	for range rand.IntN(5) {
+		k, v, at := fmt.Sprint("key", rand.IntN(3)), strconv.Itoa(rand.IntN(4)), time.Unix(int64(rand.IntN(3)), 0)
+		switch rand.IntN(4) {
+		case 0:
+			s = s.SetAt(k, v, at)
+		case 1:
+			s = s.Set(k, v)
+		case 2:
+			s = s.DeleteAt(k, at)
+		case 3:
+			s = s.Delete(k)
+		}
+	}
+	return s
+}
+
+// TestMergePolicies checks which laws MergeWith obeys under each policy:
+// lawtest proves those that hold, a counterexample those that don't.
+func TestMergePolicies(t *testing.T) {
+	printSection("MERGE POLICIES - Which laws does each conflict resolution obey?")
+
+	printInfo("What we're testing: associativity, commutativity and idempotence of MergeWith")
+	printInfo("Why it matters: replicas merging in different orders agree only if merge is commutative")
+	fmt.Println()
+
+	// Both custom policies keep a key deleted on both sides deleted
+	concat := Resolve("concatenate", func(_ string, left, right Entry) Entry {
+		if len(left.Values)+len(right.Values) == 0 {
+			return Entry{Time: later(left.Time, right.Time)}
+		}
+		return Entry{Values: []string{strings.Join(left.Values, "") + strings.Join(right.Values, "")}}
+	})
+	sum := Resolve("sum", func(_ string, left, right Entry) Entry {
+		if len(left.Values)+len(right.Values) == 0 {
+			return Entry{Time: later(left.Time, right.Time)}
+		}
+		total := 0
+		for _, v := range slices.Concat(left.Values, right.Values) {
+			n, _ := strconv.Atoi(v)
+			total += n
+		}
+		return Entry{Values: []string{strconv.Itoa(total)}}
+	})
+
+	tests := []struct {
+		policy                               MergePolicy
+		associative, commutative, idempotent bool
+	}{
+		{RightWins, true, false, true},
+		{LastWriterWins, true, true, true},
+		{LexicographicMax, true, true, true},
+		{MultiValue, true, true, true},
+		{concat, true, false, false},
+		{sum, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(strings.ReplaceAll(tt.policy.String(), " ", "-"), func(t *testing.T) {
+			c := stateContents()
+			gen := c.gen(randomReplica)
+			op := c.op(func(a, b *State) *State { return a.MergeWith(b, tt.policy) })
+
+			laws := []struct {
+				name  string
+				holds bool
+				check func(t *testing.T)
+				law   func(a, b, c string) bool
+			}{
+				{"associative", tt.associative,
+					func(t *testing.T) { lawtest.Associative(t, op, gen) },
+					func(a, b, c string) bool { return op(op(a, b), c) == op(a, op(b, c)) }},
+				{"commutative", tt.commutative,
+					func(t *testing.T) { lawtest.Commutative(t, op, gen) },
+					func(a, b, _ string) bool { return op(a, b) == op(b, a) }},
+				{"idempotent", tt.idempotent,
+					nil,
+					func(a, _, _ string) bool { return op(a, a) == a }},
+			}
+			var verdict []string
+			for _, law := range laws {
+				a, b, c, broken := counterexample(gen, law.law)
+				switch {
+				case law.holds && law.check != nil:
+					t.Run(law.name, law.check)
+				case law.holds && broken:
+					t.Errorf("%s: not %s, with a=%s b=%s c=%s", tt.policy, law.name, a, b, c)
+				case !law.holds && !broken:
+					t.Errorf("%s: expected not %s, found no counterexample", tt.policy, law.name)
+				}
+				if law.holds {
+					verdict = append(verdict, law.name)
+				} else {
+					verdict = append(verdict, "not "+law.name)
+				}
+			}
+			printInfo(fmt.Sprintf("%-18s %s", tt.policy, strings.Join(verdict, ", ")))
+		})
+	}
+}
+
+// counterexample looks for random values breaking law.
+func counterexample(gen lawtest.Generator[string], law func(a, b, c string) bool) (a, b, c string, found bool) {
+	for range 1000 {
+		a, b, c = gen(), gen(), gen()
+		if !law(a, b, c) {
+			return a, b, c, true
+		}
+	}
+	return "", "", "", false
+}
+
+// TestMergeWith checks each policy on a conflict, and that replicas merged
+// in different orders converge under a commutative policy only.
+func TestMergeWith(t *testing.T) {
+	printStep("Replicas", "Three replicas wrote the same key, merged in two orders")
+
+	early, late := time.Unix(1, 0), time.Unix(2, 0)
+	a := NewState(map[string]string{"only-a": "1"}).SetAt("key", "a-late", late)
+	b := NewState(nil).SetAt("key", "b-early", early)
+
+	for _, tt := range []struct {
+		policy MergePolicy
+		want   []string
+	}{
+		{RightWins, []string{"b-early"}},
+		{LastWriterWins, []string{"a-late"}},
+		{LexicographicMax, []string{"b-early"}},
+		{MultiValue, []string{"a-late", "b-early"}},
+	} {
+		merged := a.MergeWith(b, tt.policy)
+		if got := merged.Values("key"); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Values(key) = %v, want %v", tt.policy, got, tt.want)
+		}
+		if v, _ := merged.Get("key"); v != tt.want[0] {
+			t.Errorf("%s: Get(key) = %q, want %q", tt.policy, v, tt.want[0])
+		}
+		if v, _ := merged.Get("only-a"); v != "1" {
+			t.Errorf("%s: lost only-a", tt.policy)
+		}
+	}
+
+	siblings := a.MergeWith(b, MultiValue)
+	if got := siblings.Set("key", "resolved").Values("key"); !slices.Equal(got, []string{"resolved"}) {
+		t.Errorf("Set after MultiValue: Values = %v, want [resolved]", got)
+	}
+	if _, ok := a.MergeWith(b.Delete("only-a"), RightWins).Get("only-a"); ok {
+		t.Error("MergeWith lost the other state's tombstone")
+	}
+
+	// A deletion is an entry like any other: the later of a write and a
+	// deletion wins, whichever side merges into the other
+	written := NewState(nil).SetAt("key", "v", time.Unix(5, 0))
+	deleted := NewState(map[string]string{"key": "x"}).DeleteAt("key", time.Unix(3, 0))
+	for _, policy := range []MergePolicy{LastWriterWins, LexicographicMax, MultiValue} {
+		for _, merged := range []*State{written.MergeWith(deleted, policy), deleted.MergeWith(written, policy)} {
+			if v, _ := merged.Get("key"); v != "v" {
+				t.Errorf("%s: Get(key) = %q after merging a later write with a deletion, want \"v\"", policy, v)
+			}
+		}
+	}
+	deleted = deleted.DeleteAt("key", time.Unix(7, 0))
+	for _, merged := range []*State{written.MergeWith(deleted, LastWriterWins), deleted.MergeWith(written, LastWriterWins)} {
+		if _, ok := merged.Get("key"); ok {
+			t.Errorf("%s: the key is back after merging a later deletion", LastWriterWins)
+		}
+	}
+
+	replicas := []*State{a, b, NewState(nil).SetAt("key", "c-middle", time.Unix(1, 5))}
+	for _, policy := range []MergePolicy{RightWins, LastWriterWins} {
+		forward := replicas[0].MergeWith(replicas[1], policy).MergeWith(replicas[2], policy)
+		backward := replicas[2].MergeWith(replicas[1], policy).MergeWith(replicas[0], policy)
+		f, _ := forward.Get("key")
+		bw, _ := backward.Get("key")
+		switch {
+		case policy.String() == LastWriterWins.String() && f != bw:
+			t.Errorf("%s: replicas diverged, %q and %q", policy, f, bw)
+		case f == bw:
+			printSuccess(fmt.Sprintf("%s: replicas converged on %q", policy, f))
+		default:
+			printWarning(fmt.Sprintf("%s: replicas diverged, %q and %q", policy, f, bw))
+		}
+	}
+}
@@ -2,14 +2,36 @@ package faulttest
 
 import (
 	"fmt"
+	"iter"
 	"maps"
+	"slices"
 	"sync"
+	"time"
 )
 
+// Entry is the value of a key as MergeWith policies see it: its values and
+// the time it was written. A key has one value unless MultiValue merged
+// conflicting ones, none if it was deleted, and the zero time unless
+// written with SetAt or DeleteAt.
+type Entry struct {
+	Values []string // sorted, without duplicates
+	Time   time.Time
+}
+
 // State represents an immutable configuration state.
 // We use a comparable wrapper to enable property-based testing with lawtest.
 type State struct {
 	data map[string]string
+
+	// deleted holds tombstones, the keys deleted from this state. Merging
+	// the state into another deletes them there too; without tombstones the
+	// other side would bring them back.
+	deleted map[string]struct{}
+
+	// meta holds the entries written by SetAt or DeleteAt or resolved by
+	// MergeWith, those with a write time or several values. The other keys
+	// have the value in data, or none if deleted, and the zero time.
+	meta map[string]Entry
 }
 
 // NewState creates a new State with the given data.
@@ -38,22 +60,108 @@ func (s *State) Set(key, value string) *State {
 		newData[k] = v
 	}
 	newData[key] = value
-	return &State{data: newData}
+	return &State{data: newData, deleted: without(s.deleted, key), meta: without(s.meta, key)}
+}
+
+// Delete returns a new State without the key, leaving a tombstone so the
+// deletion survives Merge: a.Merge(b.Delete(k)) has no k, even if a has.
+func (s *State) Delete(key string) *State {
+	newData := make(map[string]string, len(s.data))
+	for k, v := range s.data {
+		if k != key {
+			newData[k] = v
+		}
+	}
+	newDeleted := make(map[string]struct{}, len(s.deleted)+1)
+	for k := range s.deleted {
+		newDeleted[k] = struct{}{}
+	}
+	newDeleted[key] = struct{}{}
+	return &State{data: newData, deleted: newDeleted, meta: without(s.meta, key)}
 }
 
 // Merge combines two states, with the other state's values taking precedence.
+// Keys the other state deleted are deleted from the result: a tombstone
+// takes precedence like a value does.
 func (s *State) Merge(other *State) *State {
 	if other == nil {
 		return s
 	}
 	newData := make(map[string]string, len(s.data)+len(other.data))
 	for k, v := range s.data {
-		newData[k] = v
+		if _, deleted := other.deleted[k]; !deleted {
+			newData[k] = v
+		}
 	}
 	for k, v := range other.data {
 		newData[k] = v
 	}
-	return &State{data: newData}
+	var newDeleted map[string]struct{}
+	if len(s.deleted)+len(other.deleted) > 0 {
+		newDeleted = make(map[string]struct{}, len(s.deleted)+len(other.deleted))
+		for k := range s.deleted {
+			if _, set := other.data[k]; !set {
+				newDeleted[k] = struct{}{}
+			}
+		}
+		for k := range other.deleted {
+			newDeleted[k] = struct{}{}
+		}
+	}
+	var newMeta map[string]Entry
+	if len(s.meta)+len(other.meta) > 0 {
+		newMeta = make(map[string]Entry, len(s.meta)+len(other.meta))
+		for k, e := range s.meta {
+			_, set := other.data[k]
+			_, deleted := other.deleted[k]
+			if !set && !deleted {
+				newMeta[k] = e
+			}
+		}
+		for k, e := range other.meta {
+			newMeta[k] = e
+		}
+	}
+	return &State{data: newData, deleted: newDeleted, meta: newMeta}
+}
+
+// All returns an iterator over the entries of the state in key order.
+func (s *State) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		if s == nil {
+			return
+		}
+		for _, k := range slices.Sorted(maps.Keys(s.data)) {
+			if !yield(k, s.data[k]) {
+				return
+			}
+		}
+	}
+}
+
+// without returns a copy of m without key, or m itself if it doesn't hold
+// key.
+func without[V any](m map[string]V, key string) map[string]V {
+	if _, ok := m[key]; !ok {
+		return m
+	}
+	newMap := make(map[string]V, len(m)-1)
+	for k, v := range m {
+		if k != key {
+			newMap[k] = v
+		}
+	}
+	return newMap
+}
+
+// withEntry returns a copy of m with e as the entry of key.
+func withEntry(m map[string]Entry, key string, e Entry) map[string]Entry {
+	newMap := make(map[string]Entry, len(m)+1)
+	for k, v := range m {
+		newMap[k] = v
+	}
+	newMap[key] = e
+	return newMap
 }
 
 // Len returns the number of entries in the state.
@@ -64,11 +172,15 @@ func (s *State) Len() int {
 	return len(s.data)
 }
 
-// String implements fmt.Stringer for debugging.
+// String implements fmt.Stringer for debugging, listing tombstones after
+// the entries.
 func (s *State) String() string {
 	if s == nil || s.data == nil {
 		return "{}"
 	}
+	if len(s.deleted) > 0 {
+		return fmt.Sprintf("%v deleted %v", s.data, slices.Sorted(maps.Keys(s.deleted)))
+	}
 	return fmt.Sprintf("%v", s.data)
 }
 
@@ -201,6 +313,13 @@ type ImmutableStore interface {
 	// MUST be associative: (a+b)+c = a+(b+c)
 	Merge(other ImmutableStore) ImmutableStore
 
+	// Delete returns a NEW store without the key
+	// The deletion MUST survive Merge: a.Merge(b.Delete(k)) has no k
+	Delete(key string) ImmutableStore
+
+	// All iterates over the entries in key order
+	All() iter.Seq2[string, string]
+
 	// Len returns the number of entries
 	Len() int
 }
@@ -233,9 +352,21 @@ func (sw *StateWrapper) Merge(other ImmutableStore) ImmutableStore {
 	return &StateWrapper{state: merged}
 }
 
+func (sw *StateWrapper) Delete(key string) ImmutableStore {
+	return &StateWrapper{state: sw.state.Delete(key)}
+}
+
+func (sw *StateWrapper) All() iter.Seq2[string, string] {
+	return sw.state.All()
+}
+
 func (sw *StateWrapper) Len() int {
 	return sw.state.Len()
 }
 
+func (sw *StateWrapper) String() string {
+	return sw.state.String()
+}
+
 // Ensure StateWrapper implements ImmutableStore at compile time
 var _ ImmutableStore = (*StateWrapper)(nil)
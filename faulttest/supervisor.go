@@ -0,0 +1,378 @@
+package faulttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Strategy decides which children a Supervisor restarts when one fails.
+// These are the Erlang/OTP strategies: the supervisor contains the failure
+// to the children that depend on each other, and no further.
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child. Use it when children are
+	// independent.
+	OneForOne Strategy = iota
+
+	// OneForAll restarts every child when one fails. Use it when children
+	// can't work without each other.
+	OneForAll
+
+	// RestForOne restarts the failed child and those started after it,
+	// which depend on it.
+	RestForOne
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one-for-one"
+	case OneForAll:
+		return "one-for-all"
+	case RestForOne:
+		return "rest-for-one"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// RestartPolicy decides whether a child that exited is restarted.
+type RestartPolicy int
+
+const (
+	// Permanent children are always restarted.
+	Permanent RestartPolicy = iota
+
+	// Transient children are restarted only after a panic or an error.
+	Transient
+
+	// Temporary children are never restarted, not even with their
+	// siblings.
+	Temporary
+)
+
+func (p RestartPolicy) restarts(err error) bool {
+	switch p {
+	case Permanent:
+		return true
+	case Transient:
+		return err != nil
+	}
+	return false
+}
+
+// ChildSpec describes a child of a Supervisor.
+type ChildSpec struct {
+	// Name identifies the child in restarts and errors.
+	Name string
+
+	// Run does the child's work until ctx is done. It must return soon
+	// after, since the supervisor waits for it before restarting siblings.
+	// A panic is recovered and, like a returned error, counts as a failure.
+	//
+	// Run reads its state from child.State() and publishes each new state
+	// with child.Commit. After a restart, child.State() is the last
+	// committed snapshot: whatever the failed run did without committing
+	// is gone.
+	Run func(ctx context.Context, child *Child) error
+
+	// Init is the state of the first start, an empty store if nil.
+	Init ImmutableStore
+
+	// Restart is the restart policy, Permanent by default.
+	Restart RestartPolicy
+}
+
+// Child is the handle a child runs with. It survives restarts and keeps
+// the child's last committed snapshot.
+type Child struct {
+	name string
+
+	mu     sync.Mutex
+	state  ImmutableStore
+	starts int
+}
+
+// Name returns the name of the child's spec.
+func (c *Child) Name() string {
+	return c.name
+}
+
+// State returns the last committed snapshot.
+func (c *Child) State() ImmutableStore {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Commit publishes state as the snapshot restarts restore. Since stores are
+// immutable, a snapshot can't be corrupted by the run that committed it
+// failing later.
+func (c *Child) Commit(state ImmutableStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+}
+
+// Starts returns how many times the child was started, 1 + its restarts.
+func (c *Child) Starts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.starts
+}
+
+// PanicError is the failure of a child that panicked.
+type PanicError struct {
+	Child string
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Child, e.Value)
+}
+
+// ErrMaxRestarts is returned by Run when children fail faster than the
+// supervisor's intensity allows.
+var ErrMaxRestarts = errors.New("restart intensity exceeded")
+
+// Restart records a restart done by a Supervisor.
+type Restart struct {
+	Child     string   // the child that exited
+	Reason    error    // why, nil if it exited normally
+	Restarted []string // the children restarted, in start order
+	Time      time.Time
+}
+
+// Supervisor runs children and restarts them when they fail, implementing
+// Law II (Preemptive Supervision): a failure is caught, contained to the
+// children the strategy names, and repaired from immutable snapshots.
+//
+// A Supervisor is itself a child through Spec, so supervisors form trees:
+// when one gives up, its parent restarts it.
+type Supervisor struct {
+	// MaxRestarts restarts are allowed within Period. One more means the
+	// failure isn't transient: the supervisor stops its children and Run
+	// returns ErrMaxRestarts.
+	MaxRestarts int
+	Period      time.Duration
+
+	strategy Strategy
+	specs    []ChildSpec
+	children []*Child
+
+	mu       sync.Mutex
+	restarts []Restart
+}
+
+// NewSupervisor creates a supervisor of specs, started in order, allowing
+// 3 restarts in 5 seconds.
+func NewSupervisor(strategy Strategy, specs ...ChildSpec) *Supervisor {
+	s := &Supervisor{
+		MaxRestarts: 3,
+		Period:      5 * time.Second,
+		strategy:    strategy,
+		specs:       specs,
+	}
+	for _, spec := range specs {
+		init := spec.Init
+		if init == nil {
+			init = NewStateWrapper(nil)
+		}
+		s.children = append(s.children, &Child{name: spec.Name, state: init})
+	}
+	return s
+}
+
+// Child returns the handle of the named child, or nil.
+func (s *Supervisor) Child(name string) *Child {
+	for _, c := range s.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Restarts returns the restarts done so far.
+func (s *Supervisor) Restarts() []Restart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.restarts)
+}
+
+// Spec returns a spec running s as the child of another supervisor.
+func (s *Supervisor) Spec(name string) ChildSpec {
+	return ChildSpec{
+		Name: name,
+		Run: func(ctx context.Context, _ *Child) error {
+			return s.Run(ctx)
+		},
+	}
+}
+
+// exit is a child's run ending.
+type exit struct {
+	i   int
+	err error
+}
+
+// supervision is the state of one Run.
+type supervision struct {
+	*Supervisor
+	ctx     context.Context
+	exits   chan exit
+	cancels []context.CancelFunc
+	running []bool
+	pending []exit // exits received while stopping other children
+	window  []time.Time
+}
+
+// Run starts the children and supervises them until ctx is done, when it
+// stops them and returns nil, or until the restart intensity is exceeded.
+// It also returns nil once every child has exited for good. Run must not
+// be called again before it returns.
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r := &supervision{
+		Supervisor: s,
+		ctx:        ctx,
+		exits:      make(chan exit, len(s.specs)),
+		cancels:    make([]context.CancelFunc, len(s.specs)),
+		running:    make([]bool, len(s.specs)),
+	}
+	for i := range s.specs {
+		r.start(i)
+	}
+
+	for {
+		var e exit
+		switch {
+		case len(r.pending) > 0:
+			e, r.pending = r.pending[0], r.pending[1:]
+		case !slices.Contains(r.running, true):
+			return nil
+		default:
+			select {
+			case e = <-r.exits:
+			case <-ctx.Done():
+				r.stop(r.all())
+				return nil
+			}
+		}
+		r.running[e.i] = false
+		if ctx.Err() != nil {
+			r.stop(r.all())
+			return nil
+		}
+		if !s.specs[e.i].Restart.restarts(e.err) {
+			continue
+		}
+
+		now := time.Now()
+		r.window = slices.DeleteFunc(r.window, func(t time.Time) bool {
+			return now.Sub(t) > s.Period
+		})
+		if len(r.window) >= s.MaxRestarts {
+			r.stop(r.all())
+			return fmt.Errorf("%w: %d restarts in %v, last %s: %v", ErrMaxRestarts, len(r.window)+1, s.Period, s.specs[e.i].Name, e.err)
+		}
+		r.window = append(r.window, now)
+		r.restart(e, now)
+	}
+}
+
+// restart restarts the child of e and the siblings the strategy names.
+func (r *supervision) restart(e exit, now time.Time) {
+	var group []int
+	switch r.strategy {
+	case OneForOne:
+		group = []int{e.i}
+	case OneForAll:
+		group = r.all()
+	case RestForOne:
+		for i := e.i; i < len(r.specs); i++ {
+			group = append(group, i)
+		}
+	}
+
+	siblings := slices.DeleteFunc(slices.Clone(group), func(i int) bool {
+		return i == e.i || !r.running[i]
+	})
+	r.stop(siblings)
+
+	rec := Restart{Child: r.specs[e.i].Name, Reason: e.err, Time: now}
+	for _, i := range group {
+		if i == e.i || (slices.Contains(siblings, i) && r.specs[i].Restart != Temporary) {
+			r.start(i)
+			rec.Restarted = append(rec.Restarted, r.specs[i].Name)
+		}
+	}
+	r.mu.Lock()
+	r.restarts = append(r.restarts, rec)
+	r.mu.Unlock()
+}
+
+func (r *supervision) all() []int {
+	all := make([]int, len(r.specs))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// start runs child i in its own goroutine, reporting its exit.
+func (r *supervision) start(i int) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.cancels[i] = cancel
+	r.running[i] = true
+	spec, child := r.specs[i], r.children[i]
+	child.mu.Lock()
+	child.starts++
+	child.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		var err error
+		ok, value := IsolatedOperation(func() {
+			err = spec.Run(ctx, child)
+		})
+		if !ok {
+			err = &PanicError{Child: spec.Name, Value: value}
+		}
+		r.exits <- exit{i, err}
+	}()
+}
+
+// stop cancels the running children among group, in reverse start order,
+// and waits for them to exit. Exits of other children arriving meanwhile
+// are kept for the main loop.
+func (r *supervision) stop(group []int) {
+	waiting := 0
+	for _, i := range slices.Backward(group) {
+		if !r.running[i] {
+			continue
+		}
+		if j := slices.IndexFunc(r.pending, func(e exit) bool { return e.i == i }); j >= 0 {
+			// It exited on its own already
+			r.pending = slices.Delete(r.pending, j, j+1)
+			r.running[i] = false
+			continue
+		}
+		r.cancels[i]()
+		waiting++
+	}
+	for waiting > 0 {
+		e := <-r.exits
+		if slices.Contains(group, e.i) && r.running[e.i] {
+			r.running[e.i] = false
+			waiting--
+			continue
+		}
+		r.pending = append(r.pending, e)
+	}
+}
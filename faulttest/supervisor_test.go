@@ -0,0 +1,253 @@
+package faulttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// worker is a child that commits its count of runs, panics on the runs
+// listed in fail, and otherwise waits to be stopped. Each start is sent on
+// started.
+func worker(name string, started chan<- string, fail ...int) ChildSpec {
+	return ChildSpec{
+		Name: name,
+		Run: func(ctx context.Context, child *Child) error {
+			run := child.Starts()
+			child.Commit(child.State().Set("runs", fmt.Sprint(run)))
+			started <- name
+			if slices.Contains(fail, run) {
+				panic(fmt.Sprintf("%s: crash on run %d", name, run))
+			}
+			<-ctx.Done()
+			return nil
+		},
+	}
+}
+
+// await receives n starts, failing the test if they don't come.
+func await(t *testing.T, started <-chan string, n int) []string {
+	t.Helper()
+	var names []string
+	for range n {
+		select {
+		case name := <-started:
+			names = append(names, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("waited for %d starts, got %v", n, names)
+		}
+	}
+	return names
+}
+
+// supervise runs s in the background, returning a func stopping it and
+// returning the error of Run.
+func supervise(s *Supervisor) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+// TestSupervisorStrategies crashes the middle of three children under each
+// strategy and checks which ones are restarted.
+func TestSupervisorStrategies(t *testing.T) {
+	printSection("LAW II - SUPERVISION TREES")
+
+	printInfo("What we're testing: a crash restarts exactly the children that depend on it")
+	printInfo("Why it matters: restarting too little leaves broken peers, too much spreads the failure")
+	fmt.Println()
+
+	tests := []struct {
+		strategy Strategy
+		starts   map[string]int
+	}{
+		{OneForOne, map[string]int{"a": 1, "b": 2, "c": 1}},
+		{OneForAll, map[string]int{"a": 2, "b": 2, "c": 2}},
+		{RestForOne, map[string]int{"a": 1, "b": 2, "c": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			printStep(tt.strategy.String(), "b crashes on its first run")
+
+			started := make(chan string, 10)
+			s := NewSupervisor(tt.strategy,
+				worker("a", started),
+				worker("b", started, 1),
+				worker("c", started),
+			)
+			stop := supervise(s)
+			restarted := 0
+			for _, n := range tt.starts {
+				restarted += n - 1
+			}
+			await(t, started, 3+restarted)
+			if err := stop(); err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+
+			for name, want := range tt.starts {
+				if got := s.Child(name).Starts(); got != want {
+					t.Errorf("%s started %d times, want %d", name, got, want)
+				}
+			}
+			restarts := s.Restarts()
+			if len(restarts) != 1 {
+				t.Fatalf("Restarts() = %v, want 1 restart", restarts)
+			}
+			var pe *PanicError
+			if !errors.As(restarts[0].Reason, &pe) || pe.Child != "b" {
+				t.Errorf("restart reason = %v, want b's panic", restarts[0].Reason)
+			}
+			printSuccess(fmt.Sprintf("restarted %v after: %v", restarts[0].Restarted, restarts[0].Reason))
+		})
+	}
+}
+
+// TestSupervisorRestoresSnapshot checks that a restarted child sees its
+// last committed state, not what it wrote before crashing.
+func TestSupervisorRestoresSnapshot(t *testing.T) {
+	printStep("Snapshot", "A child crashes between building its next state and committing it")
+
+	started := make(chan string, 10)
+	s := NewSupervisor(OneForOne, ChildSpec{
+		Name: "counter",
+		Init: NewStateWrapper(map[string]string{"count": "0"}),
+		Run: func(ctx context.Context, child *Child) error {
+			state := child.State()
+			count, _ := state.Get("count")
+			started <- count
+			if child.Starts() == 1 {
+				child.Commit(state.Set("count", "1"))
+				partial := child.State().Set("count", "2_PARTIAL")
+				panic(fmt.Sprintf("crash before committing %d entries", partial.Len()))
+			}
+			<-ctx.Done()
+			return nil
+		},
+	})
+	stop := supervise(s)
+	got := await(t, started, 2)
+	if err := stop(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	if want := []string{"0", "1"}; !slices.Equal(got, want) {
+		t.Errorf("runs saw counts %v, want %v", got, want)
+	}
+	if v, _ := s.Child("counter").State().Get("count"); v != "1" {
+		t.Errorf("final count = %q, want the committed 1", v)
+	}
+	printSuccess("Restarted from the last committed snapshot, the partial write is gone")
+}
+
+// TestSupervisorIntensity checks that a child failing on every run makes
+// the supervisor give up, and its parent restart it.
+func TestSupervisorIntensity(t *testing.T) {
+	printStep("Intensity", "A child that crashes on every run")
+
+	t.Run("GivesUp", func(t *testing.T) {
+		started := make(chan string, 10)
+		s := NewSupervisor(OneForOne, worker("flaky", started, 1, 2, 3, 4, 5))
+		s.MaxRestarts = 2
+		s.Period = time.Minute
+
+		err := s.Run(context.Background())
+		if !errors.Is(err, ErrMaxRestarts) {
+			t.Fatalf("Run() = %v, want ErrMaxRestarts", err)
+		}
+		if got := s.Child("flaky").Starts(); got != 3 {
+			t.Errorf("flaky started %d times, want 3", got)
+		}
+		printWarning(err.Error())
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		// Two failures further apart than Period never add up
+		started := make(chan string, 10)
+		spec := worker("slow", started)
+		run := spec.Run
+		spec.Run = func(ctx context.Context, child *Child) error {
+			if child.Starts() <= 3 {
+				time.Sleep(20 * time.Millisecond)
+				return errors.New("failed")
+			}
+			return run(ctx, child)
+		}
+		s := NewSupervisor(OneForOne, spec)
+		s.MaxRestarts = 1
+		s.Period = 10 * time.Millisecond
+
+		stop := supervise(s)
+		await(t, started, 1)
+		if err := stop(); err != nil {
+			t.Fatalf("Run() = %v", err)
+		}
+		if got := len(s.Restarts()); got != 3 {
+			t.Errorf("%d restarts, want 3", got)
+		}
+	})
+
+	t.Run("Tree", func(t *testing.T) {
+		started := make(chan string, 20)
+		inner := NewSupervisor(OneForOne, worker("flaky", started, 1, 2, 3))
+		inner.MaxRestarts = 1
+		inner.Period = time.Minute
+		outer := NewSupervisor(OneForOne, inner.Spec("inner"), worker("peer", started))
+
+		stop := supervise(outer)
+		// flaky fails twice, inner gives up, outer restarts it, flaky fails
+		// once more and then runs
+		await(t, started, 5)
+		if err := stop(); err != nil {
+			t.Fatalf("Run() = %v", err)
+		}
+
+		restarts := outer.Restarts()
+		if len(restarts) != 1 || !errors.Is(restarts[0].Reason, ErrMaxRestarts) {
+			t.Fatalf("outer restarts = %v, want inner restarted once", restarts)
+		}
+		if got := outer.Child("peer").Starts(); got != 1 {
+			t.Errorf("peer started %d times, want 1", got)
+		}
+		if got := inner.Child("flaky").Starts(); got != 4 {
+			t.Errorf("flaky started %d times, want 4", got)
+		}
+		printSuccess("The failure escalated one level and stopped there")
+	})
+}
+
+// TestSupervisorRestartPolicies checks which exits restart a child.
+func TestSupervisorRestartPolicies(t *testing.T) {
+	s := NewSupervisor(OneForAll,
+		ChildSpec{Name: "transient", Restart: Transient, Run: func(ctx context.Context, child *Child) error {
+			if child.Starts() == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		}},
+		ChildSpec{Name: "temporary", Restart: Temporary, Run: func(context.Context, *Child) error {
+			return errors.New("failed")
+		}},
+	)
+
+	// transient fails, restarting with it the running siblings that
+	// aren't temporary; then everything has exited for good.
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if got := s.Child("transient").Starts(); got != 2 {
+		t.Errorf("transient started %d times, want 2", got)
+	}
+	if got := s.Child("temporary").Starts(); got != 1 {
+		t.Errorf("temporary started %d times, want 1", got)
+	}
+}
@@ -0,0 +1,42 @@
+package faulttest
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Transact applies update to state all or nothing. update writes to a
+// draft copy of state.Config; only if it returns nil does the draft replace
+// Config, in a single assignment under the lock. An error or a panic in
+// update discards the draft, so the partial writes MutateAndPanic leaves
+// behind never reach the shared state.
+//
+// A panic is recovered and returned as an error, wrapping the panic value
+// if it is one: the lock is released and the state is as it was, so there
+// is nothing left for a supervisor to repair.
+//
+// This keeps CriticalState's API while giving its updates the guarantee
+// SafeUpdate gets from immutability. Readers must take the lock, as before.
+func Transact(state *CriticalState, update func(draft map[string]string) error) (err error) {
+	state.Lock.Lock()
+	defer state.Lock.Unlock()
+
+	// Copy-on-write: the shared map is never written, only replaced
+	draft := make(map[string]string, len(state.Config))
+	maps.Copy(draft, state.Config)
+
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case error:
+			err = fmt.Errorf("transaction rolled back: panic: %w", r)
+		default:
+			err = fmt.Errorf("transaction rolled back: panic: %v", r)
+		}
+	}()
+	if err := update(draft); err != nil {
+		return fmt.Errorf("transaction rolled back: %w", err)
+	}
+	state.Config = draft
+	return nil
+}
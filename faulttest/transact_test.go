@@ -0,0 +1,153 @@
+package faulttest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+)
+
+// TestTransact replays the MutateAndPanic scenario inside a transaction.
+func TestTransact(t *testing.T) {
+	printSection("TRANSACTIONS - All-or-nothing updates of CriticalState")
+
+	printInfo("What we're testing: a crash mid-update leaves the shared state untouched")
+	printInfo("How: updates write to a draft copy, published only on success")
+	fmt.Println()
+
+	t.Run("MutateAndPanic", func(t *testing.T) {
+		printStep("Experiment 1", "The MutateAndPanic crash, inside Transact")
+
+		critical := NewCriticalState()
+		critical.Config["existing"] = "value"
+
+		err := Transact(critical, func(draft map[string]string) error {
+			draft["key1"] = "value_corrupt_PARTIAL"
+			panic("Simulated unexpected failure: Logic error in critical section")
+		})
+		if err == nil {
+			t.Fatal("Transact() = nil after a panic")
+		}
+		printInfo(fmt.Sprintf("Transact returned: %v", err))
+
+		// The lock was released, or this deadlocks
+		critical.Lock.Lock()
+		defer critical.Lock.Unlock()
+		if want := map[string]string{"existing": "value"}; !maps.Equal(critical.Config, want) {
+			printFailure(fmt.Sprintf("Found corrupted state: %v", critical.Config))
+			t.Fatalf("Config = %v, want %v", critical.Config, want)
+		}
+		printSuccess("State is untouched - the partial write never left the draft")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		critical := NewCriticalState()
+		errInvalid := errors.New("invalid value")
+
+		err := Transact(critical, func(draft map[string]string) error {
+			draft["key"] = "value"
+			return errInvalid
+		})
+		if !errors.Is(err, errInvalid) {
+			t.Errorf("Transact() = %v, want %v", err, errInvalid)
+		}
+		if len(critical.Config) != 0 {
+			t.Errorf("Config = %v after a failed transaction", critical.Config)
+		}
+	})
+
+	t.Run("PanicWithError", func(t *testing.T) {
+		critical := NewCriticalState()
+		errCorrupt := errors.New("corrupt draft")
+
+		err := Transact(critical, func(draft map[string]string) error {
+			panic(fmt.Errorf("writing key: %w", errCorrupt))
+		})
+		if !errors.Is(err, errCorrupt) {
+			t.Errorf("Transact() = %v, want it to wrap %v", err, errCorrupt)
+		}
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		critical := NewCriticalState()
+		before := critical.Config
+
+		err := Transact(critical, func(draft map[string]string) error {
+			draft["key1"] = "value1"
+			draft["key2"] = "value2"
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Transact() = %v", err)
+		}
+		if want := map[string]string{"key1": "value1", "key2": "value2"}; !maps.Equal(critical.Config, want) {
+			t.Errorf("Config = %v, want %v", critical.Config, want)
+		}
+		if len(before) != 0 {
+			t.Errorf("the previous Config was written to: %v", before)
+		}
+	})
+
+	t.Run("EveryInjectionPoint", func(t *testing.T) {
+		printStep("Experiment 2", "Crashing the transaction at every write")
+
+		target := criticalTarget()
+		target.Calls = 2
+		target.Op = func(state *CriticalState, faults *Faults) {
+			err := Transact(state, func(draft map[string]string) error {
+				faults.Write(func() { draft["key"] = "value_PARTIAL" })
+				faults.Write(func() { draft["key"] = "value" })
+				return nil
+			})
+			if err != nil {
+				// Report the failed call to Inject, which checks the state
+				panic(err)
+			}
+		}
+		report := Inject(target, BeforeWrite(1), AfterWrite(1), BeforeWrite(2), AfterWrite(3), OnCall(2))
+		printInfo(report.String())
+
+		for _, res := range report.Results {
+			if res.Panic == nil {
+				t.Errorf("%s: the fault didn't fail the transaction", res.Point)
+			}
+		}
+		if unsafe := report.Unsafe(); len(unsafe) > 0 {
+			t.Errorf("Transact let faults through: %s", report.Summary())
+		}
+		printSuccess(report.Summary())
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		printStep("Experiment 3", "10 transactions at once, half of them crashing")
+
+		critical := NewCriticalState()
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Go(func() {
+				Transact(critical, func(draft map[string]string) error {
+					draft[fmt.Sprint("key", i)] = "value_PARTIAL"
+					if i%2 == 1 {
+						panic("crash")
+					}
+					draft[fmt.Sprint("key", i)] = "value"
+					return nil
+				})
+			})
+		}
+		wg.Wait()
+
+		critical.Lock.Lock()
+		defer critical.Lock.Unlock()
+		if len(critical.Config) != 5 {
+			t.Errorf("Config = %v, want the 5 committed keys", critical.Config)
+		}
+		for k, v := range critical.Config {
+			if v != "value" {
+				t.Errorf("%s = %q, a partial write persisted", k, v)
+			}
+		}
+		printSuccess(fmt.Sprintf("%d commits, 0 partial writes", len(critical.Config)))
+	})
+}
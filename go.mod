@@ -2,4 +2,10 @@ module github.com/alexshd/beacon
 
 go 1.25.3
 
-require github.com/alexshd/lawtest v0.1.0
+require (
+	github.com/alexshd/lawtest v0.1.0
+	golang.org/x/mod v0.31.0
+	golang.org/x/tools v0.40.0
+)
+
+require golang.org/x/sync v0.19.0 // indirect
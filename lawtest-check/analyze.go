@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// operation is what source analysis found out about a function or method.
+type operation struct {
+	Name      string // Merge, or (*State).Merge for methods
+	Signature string // as declared, e.g. func (s *State) Merge(other *State) *State
+	Pos       token.Position
+	Recv      string // receiver type name of methods, such as State
+	Generic   bool   // the function or its receiver type has type parameters
+
+	Binary     bool       // (T, T) -> T, counting the receiver
+	Type       types.Type // T, the result type, nil unless there is one result
+	TypeName   string     // T relative to its package
+	Comparable bool       // T supports ==
+	Wrappable  bool       // T can be compared by content through a wrapper
+
+	Purity purity // side effects of the operation and what it calls
+
+	fn     *types.Func
+	module *packages.Module
+}
+
+// finding is an answer to a question found by analysis, with its reason.
+type finding struct {
+	key    string
+	answer answer
+	note   string
+}
+
+// findings answers the questions analysis can decide: the signature, the
+// comparability of the type and whether the operation is pure. Whether it
+// should be associative or is meant for concurrent code is left to people.
+func (op *operation) findings() []finding {
+	var result []finding
+	if op.Binary {
+		result = append(result, finding{"signature", yes, op.Signature})
+	} else {
+		result = append(result, finding{"signature", no, op.Signature + " is not (T, T) -> T"})
+	}
+
+	if op.Type != nil {
+		_, pointer := op.Type.Underlying().(*types.Pointer)
+		switch t := op.TypeName; {
+		case op.Comparable && pointer && op.Wrappable:
+			result = append(result, finding{"comparable", yes, t + " compares by identity, compare what it points to through a wrapper"})
+		case op.Comparable:
+			result = append(result, finding{"comparable", yes, t + " is comparable"})
+		case op.Wrappable:
+			result = append(result, finding{"comparable", yes, t + " is not comparable, test it through a wrapper"})
+		default:
+			result = append(result, finding{"comparable", no, t + " holds funcs or channels, which can't be compared"})
+		}
+	}
+
+	if op.Purity.Pure() {
+		result = append(result, finding{"pure", yes, op.Purity.Verdict()})
+	} else {
+		note := op.Purity.Effects[0].String()
+		if more := len(op.Purity.Effects) - 1; more > 0 {
+			note += fmt.Sprintf(" and %d more", more)
+		}
+		result = append(result, finding{"pure", no, note})
+	}
+	return result
+}
+
+// analyzeOperation loads the package matching pattern and analyzes the
+// function or method called name in it. Without a name, the package must
+// declare exactly one (T, T) -> T operation.
+func analyzeOperation(pattern, name string) (*operation, error) {
+	pkg, err := loadPackage(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return findOperation(pkg, name)
+}
+
+// loadPackage loads the single package matching pattern, with the syntax
+// of its dependencies for the purity check.
+func loadPackage(pattern string) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedModule |
+			packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo,
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("%s does not type-check", pattern)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s matches %d packages, name a single one", pattern, len(pkgs))
+	}
+	return pkgs[0], nil
+}
+
+// findOperation analyzes the operation called name in pkg.
+func findOperation(pkg *packages.Package, name string) (*operation, error) {
+	var matches, binary []*operation
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			op := newOperation(pkg, fn)
+			switch {
+			case name != "" && matchesName(fn, name):
+				matches = append(matches, op)
+			case name == "" && op.Binary:
+				binary = append(binary, op)
+			}
+		}
+	}
+
+	if name == "" {
+		switch len(binary) {
+		case 0:
+			return nil, fmt.Errorf("%s declares no (T, T) -> T operation, name one with -func", pkg.PkgPath)
+		case 1:
+			binary[0].Purity = checkPurity(pkg, binary[0].fn)
+			return binary[0], nil
+		}
+		return nil, fmt.Errorf("%s declares several (T, T) -> T operations, choose one with -func: %s", pkg.PkgPath, names(binary))
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%s declares no function or method %s", pkg.PkgPath, name)
+	case 1:
+		matches[0].Purity = checkPurity(pkg, matches[0].fn)
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("%s is ambiguous in %s, qualify it with the type: %s", name, pkg.PkgPath, names(matches))
+}
+
+func newOperation(pkg *packages.Package, fn *ast.FuncDecl) *operation {
+	obj := pkg.TypesInfo.Defs[fn.Name].(*types.Func)
+	sig := obj.Type().(*types.Signature)
+
+	op := &operation{
+		Name:      fn.Name.Name,
+		Signature: declaration(pkg.Fset, fn),
+		Pos:       pkg.Fset.Position(fn.Pos()),
+		fn:        obj,
+		module:    pkg.Module,
+	}
+	if recv := sig.Recv(); recv != nil {
+		op.Name = "(" + types.TypeString(recv.Type(), types.RelativeTo(pkg.Types)) + ")." + op.Name
+		op.Recv = receiverName(fn.Recv.List[0].Type)
+	}
+	op.Generic = sig.TypeParams().Len() > 0 || sig.RecvTypeParams().Len() > 0
+
+	var operands []types.Type
+	if sig.Recv() != nil {
+		operands = append(operands, sig.Recv().Type())
+	}
+	for v := range sig.Params().Variables() {
+		operands = append(operands, v.Type())
+	}
+	if sig.Results().Len() == 1 {
+		t := sig.Results().At(0).Type()
+		op.Type = t
+		op.TypeName = types.TypeString(t, types.RelativeTo(pkg.Types))
+		op.Comparable = types.Comparable(t)
+		op.Wrappable = wrappable(t, make(map[types.Type]bool))
+		op.Binary = len(operands) == 2 && !sig.Variadic() &&
+			types.Identical(operands[0], t) && types.Identical(operands[1], t)
+	}
+	return op
+}
+
+// matchesName reports whether fn is the function or method name, where
+// methods may be qualified by their receiver type, as in State.Merge.
+func matchesName(fn *ast.FuncDecl, name string) bool {
+	typeName, method, qualified := strings.Cut(name, ".")
+	if !qualified {
+		return fn.Name.Name == name
+	}
+	if fn.Recv == nil || fn.Name.Name != method {
+		return false
+	}
+	return receiverName(fn.Recv.List[0].Type) == strings.Trim(typeName, "(*)")
+}
+
+// receiverName returns the base type name of a receiver type expression.
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.IndexListExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+// declaration renders the signature of fn as written, without its body.
+func declaration(fset *token.FileSet, fn *ast.FuncDecl) string {
+	decl := *fn
+	decl.Body = nil
+	decl.Doc = nil
+	return render(fset, &decl)
+}
+
+// render formats node as Go source.
+func render(fset *token.FileSet, node any) string {
+	var sb strings.Builder
+	if err := format.Node(&sb, fset, node); err != nil {
+		return "?"
+	}
+	return sb.String()
+}
+
+func names(ops []*operation) string {
+	s := make([]string, len(ops))
+	for i, op := range ops {
+		s[i] = op.Name
+	}
+	return strings.Join(s, ", ")
+}
+
+// wrappable reports whether values of t can be compared by content, as
+// reflect.DeepEqual does for the wrappers lawtest-gen generates: anything
+// but funcs and channels.
+func wrappable(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return true
+	}
+	seen[t] = true
+	switch u := t.Underlying().(type) {
+	case *types.Signature, *types.Chan:
+		return false
+	case *types.Pointer:
+		return wrappable(u.Elem(), seen)
+	case *types.Slice:
+		return wrappable(u.Elem(), seen)
+	case *types.Array:
+		return wrappable(u.Elem(), seen)
+	case *types.Map:
+		return wrappable(u.Key(), seen) && wrappable(u.Elem(), seen)
+	case *types.Struct:
+		for f := range u.Fields() {
+			if !wrappable(f.Type(), seen) {
+				return false
+			}
+		}
+	}
+	return true
+}
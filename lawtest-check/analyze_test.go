@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+var loadOps = sync.OnceValues(func() (*packages.Package, error) {
+	return loadPackage("./testdata/ops")
+})
+
+func analyzeOp(t *testing.T, name string) (*operation, error) {
+	t.Helper()
+	pkg, err := loadOps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return findOperation(pkg, name)
+}
+
+func TestAnalyzeOperation(t *testing.T) {
+	tests := []struct {
+		name       string
+		signature  answer
+		comparable answer
+		pure       answer
+	}{
+		{"Merge", yes, yes, yes},
+		{"Counter.Add", yes, yes, no},
+		{"Label", no, yes, no},
+		{"Join", yes, no, no},
+	}
+	for _, tt := range tests {
+		op, err := analyzeOp(t, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]answer{"signature": tt.signature, "comparable": tt.comparable, "pure": tt.pure}
+		for _, f := range op.findings() {
+			if f.answer != want[f.key] {
+				t.Errorf("%s: expected %s=%s, got %s (%s)", tt.name, f.key, want[f.key], f.answer, f.note)
+			}
+			delete(want, f.key)
+		}
+		if len(want) > 0 {
+			t.Errorf("%s: no findings for %v", tt.name, want)
+		}
+	}
+}
+
+func TestCheckPurity(t *testing.T) {
+	tests := []struct {
+		name    string
+		verdict string
+		effects []string
+		visited int
+	}{
+		{"Merge", "pure", nil, 1},
+		{"Scale", "pure", nil, 2},
+		{"Counter.Add", "impure, 1 side effect", []string{"global write: writes ops.merges"}, 1},
+		{"Label", "impure, 2 side effects", []string{"logging: log.Printf()", "time: time.Now()"}, 1},
+		{"Join", "impure, 2 side effects", []string{"channel: send on a.ch", "channel: receive from b.ch"}, 1},
+		{"Sum", "impure, 3 side effects", []string{
+			"global write: writes ops.merges via ops.count → ops.record",
+			"randomness: rand.IntN() via ops.count → ops.record",
+			"I/O: fmt.Println() via ops.count → ops.record",
+		}, 3},
+		{"Combine", "pure as far as static calls go, 1 dynamic calls not followed", nil, 1},
+	}
+	for _, tt := range tests {
+		op, err := analyzeOp(t, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := op.Purity
+		if p.Verdict() != tt.verdict || p.Visited != tt.visited {
+			t.Errorf("%s: expected %q after %d functions, got %q after %d", tt.name, tt.verdict, tt.visited, p.Verdict(), p.Visited)
+		}
+		var effects []string
+		for _, e := range p.Effects {
+			s := e.Kind + ": " + e.What
+			if len(e.Via) > 0 {
+				s += " via " + strings.Join(e.Via, " → ")
+			}
+			effects = append(effects, s)
+		}
+		if strings.Join(effects, "; ") != strings.Join(tt.effects, "; ") {
+			t.Errorf("%s: expected effects %q, got %q", tt.name, tt.effects, effects)
+		}
+	}
+}
+
+func TestAnalyzeOperationName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // operation, or the start of the error
+	}{
+		{"", "several (T, T) -> T operations"},
+		{"Add", "(Counter).Add"},
+		{"(Counter).Add", "(Counter).Add"},
+		{"Config.Add", "declares no function or method"},
+		{"Missing", "declares no function or method"},
+	}
+	for _, tt := range tests {
+		op, err := analyzeOp(t, tt.name)
+		var got string
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = op.Name
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
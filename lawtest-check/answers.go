@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readAnswersFile reads answers from a YAML file mapping question keys to
+// yes or no:
+//
+//	# Merge in config-merge-example
+//	signature: yes
+//	order-matters: no
+//
+// Only this flat form is supported, which is all the checklist needs.
+func readAnswersFile(filename string) (map[string]answer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	answers, err := parseAnswers(bufio.NewScanner(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", filename, err)
+	}
+	return answers, nil
+}
+
+func parseAnswers(scanner *bufio.Scanner) (map[string]answer, error) {
+	answers := make(map[string]answer)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i == 0 || i > 0 && (text[i-1] == ' ' || text[i-1] == '\t') {
+			text = text[:i]
+		}
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		if text[0] == ' ' || text[0] == '\t' {
+			return nil, fmt.Errorf("%d: nested values are not supported, use key: yes|no", line)
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("%d: expected key: yes|no", line)
+		}
+		key = unquote(strings.TrimSpace(key))
+		if questionIndex(key) < 0 {
+			return nil, fmt.Errorf("%d: unknown question %q, expected one of %s", line, key, questionKeys())
+		}
+		if _, dup := answers[key]; dup {
+			return nil, fmt.Errorf("%d: %s answered twice", line, key)
+		}
+		a, err := parseAnswer(unquote(strings.TrimSpace(value)))
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %v", line, key, err)
+		}
+		answers[key] = a
+	}
+	return answers, scanner.Err()
+}
+
+// unquote strips matching single or double quotes.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
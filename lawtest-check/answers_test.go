@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestParseAnswers(t *testing.T) {
+	input := `---
+# Merge in config-merge-example
+signature: yes
+comparable: "no"   # needs a wrapper
+order-matters: N
+'concurrent': true
+`
+	got, err := parseAnswers(bufio.NewScanner(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]answer{"signature": yes, "comparable": no, "order-matters": no, "concurrent": yes}
+	if len(got) != len(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	for key, a := range want {
+		if got[key] != a {
+			t.Errorf("%s: expected %s, got %s", key, a, got[key])
+		}
+	}
+}
+
+func TestParseAnswersErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"pure: maybe", `1: pure: "maybe" is not yes or no`},
+		{"# comment\nfast: yes", `2: unknown question "fast"`},
+		{"pure: yes\npure: no", "2: pure answered twice"},
+		{"pure: yes\n  strict: no", "2: nested values are not supported"},
+		{"pure yes", "1: expected key: yes|no"},
+	}
+	for _, tt := range tests {
+		_, err := parseAnswers(bufio.NewScanner(strings.NewReader(tt.input)))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.want, err)
+		}
+	}
+}
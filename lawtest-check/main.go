@@ -2,14 +2,148 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
 )
 
 // lawtest-check - Interactive tool to determine if lawtest is appropriate for your use case
 
+// question is one item of the checklist. Its key names the flag and the
+// answers file entry that answer it without asking.
+type question struct {
+	key      string
+	question string
+	reason   string
+	weight   int
+	want     answer // the answer that counts towards the score
+}
+
+var questions = []question{
+	{
+		"signature",
+		"Does your operation have signature (T, T) -> T (same type in and out)?",
+		"lawtest works with binary operations on a single type",
+		10, yes,
+	},
+	{
+		"comparable",
+		"Is the type comparable (can use == in Go) OR can you wrap it with pointers?",
+		"lawtest needs to compare values for equality checks",
+		10, yes,
+	},
+	{
+		"associative",
+		"Should the operation be associative? (a op b) op c = a op (b op c)",
+		"Most lawtest value comes from verifying associativity",
+		8, yes,
+	},
+	{
+		"immutable",
+		"Should the operation be immutable (not mutate inputs)?",
+		"ImmutableOp test requires operations don't mutate",
+		8, yes,
+	},
+	{
+		"pure",
+		"Is the operation pure (no side effects like I/O, database, etc)?",
+		"lawtest assumes pure operations for property testing",
+		9, yes,
+	},
+	{
+		"order-matters",
+		"Does operation order matter for correctness?",
+		"If order matters, operation likely isn't associative",
+		5, no,
+	},
+	{
+		"concurrent",
+		"Is this for concurrent/parallel code?",
+		"lawtest excels at proving concurrent safety",
+		6, yes,
+	},
+}
+
+// response is the answer to a question and, when it wasn't typed in,
+// where it came from.
+type response struct {
+	answer   answer
+	source   string
+	analyzed bool // found by -analyze rather than given
+}
+
 func main() {
+	given := make([]answer, len(questions))
+	for i, q := range questions {
+		flag.Var(&given[i], q.key, "answer `yes|no` to: "+q.question)
+	}
+	answersFile := flag.String("answers", "", "YAML `file` with answers keyed by the question flags, e.g. associative: yes")
+	analyze := flag.String("analyze", "", "answer the signature, comparability and purity questions by analyzing the operation in `package`")
+	funcName := flag.String("func", "", "operation to analyze, such as Merge or State.Merge; may be omitted when the package has a single (T, T) -> T operation")
+	recordFile := flag.String("record", "", "write the decision record to `file`; with -analyze it defaults to <op>.lawcheck.yaml in the package")
+	dryRun := flag.Bool("dry-run", false, "print the decision record and test file instead of writing them")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lawtest-check [flags]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Asks whether lawtest fits an operation. Questions answered by flags, an")
+		fmt.Fprintln(os.Stderr, "answers file or -analyze are not asked, e.g.:")
+		fmt.Fprintln(os.Stderr, "  lawtest-check -analyze ./faulttest -func Merge")
+		fmt.Fprintln(os.Stderr, "  lawtest-check -answers merge.yaml -concurrent=no")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "With -analyze, the decision is recorded next to the package, in the format")
+		fmt.Fprintln(os.Stderr, "of -answers, with a test file making the recommended lawtest calls.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Explicit answers take precedence over the answers file, which takes
+	// precedence over analysis.
+	responses := make([]response, len(questions))
+	for i, a := range given {
+		if a != unanswered {
+			responses[i] = response{answer: a, source: "-" + questions[i].key}
+		}
+	}
+	if *answersFile != "" {
+		answers, err := readAnswersFile(*answersFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		for i, q := range questions {
+			if a, ok := answers[q.key]; ok && responses[i].answer == unanswered {
+				responses[i] = response{answer: a, source: *answersFile}
+			}
+		}
+	}
+
+	var op *operation
+	if *analyze != "" {
+		var err error
+		op, err = analyzeOperation(*analyze, *funcName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		for _, f := range op.findings() {
+			i := questionIndex(f.key)
+			if responses[i].answer == unanswered {
+				responses[i] = response{f.answer, "analysis: " + f.note, true}
+			}
+		}
+	}
+
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════")
 	fmt.Println("  lawtest Applicability Checker")
@@ -19,69 +153,38 @@ func main() {
 	fmt.Println("testing your operation.")
 	fmt.Println()
 
+	if op != nil {
+		fmt.Printf("Analyzed %s at %s\n", op.Signature, relPos(op.Pos))
+		fmt.Println()
+		writePurity(os.Stdout, op)
+	}
+
 	scanner := bufio.NewScanner(os.Stdin)
 	score := 0
 	total := 0
 
-	questions := []struct {
-		question string
-		reason   string
-		weight   int
-	}{
-		{
-			"Does your operation have signature (T, T) -> T (same type in and out)?",
-			"lawtest works with binary operations on a single type",
-			10,
-		},
-		{
-			"Is the type comparable (can use == in Go) OR can you wrap it with pointers?",
-			"lawtest needs to compare values for equality checks",
-			10,
-		},
-		{
-			"Should the operation be associative? (a op b) op c = a op (b op c)",
-			"Most lawtest value comes from verifying associativity",
-			8,
-		},
-		{
-			"Should the operation be immutable (not mutate inputs)?",
-			"ImmutableOp test requires operations don't mutate",
-			8,
-		},
-		{
-			"Is the operation pure (no side effects like I/O, database, etc)?",
-			"lawtest assumes pure operations for property testing",
-			9,
-		},
-		{
-			"Does operation order matter for correctness?",
-			"If order matters, operation likely isn't associative",
-			5,
-		},
-		{
-			"Is this for concurrent/parallel code?",
-			"lawtest excels at proving concurrent safety",
-			6,
-		},
-	}
-
 	for i, q := range questions {
 		total += q.weight
 		fmt.Printf("%d. %s\n", i+1, q.question)
 		fmt.Printf("   Why: %s\n", q.reason)
-		fmt.Print("   Answer (y/n): ")
 
-		scanner.Scan()
-		answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
-
-		if i == 5 { // "order matters" question - inverted logic
-			if answer == "n" || answer == "no" {
-				score += q.weight
-			}
+		r := responses[i]
+		if r.answer != unanswered {
+			fmt.Printf("   Answer: %s (%s)\n", r.answer, r.source)
 		} else {
-			if answer == "y" || answer == "yes" {
-				score += q.weight
+			fmt.Print("   Answer (y/n): ")
+			a, err := readAnswer(scanner)
+			if err != nil {
+				fmt.Println()
+				fmt.Fprintf(os.Stderr, "Error: question %d: %v; answer it with -%s=yes|no or in the -answers file\n", i+1, err, q.key)
+				os.Exit(2)
 			}
+			r.answer = a
+			responses[i] = r
+		}
+
+		if r.answer == q.want {
+			score += q.weight
 		}
 		fmt.Println()
 	}
@@ -93,17 +196,24 @@ func main() {
 	fmt.Println("═══════════════════════════════════════════════════════════")
 	fmt.Printf("\nScore: %d/%d (%.0f%%)\n\n", score, total, percentage)
 
+	answers := make([]answer, len(responses))
+	for i, r := range responses {
+		answers[i] = r.answer
+	}
+	adv := recommend(answers, op)
+
+	var fit string
 	if percentage >= 80 {
+		fit = "excellent fit"
 		fmt.Println("✅ EXCELLENT FIT for lawtest")
 		fmt.Println()
 		fmt.Println("Your operation is a perfect candidate for property-based")
-		fmt.Println("testing with lawtest. You should use:")
-		fmt.Println("  • lawtest.ImmutableOp() - verify no mutation")
-		fmt.Println("  • lawtest.Associative() - verify order independence")
-		fmt.Println("  • lawtest.ParallelSafe() - verify concurrent safety")
+		fmt.Println("testing with lawtest.")
 		fmt.Println()
+		writeAdvice(os.Stdout, adv)
 		fmt.Println("See config-merge-example for implementation patterns.")
 	} else if percentage >= 60 {
+		fit = "partial fit"
 		fmt.Println("⚠️  PARTIAL FIT for lawtest")
 		fmt.Println()
 		fmt.Println("lawtest can help, but with limitations:")
@@ -111,8 +221,10 @@ func main() {
 		fmt.Println("  • You may need wrapper types for non-comparable types")
 		fmt.Println("  • Consider using alongside traditional tests")
 		fmt.Println()
+		writeAdvice(os.Stdout, adv)
 		fmt.Println("Review LAWTEST_USAGE.md for decision guidance.")
 	} else {
+		fit = "poor fit"
 		fmt.Println("❌ POOR FIT for lawtest")
 		fmt.Println()
 		fmt.Println("lawtest is NOT recommended for this use case.")
@@ -128,4 +240,154 @@ func main() {
 	fmt.Println()
 	fmt.Println("See LAWTEST_USAGE.md for detailed guidelines.")
 	fmt.Println()
+
+	if *recordFile == "" && op != nil {
+		*recordFile = filepath.Join(filepath.Dir(op.Pos.Filename), baseName(op)+".lawcheck.yaml")
+	}
+	if *recordFile == "" {
+		return
+	}
+	d := decision{
+		Op:        op,
+		Pattern:   *analyze,
+		Func:      *funcName,
+		Responses: responses,
+		Score:     score,
+		Total:     total,
+		Fit:       fit,
+		Advice:    adv,
+		Time:      time.Now(),
+	}
+	if err := writeDecision(d, *recordFile, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeDecision writes the decision record and, when d recommends lawtest
+// calls for an analyzed operation, a test file making them. An existing
+// test file is left alone: it is meant to be edited.
+func writeDecision(d decision, recordFile string, dryRun bool) error {
+	var record strings.Builder
+	if err := writeRecord(&record, d, relPath(recordFile)); err != nil {
+		return err
+	}
+
+	var testFile, test string
+	if d.Op != nil && d.Op.Binary && !d.Op.Generic && len(d.Advice.Use) > 0 {
+		testFile = filepath.Join(filepath.Dir(d.Op.Pos.Filename), baseName(d.Op)+"_lawcheck_test.go")
+		var err error
+		test, err = generateSkeleton(d.Op, d.Advice, filepath.Base(recordFile))
+		if err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("--- %s\n%s\n", relPath(recordFile), record.String())
+		if testFile != "" {
+			fmt.Printf("--- %s\n%s", relPath(testFile), test)
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(recordFile, []byte(record.String()), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded the decision in %s\n", relPath(recordFile))
+	if testFile == "" {
+		return nil
+	}
+	if _, err := os.Stat(testFile); err == nil {
+		fmt.Printf("Kept %s; delete it to have it written again\n", relPath(testFile))
+		return nil
+	}
+	if err := os.WriteFile(testFile, []byte(test), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s; run it with: go test -run %s\n", relPath(testFile), testName(d.Op))
+	switch v := lawtestVersion(d.Op); {
+	case v == "":
+		fmt.Printf("Add lawtest to the module first: go get %s\n", lawtestPath)
+	case d.Advice.Custom && semver.Compare(v, "v0.1.3") < 0:
+		fmt.Printf("The Custom variants need lawtest v0.1.3, the module has %s: go get %s@v0.1.3\n", v, lawtestPath)
+	}
+	return nil
+}
+
+// questionIndex returns the index of the question with the given key, or
+// -1 if there is none.
+func questionIndex(key string) int {
+	for i, q := range questions {
+		if q.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// readAnswer reads answers from the scanner until one is yes or no. Running
+// out of input is an error rather than a silent no, so scripts that forget
+// a question fail instead of scoring it.
+func readAnswer(scanner *bufio.Scanner) (answer, error) {
+	for scanner.Scan() {
+		a, err := parseAnswer(scanner.Text())
+		if err == nil {
+			return a, nil
+		}
+		fmt.Print("   Please answer y or n: ")
+	}
+	if err := scanner.Err(); err != nil {
+		return unanswered, err
+	}
+	return unanswered, errors.New("no answer on standard input")
+}
+
+// answer is a yes or no answer to a question, or none yet.
+type answer int
+
+const (
+	unanswered answer = iota
+	yes
+	no
+)
+
+func (a answer) String() string {
+	switch a {
+	case yes:
+		return "yes"
+	case no:
+		return "no"
+	}
+	return ""
+}
+
+// Set implements flag.Value.
+func (a *answer) Set(s string) error {
+	v, err := parseAnswer(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// parseAnswer accepts y/n, yes/no and true/false in any case.
+func parseAnswer(s string) (answer, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes", "true":
+		return yes, nil
+	case "n", "no", "false":
+		return no, nil
+	}
+	return unanswered, fmt.Errorf("%q is not yes or no", s)
+}
+
+// questionKeys lists the keys of all questions, for error messages.
+func questionKeys() string {
+	keys := make([]string, len(questions))
+	for i, q := range questions {
+		keys[i] = q.key
+	}
+	return strings.Join(keys, ", ")
 }
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// purity is the verdict on whether an operation is pure: the side effects
+// found in it and in the functions it calls, and the calls that couldn't
+// be followed.
+type purity struct {
+	Effects []effect
+	Dynamic []token.Position // calls through func values or interfaces of the module
+	Visited int              // functions whose bodies were checked
+}
+
+// effect is a side effect found at a call site or statement.
+type effect struct {
+	Pos  token.Position
+	Kind string   // I/O, logging, time, randomness, global write or channel
+	What string   // the call or statement, such as time.Now()
+	Via  []string // functions called on the way from the operation, if any
+}
+
+func (e effect) String() string {
+	s := fmt.Sprintf("%s at %s", e.What, relPos(e.Pos))
+	if len(e.Via) > 0 {
+		s += " via " + strings.Join(e.Via, " → ")
+	}
+	return s
+}
+
+// Pure reports whether no side effect was found.
+func (p purity) Pure() bool {
+	return len(p.Effects) == 0
+}
+
+// Verdict sums up the purity check in a few words.
+func (p purity) Verdict() string {
+	switch {
+	case len(p.Effects) == 1:
+		return "impure, 1 side effect"
+	case len(p.Effects) > 1:
+		return fmt.Sprintf("impure, %d side effects", len(p.Effects))
+	case len(p.Dynamic) > 0:
+		return fmt.Sprintf("pure as far as static calls go, %d dynamic calls not followed", len(p.Dynamic))
+	}
+	return "pure"
+}
+
+// writePurity prints the verdict on op's purity with the offending call
+// sites.
+func writePurity(w io.Writer, op *operation) {
+	p := op.Purity
+	calls := ""
+	if n := p.Visited - 1; n == 1 {
+		calls = " and the function it calls"
+	} else if n > 1 {
+		calls = fmt.Sprintf(" and the %d functions it calls", n)
+	}
+	fmt.Fprintf(w, "Purity: %s in %s%s\n", p.Verdict(), op.Name, calls)
+	for _, e := range p.Effects {
+		fmt.Fprintf(w, "  • %-12s %s\n", e.Kind, e)
+	}
+	for _, pos := range p.Dynamic {
+		fmt.Fprintf(w, "  ? %-12s call through a func value or interface at %s\n", "not followed", relPos(pos))
+	}
+	fmt.Fprintln(w)
+}
+
+// effectfulPackages classifies packages all of whose functions and methods
+// are side effects.
+var effectfulPackages = map[string]string{
+	"bufio":        "I/O",
+	"database/sql": "I/O",
+	"io":           "I/O",
+	"io/ioutil":    "I/O",
+	"net":          "I/O",
+	"net/http":     "I/O",
+	"os":           "I/O",
+	"os/exec":      "I/O",
+	"syscall":      "I/O",
+	"log":          "logging",
+	"log/slog":     "logging",
+	"crypto/rand":  "randomness",
+	"math/rand":    "randomness",
+	"math/rand/v2": "randomness",
+}
+
+// effectfulFuncs classifies side effects in packages that are otherwise
+// pure, like time and fmt.
+var effectfulFuncs = map[string]string{
+	"time.Now":       "time",
+	"time.Since":     "time",
+	"time.Until":     "time",
+	"time.Sleep":     "time",
+	"time.After":     "time",
+	"time.AfterFunc": "time",
+	"time.Tick":      "time",
+	"time.NewTimer":  "time",
+	"time.NewTicker": "time",
+	"fmt.Print":      "I/O",
+	"fmt.Printf":     "I/O",
+	"fmt.Println":    "I/O",
+	"fmt.Fprint":     "I/O",
+	"fmt.Fprintf":    "I/O",
+	"fmt.Fprintln":   "I/O",
+	"fmt.Scan":       "I/O",
+	"fmt.Scanf":      "I/O",
+	"fmt.Scanln":     "I/O",
+	"fmt.Fscan":      "I/O",
+	"fmt.Fscanf":     "I/O",
+	"fmt.Fscanln":    "I/O",
+}
+
+// effectKind classifies a call to fn, returning "" when it has no side
+// effect.
+func effectKind(fn *types.Func) string {
+	if kind, ok := effectfulPackages[fn.Pkg().Path()]; ok {
+		return kind
+	}
+	if fn.Signature().Recv() != nil {
+		return ""
+	}
+	return effectfulFuncs[fn.Pkg().Path()+"."+fn.Name()]
+}
+
+// checkPurity walks the static call graph from fn, checking the bodies of
+// the functions declared in modules. Those of the standard library are
+// known from the tables above instead.
+func checkPurity(root *packages.Package, fn *types.Func) purity {
+	w := &purityWalker{
+		decls:   make(map[*types.Func]funcSource),
+		visited: make(map[*types.Func]bool),
+		modules: make(map[*types.Package]bool),
+	}
+	packages.Visit([]*packages.Package{root}, nil, func(pkg *packages.Package) {
+		if pkg.Module == nil || pkg.TypesInfo == nil {
+			return
+		}
+		w.modules[pkg.Types] = true
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil {
+					if obj, ok := pkg.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+						w.decls[obj] = funcSource{pkg, fd}
+					}
+				}
+			}
+		}
+	})
+	w.walk(fn, nil)
+	return w.purity
+}
+
+type funcSource struct {
+	pkg  *packages.Package
+	decl *ast.FuncDecl
+}
+
+type purityWalker struct {
+	purity
+	decls   map[*types.Func]funcSource
+	visited map[*types.Func]bool
+	modules map[*types.Package]bool // packages outside the standard library
+}
+
+// walk records the side effects of fn, reached through the calls in via,
+// and walks the functions it calls. Each function is checked once.
+func (w *purityWalker) walk(fn *types.Func, via []string) {
+	src, ok := w.decls[fn]
+	if !ok || w.visited[fn] {
+		return
+	}
+	w.visited[fn] = true
+	w.Visited++
+
+	info := src.pkg.TypesInfo
+	add := func(n ast.Node, kind, what string) {
+		w.Effects = append(w.Effects, effect{src.pkg.Fset.Position(n.Pos()), kind, what, via})
+	}
+	global := func(expr ast.Expr) {
+		if v := rootVar(info, expr); v != nil && v.Pkg() != nil && v.Parent() == v.Pkg().Scope() {
+			add(expr, "global write", "writes "+v.Pkg().Name()+"."+v.Name())
+		}
+	}
+
+	ast.Inspect(src.decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			switch callee := typeutil.Callee(info, n).(type) {
+			case *types.Builtin:
+				if callee.Name() == "close" {
+					add(n, "channel", "close("+render(src.pkg.Fset, n.Args[0])+")")
+				}
+			case *types.Func:
+				callee = callee.Origin()
+				switch {
+				case callee.Pkg() == nil:
+					// error.Error
+				case effectKind(callee) != "":
+					add(n, effectKind(callee), callName(callee)+"()")
+				case w.decls[callee].decl != nil:
+					w.walk(callee, append(slices.Clip(via), callName(callee)))
+				case w.modules[callee.Pkg()] && isInterfaceMethod(callee):
+					w.Dynamic = append(w.Dynamic, src.pkg.Fset.Position(n.Pos()))
+				}
+			case nil:
+				if tv, ok := info.Types[n.Fun]; !ok || !tv.IsType() {
+					// A call through a func value
+					w.Dynamic = append(w.Dynamic, src.pkg.Fset.Position(n.Pos()))
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				for _, lhs := range n.Lhs {
+					global(lhs)
+				}
+			}
+		case *ast.IncDecStmt:
+			global(n.X)
+		case *ast.SendStmt:
+			add(n, "channel", "send on "+render(src.pkg.Fset, n.Chan))
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				add(n, "channel", "receive from "+render(src.pkg.Fset, n.X))
+			}
+		case *ast.RangeStmt:
+			if _, ok := info.TypeOf(n.X).Underlying().(*types.Chan); ok {
+				add(n, "channel", "range over "+render(src.pkg.Fset, n.X))
+			}
+		case *ast.SelectStmt:
+			add(n, "channel", "select")
+		}
+		return true
+	})
+}
+
+func isInterfaceMethod(fn *types.Func) bool {
+	recv := fn.Signature().Recv()
+	return recv != nil && types.IsInterface(recv.Type())
+}
+
+func callName(fn *types.Func) string {
+	if recv := fn.Signature().Recv(); recv != nil {
+		return "(" + types.TypeString(recv.Type(), (*types.Package).Name) + ")." + fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
+
+// rootVar returns the variable an assignment target writes into, looking
+// through fields, index expressions and dereferences.
+func rootVar(info *types.Info, expr ast.Expr) *types.Var {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			v, _ := info.Uses[e].(*types.Var)
+			return v
+		case *ast.SelectorExpr:
+			if sel := info.Selections[e]; sel == nil {
+				// A qualified identifier such as pkg.Var
+				v, _ := info.Uses[e.Sel].(*types.Var)
+				return v
+			}
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// relPos shortens pos to a path relative to the working directory when
+// that is shorter.
+func relPos(pos token.Position) string {
+	pos.Filename = relPath(pos.Filename)
+	return pos.String()
+}
+
+// relPath returns path relative to the working directory when that is
+// shorter.
+func relPath(path string) string {
+	if wd, err := os.Getwd(); err == nil && filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(wd, path); err == nil && len(rel) < len(path) {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+)
+
+// advice is what the answers call for: the lawtest calls to make, those
+// ruled out, and caveats.
+type advice struct {
+	Use   []recommendation
+	Skip  []recommendation
+	Notes []string
+
+	Custom bool // the Custom variants with an equality func are needed
+	Impure bool // the operation has side effects
+}
+
+// recommendation is a lawtest call with the reason it is made or ruled out.
+type recommendation struct {
+	Call   string // such as lawtest.AssociativeCustom
+	Law    string // the subtest checking it, such as Associativity
+	Reason string
+}
+
+// recommend turns the answers into lawtest calls. op, when analyzed,
+// decides whether values can be compared with == or need the Custom
+// variants.
+func recommend(answers []answer, op *operation) advice {
+	var adv advice
+	get := func(key string) answer { return answers[questionIndex(key)] }
+
+	if get("signature") == no {
+		adv.Notes = append(adv.Notes, "lawtest checks (T, T) -> T operations; for updates like Set(k, v) T, lawtest-gen generates tests of the transition laws")
+		return adv
+	}
+	if get("comparable") == no {
+		adv.Notes = append(adv.Notes, "lawtest compares results; give the type a content equality, or keep funcs and channels out of it, before testing its laws")
+		return adv
+	}
+
+	pointer := false
+	if op != nil && op.Type != nil {
+		_, pointer = op.Type.Underlying().(*types.Pointer)
+		adv.Custom = !op.Comparable || pointer
+	}
+	call := func(name string) string {
+		if adv.Custom {
+			return "lawtest." + name + "Custom"
+		}
+		return "lawtest." + name
+	}
+
+	if get("immutable") == yes {
+		adv.Use = append(adv.Use, recommendation{call("ImmutableOp"), "Immutability", "the inputs must survive the operation untouched"})
+	} else {
+		adv.Skip = append(adv.Skip, recommendation{call("ImmutableOp"), "Immutability", "the operation may update its inputs"})
+	}
+
+	switch {
+	case get("associative") == yes && get("order-matters") == yes:
+		adv.Skip = append(adv.Skip, recommendation{call("Associative"), "Associativity", "order matters, so regrouping a chain of calls likely changes the result"})
+	case get("associative") == yes:
+		adv.Use = append(adv.Use, recommendation{call("Associative"), "Associativity", "(a op b) op c must equal a op (b op c)"})
+	default:
+		adv.Skip = append(adv.Skip, recommendation{call("Associative"), "Associativity", "the operation isn't meant to be associative"})
+	}
+
+	if get("concurrent") == yes {
+		adv.Use = append(adv.Use, recommendation{call("ParallelSafe"), "ParallelSafety", "concurrent calls must agree with sequential ones"})
+	} else {
+		adv.Skip = append(adv.Skip, recommendation{call("ParallelSafe"), "ParallelSafety", "the operation isn't used concurrently"})
+	}
+
+	switch {
+	case adv.Custom && pointer:
+		adv.Notes = append(adv.Notes, fmt.Sprintf("== compares %s by identity, so the Custom variants take an equality func (lawtest v0.1.3 or later)", op.TypeName))
+	case adv.Custom:
+		adv.Notes = append(adv.Notes, fmt.Sprintf("%s can't be compared with ==, so the Custom variants take an equality func (lawtest v0.1.3 or later)", op.TypeName))
+	case op == nil || op.Type == nil:
+		adv.Notes = append(adv.Notes, "if == doesn't compare the type by content (maps, slices, pointers), use the Custom variants with an equality func")
+	}
+	if get("pure") == no {
+		adv.Impure = true
+		adv.Notes = append(adv.Notes, "the operation has side effects: make them deterministic, e.g. by injecting the clock or the random source, or the tests will be flaky")
+	}
+	return adv
+}
+
+// writeAdvice prints the recommended and ruled out calls.
+func writeAdvice(w io.Writer, adv advice) {
+	if len(adv.Use) > 0 {
+		fmt.Fprintln(w, "Recommended lawtest calls:")
+		for _, r := range adv.Use {
+			fmt.Fprintf(w, "  • %s() - %s\n", r.Call, r.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(adv.Skip) > 0 {
+		fmt.Fprintln(w, "Left out:")
+		for _, r := range adv.Skip {
+			fmt.Fprintf(w, "  • %s() - %s\n", r.Call, r.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, note := range adv.Notes {
+		fmt.Fprintf(w, "Note: %s.\n", note)
+	}
+	if len(adv.Notes) > 0 {
+		fmt.Fprintln(w)
+	}
+}
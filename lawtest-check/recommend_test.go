@@ -0,0 +1,132 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// answersFor answers every question yes, except the keys given as no.
+func answersFor(noKeys ...string) []answer {
+	answers := make([]answer, len(questions))
+	for i := range answers {
+		answers[i] = yes
+	}
+	for _, key := range noKeys {
+		answers[questionIndex(key)] = no
+	}
+	return answers
+}
+
+func TestRecommend(t *testing.T) {
+	merge, err := analyzeOp(t, "Merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	add, err := analyzeOp(t, "Counter.Add")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		answers []answer
+		op      *operation
+		use     []string
+	}{
+		{"comparable", answersFor("order-matters"), add, []string{"lawtest.ImmutableOp", "lawtest.Associative", "lawtest.ParallelSafe"}},
+		{"concurrent map", answersFor("order-matters"), merge, []string{"lawtest.ImmutableOpCustom", "lawtest.AssociativeCustom", "lawtest.ParallelSafeCustom"}},
+		{"order matters", answersFor("concurrent"), merge, []string{"lawtest.ImmutableOpCustom"}},
+		{"not analyzed", answersFor("order-matters", "immutable"), nil, []string{"lawtest.Associative", "lawtest.ParallelSafe"}},
+		{"not binary", answersFor("signature"), merge, nil},
+		{"not comparable", answersFor("comparable"), merge, nil},
+	}
+	for _, tt := range tests {
+		adv := recommend(tt.answers, tt.op)
+		var use []string
+		for _, r := range adv.Use {
+			use = append(use, r.Call)
+		}
+		if strings.Join(use, ", ") != strings.Join(tt.use, ", ") {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.use, use)
+		}
+		if len(adv.Use)+len(adv.Skip) != 3 && len(adv.Use)+len(adv.Skip) != 0 {
+			t.Errorf("%s: expected each call recommended or left out, got %+v", tt.name, adv)
+		}
+	}
+}
+
+func TestGenerateSkeleton(t *testing.T) {
+	op, err := analyzeOp(t, "Merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generateSkeleton(op, recommend(answersFor("order-matters"), op), "merge.lawcheck.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "merge_lawcheck_test.go", src, 0); err != nil {
+		t.Fatalf("generated test doesn't parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"package ops",
+		"func TestMergeLawCheck(t *testing.T) {",
+		"op := Merge",
+		"gen := func() Config {",
+		"m := make(Config)",
+		"eq := func(a, b Config) bool { return reflect.DeepEqual(a, b) }",
+		"lawtest.AssociativeCustom(t, op, gen, eq)",
+		"lawtest.ParallelSafeCustom(t, op, gen, eq, 100)",
+		`"math/rand/v2"`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected %q in\n%s", want, src)
+		}
+	}
+
+	op, err = analyzeOp(t, "Counter.Add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err = generateSkeleton(op, recommend(answersFor("order-matters", "pure"), op), "counter_add.lawcheck.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func TestCounterAddLawCheck(t *testing.T) {",
+		"op := func(a, b Counter) Counter { return a.Add(b) }",
+		"N: rand.IntN(10),",
+		"(Counter).Add has side effects",
+		"lawtest.Associative(t, op, gen)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected %q in\n%s", want, src)
+		}
+	}
+
+	// Unexported fields are left to the constructor and the updates, and
+	// values are compared through what the methods report
+	op, err = analyzeOp(t, "Tally.Union")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err = generateSkeleton(op, recommend(answersFor("order-matters"), op), "tally_union.lawcheck.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"v := NewTally(func() []string {",
+		"v = v.Add(string(rune('a' + rand.IntN(4))))",
+		"// observe returns what All and Total report",
+		"return []any{maps.Collect(v.All()), v.Total()}",
+		"eq := func(a, b *Tally) bool { return reflect.DeepEqual(observe(a), observe(b)) }",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected %q in\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "counts:") || strings.Contains(src, "Watch") {
+		t.Errorf("expected no unexported fields and no Watch in\n%s", src)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// decision is the outcome of a check, recorded next to the package so the
+// reasons for testing an operation the way it is stay on file.
+type decision struct {
+	Op        *operation // nil unless analyzed
+	Pattern   string     // the -analyze package
+	Func      string     // the -func name
+	Responses []response
+	Score     int
+	Total     int
+	Fit       string
+	Advice    advice
+	Time      time.Time
+}
+
+// writeRecord writes d in the format of the -answers file, so that
+// replaying it asks nothing. Answers found by analysis are commented out:
+// a replay analyzes the operation again.
+func writeRecord(w io.Writer, d decision, path string) error {
+	var sb strings.Builder
+	if d.Op != nil {
+		fmt.Fprintf(&sb, "# lawtest-check decision for %s\n", d.Op.Name)
+		fmt.Fprintf(&sb, "# %s, declared at %s\n", d.Op.fn.Pkg().Path(), relPos(d.Op.Pos))
+	} else {
+		sb.WriteString("# lawtest-check decision\n")
+	}
+	fmt.Fprintf(&sb, "# Recorded %s. Replay with:\n", d.Time.Format(time.DateOnly))
+	sb.WriteString("#   lawtest-check")
+	if d.Pattern != "" {
+		fmt.Fprintf(&sb, " -analyze %s", d.Pattern)
+	}
+	if d.Func != "" {
+		fmt.Fprintf(&sb, " -func %s", d.Func)
+	}
+	fmt.Fprintf(&sb, " -answers %s\n", path)
+	sb.WriteString("#\n")
+	fmt.Fprintf(&sb, "# Score: %d/%d (%.0f%%), %s\n", d.Score, d.Total, float64(d.Score)/float64(d.Total)*100, d.Fit)
+	for _, r := range d.Advice.Use {
+		fmt.Fprintf(&sb, "# Use %s: %s\n", r.Call, r.Reason)
+	}
+	for _, r := range d.Advice.Skip {
+		fmt.Fprintf(&sb, "# Left out %s: %s\n", r.Call, r.Reason)
+	}
+	for _, note := range d.Advice.Notes {
+		fmt.Fprintf(&sb, "# Note: %s\n", note)
+	}
+	sb.WriteString("\n")
+
+	for i, q := range questions {
+		r := d.Responses[i]
+		switch {
+		case r.analyzed:
+			fmt.Fprintf(&sb, "# %s: %s  (%s)\n", q.key, r.answer, r.source)
+		case r.source != "" && filepath.Clean(r.source) != filepath.Clean(path):
+			fmt.Fprintf(&sb, "%s: %s  # %s\n", q.key, r.answer, r.source)
+		default:
+			fmt.Fprintf(&sb, "%s: %s\n", q.key, r.answer)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
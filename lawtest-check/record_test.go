@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRecord(t *testing.T) {
+	op, err := analyzeOp(t, "Merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := make([]response, len(questions))
+	answers := answersFor("order-matters")
+	for i, a := range answers {
+		responses[i] = response{answer: a}
+	}
+	for _, f := range op.findings() {
+		responses[questionIndex(f.key)] = response{f.answer, "analysis: " + f.note, true}
+	}
+	responses[questionIndex("concurrent")].source = "-concurrent"
+
+	var sb strings.Builder
+	d := decision{
+		Op:        op,
+		Pattern:   "./testdata/ops",
+		Func:      "Merge",
+		Responses: responses,
+		Score:     56,
+		Total:     56,
+		Fit:       "excellent fit",
+		Advice:    recommend(answers, op),
+		Time:      time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+	}
+	if err := writeRecord(&sb, d, "merge.lawcheck.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	record := sb.String()
+	for _, want := range []string{
+		"# lawtest-check decision for Merge\n",
+		"# Recorded 2026-10-16. Replay with:\n",
+		"#   lawtest-check -analyze ./testdata/ops -func Merge -answers merge.lawcheck.yaml\n",
+		"# Use lawtest.AssociativeCustom: ",
+		"# signature: yes  (analysis: ",
+		"concurrent: yes  # -concurrent\n",
+		"order-matters: no\n",
+	} {
+		if !strings.Contains(record, want) {
+			t.Errorf("expected %q in\n%s", want, record)
+		}
+	}
+
+	// The record replays as an answers file, leaving analysis to run again
+	got, err := parseAnswers(bufio.NewScanner(strings.NewReader(record)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]answer{"associative": yes, "immutable": yes, "order-matters": no, "concurrent": yes}
+	if len(got) != len(want) {
+		t.Errorf("Expected answers %v, got %v", want, got)
+	}
+	for key, a := range want {
+		if got[key] != a {
+			t.Errorf("%s: expected %s, got %s", key, a, got[key])
+		}
+	}
+}
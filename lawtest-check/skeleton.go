@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/mod/modfile"
+)
+
+// baseName names the files written for op: state_merge for
+// (*State).Merge, safemerge for SafeMerge.
+func baseName(op *operation) string {
+	name := op.fn.Name()
+	if op.Recv != "" {
+		name = op.Recv + "_" + name
+	}
+	return strings.ToLower(name)
+}
+
+// testName names the test function written for op, such as
+// TestStateMergeLawCheck.
+func testName(op *operation) string {
+	name := op.fn.Name()
+	if op.Recv != "" {
+		name = op.Recv + name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return "Test" + string(r) + "LawCheck"
+}
+
+// generateSkeleton writes a test file for op making the recommended
+// lawtest calls. The generator fills values with random contents, which is
+// enough to run the tests; realistic values make them stronger.
+func generateSkeleton(op *operation, adv advice, record string) (string, error) {
+	if !op.Binary || op.Generic {
+		return "", fmt.Errorf("%s is not a (T, T) -> T operation lawtest-check can write tests for", op.Name)
+	}
+	pkg := op.fn.Pkg()
+	s := &skeleton{pkg: pkg, imports: map[string]bool{"testing": true, lawtestPath: true}}
+	t := s.typ(op.Type)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "// %s checks %s with the lawtest calls lawtest-check\n", testName(op), op.Name)
+	fmt.Fprintf(&body, "// recommended, from the answers recorded in %s.\n", record)
+	fmt.Fprintf(&body, "func %s(t *testing.T) {\n", testName(op))
+	if adv.Impure {
+		fmt.Fprintf(&body, "// %s has side effects: make them deterministic, e.g. by\n", op.Name)
+		body.WriteString("// injecting the clock or the random source, or these tests will be flaky.\n")
+	}
+	if op.Recv != "" {
+		fmt.Fprintf(&body, "op := func(a, b %s) %s { return a.%s(b) }\n", t, t, op.fn.Name())
+	} else {
+		fmt.Fprintf(&body, "op := %s\n", op.fn.Name())
+	}
+	fmt.Fprintf(&body, "gen := func() %s {\n%s}\n", t, s.gen(op))
+	if adv.Custom {
+		s.imports["reflect"] = true
+		if names, observed := s.observers(op.Type); len(names) > 0 {
+			list := names[len(names)-1]
+			if len(names) > 1 {
+				list = strings.Join(names[:len(names)-1], ", ") + " and " + list
+			}
+			fmt.Fprintf(&body, "// observe returns what %s report\n", list)
+			fmt.Fprintf(&body, "observe := func(v %s) []any {\nreturn []any{%s}\n}\n", t, strings.Join(observed, ", "))
+			fmt.Fprintf(&body, "eq := func(a, b %s) bool { return reflect.DeepEqual(observe(a), observe(b)) }\n", t)
+		} else {
+			fmt.Fprintf(&body, "eq := func(a, b %s) bool { return reflect.DeepEqual(a, b) }\n", t)
+		}
+	}
+	for _, r := range adv.Use {
+		args := "t, op, gen"
+		if adv.Custom {
+			args += ", eq"
+		}
+		if strings.HasPrefix(r.Call, "lawtest.ParallelSafe") {
+			args += ", 100"
+		}
+		reason := []rune(r.Reason)
+		reason[0] = unicode.ToUpper(reason[0])
+		fmt.Fprintf(&body, "\nt.Run(%q, func(t *testing.T) {\n// %s.\n%s(%s)\n})\n", r.Law, string(reason), r.Call, args)
+	}
+	body.WriteString("}\n")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code written by lawtest-check for %s. Adjust the generator to produce\n", op.Name)
+	sb.WriteString("// realistic values; lawtest-check won't overwrite this file.\n\n")
+	fmt.Fprintf(&sb, "package %s\n\nimport (\n", pkg.Name())
+	paths := make([]string, 0, len(s.imports))
+	for path := range s.imports {
+		paths = append(paths, path)
+	}
+	// The standard library first, as goimports groups them
+	slices.SortFunc(paths, func(a, b string) int {
+		if standard(a) != standard(b) {
+			if standard(a) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	for i, path := range paths {
+		if i > 0 && standard(paths[i-1]) && !standard(path) {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%q\n", path)
+	}
+	sb.WriteString(")\n\n")
+	sb.WriteString(body.String())
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("formatting test file: %v", err)
+	}
+	return string(src), nil
+}
+
+// skeleton tracks the imports the test file needs.
+type skeleton struct {
+	pkg     *types.Package
+	imports map[string]bool // by path
+}
+
+// typ renders t as seen from the package under test.
+func (s *skeleton) typ(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == s.pkg {
+			return ""
+		}
+		s.imports[p.Path()] = true
+		return p.Name()
+	})
+}
+
+// gen returns the body of the generator of the operand of op. Values are
+// built with the constructor of the type, NewT, and random calls of its
+// update methods when it has them: filling unexported fields at random
+// breaks the invariants between them.
+func (s *skeleton) gen(op *operation) string {
+	t := op.Type
+	start := s.value(t, 0)
+	if ctor := constructor(t); ctor != nil {
+		sig := ctor.Type().(*types.Signature)
+		args := make([]string, sig.Params().Len())
+		for i := range args {
+			args[i] = s.value(sig.Params().At(i).Type(), 1)
+		}
+		start = ctor.Name() + "(" + strings.Join(args, ", ") + ")"
+	}
+	updates := updaters(t, op.fn)
+	if len(updates) == 0 {
+		return "return " + start + "\n"
+	}
+
+	var sb strings.Builder
+	s.imports["math/rand/v2"] = true
+	fmt.Fprintf(&sb, "v := %s\nfor range rand.IntN(4) {\n", start)
+	if len(updates) > 1 {
+		fmt.Fprintf(&sb, "switch rand.IntN(%d) {\n", len(updates))
+	}
+	for i, m := range updates {
+		if len(updates) > 1 {
+			fmt.Fprintf(&sb, "case %d:\n", i)
+		}
+		sig := m.Type().(*types.Signature)
+		args := make([]string, sig.Params().Len())
+		for j := range args {
+			args[j] = s.value(sig.Params().At(j).Type(), 1)
+		}
+		fmt.Fprintf(&sb, "v = v.%s(%s)\n", m.Name(), strings.Join(args, ", "))
+	}
+	if len(updates) > 1 {
+		sb.WriteString("}\n")
+	}
+	sb.WriteString("}\nreturn v\n")
+	return sb.String()
+}
+
+// constructor returns the function NewT of the package of t returning a t
+// from arguments of other types, or nil.
+func constructor(t types.Type) *types.Func {
+	named := namedOf(t)
+	if named == nil || named.Obj().Pkg() == nil {
+		return nil
+	}
+	fn, ok := named.Obj().Pkg().Scope().Lookup("New" + named.Obj().Name()).(*types.Func)
+	if !ok {
+		return nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 || sig.Variadic() || sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), t) || takes(sig, t) {
+		return nil
+	}
+	return fn
+}
+
+// updaters returns the exported methods of t returning an updated t from
+// arguments of other types, like Set(key, value string) *State, but op.
+func updaters(t types.Type, op *types.Func) []*types.Func {
+	var updates []*types.Func
+	mset := types.NewMethodSet(t)
+	for i := range mset.Len() {
+		m := mset.At(i).Obj().(*types.Func)
+		sig := m.Type().(*types.Signature)
+		if m == op || !m.Exported() || sig.Variadic() || sig.Params().Len() == 0 || sig.Results().Len() != 1 ||
+			!types.Identical(sig.Results().At(0).Type(), t) || takes(sig, t) {
+			continue
+		}
+		updates = append(updates, m)
+	}
+	return updates
+}
+
+// observers returns the names of the exported methods of t reading its
+// content, taking no argument and returning one value that isn't a t, and
+// the expressions calling them on v. Iterators are collected; other funcs
+// and channels never compare equal and are left out.
+func (s *skeleton) observers(t types.Type) (names, observed []string) {
+	mset := types.NewMethodSet(t)
+	for i := range mset.Len() {
+		m := mset.At(i).Obj().(*types.Func)
+		sig := m.Type().(*types.Signature)
+		if !m.Exported() || sig.Params().Len() > 0 || sig.Results().Len() != 1 {
+			continue
+		}
+		r := sig.Results().At(0).Type()
+		if types.Identical(r, t) {
+			continue
+		}
+		call := "v." + m.Name() + "()"
+		switch u := r.Underlying().(type) {
+		case *types.Signature:
+			switch yields := seqYields(u); {
+			case len(yields) == 1:
+				s.imports["slices"] = true
+				call = "slices.Collect(" + call + ")"
+			case len(yields) == 2 && types.Comparable(yields[0]):
+				s.imports["maps"] = true
+				call = "maps.Collect(" + call + ")"
+			default:
+				continue
+			}
+		case *types.Chan:
+			continue
+		}
+		names = append(names, m.Name())
+		observed = append(observed, call)
+	}
+	return names, observed
+}
+
+// seqYields returns the types an iterator like iter.Seq or iter.Seq2
+// yields, or nil if sig is not one.
+func seqYields(sig *types.Signature) []types.Type {
+	if sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+		return nil
+	}
+	yield, ok := sig.Params().At(0).Type().Underlying().(*types.Signature)
+	if !ok || yield.Results().Len() != 1 || !types.Identical(yield.Results().At(0).Type(), types.Typ[types.Bool]) {
+		return nil
+	}
+	var yields []types.Type
+	for p := range yield.Params().Variables() {
+		yields = append(yields, p.Type())
+	}
+	return yields
+}
+
+// takes reports whether sig has a parameter of type t.
+func takes(sig *types.Signature, t types.Type) bool {
+	for p := range sig.Params().Variables() {
+		if types.Identical(p.Type(), t) {
+			return true
+		}
+	}
+	return false
+}
+
+// namedOf returns the named type t is, or points to, or nil.
+func namedOf(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// value returns an expression yielding a random value of type t, nested
+// at most three levels deep.
+func (s *skeleton) value(t types.Type, depth int) string {
+	ts := s.typ(t)
+	if depth > 3 {
+		return "*new(" + ts + ")"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		var e string
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			e = "rand.IntN(2) == 0"
+		case info&types.IsString != 0:
+			e = "string(rune('a' + rand.IntN(4)))"
+		case info&types.IsInteger != 0:
+			e = "rand.IntN(10)"
+		case info&types.IsFloat != 0:
+			e = "float64(rand.IntN(10))"
+		default:
+			return "*new(" + ts + ")"
+		}
+		s.imports["math/rand/v2"] = true
+		if t == types.Typ[types.Bool] || t == types.Typ[types.String] || t == types.Typ[types.Int] || t == types.Typ[types.Float64] {
+			return e
+		}
+		return ts + "(" + e + ")"
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Struct); ok && depth < 3 {
+			return "&" + s.value(u.Elem(), depth+1)
+		}
+		return fmt.Sprintf("func() %s {\nv := %s\nreturn &v\n}()", ts, s.value(u.Elem(), depth+1))
+	case *types.Map:
+		s.imports["math/rand/v2"] = true
+		return fmt.Sprintf("func() %s {\nm := make(%s)\nfor range rand.IntN(4) {\nm[%s] = %s\n}\nreturn m\n}()",
+			ts, ts, s.value(u.Key(), depth+1), s.value(u.Elem(), depth+1))
+	case *types.Slice:
+		s.imports["math/rand/v2"] = true
+		return fmt.Sprintf("func() %s {\nvar v %s\nfor range rand.IntN(4) {\nv = append(v, %s)\n}\nreturn v\n}()",
+			ts, ts, s.value(u.Elem(), depth+1))
+	case *types.Array:
+		return fmt.Sprintf("func() %s {\nvar v %s\nfor i := range v {\nv[i] = %s\n}\nreturn v\n}()",
+			ts, ts, s.value(u.Elem(), depth+1))
+	case *types.Struct:
+		var fields []string
+		for f := range u.Fields() {
+			if f.Exported() || f.Pkg() == s.pkg {
+				fields = append(fields, f.Name()+": "+s.value(f.Type(), depth+1))
+			}
+		}
+		if len(fields) == 0 {
+			return ts + "{}"
+		}
+		return ts + "{\n" + strings.Join(fields, ",\n") + ",\n}"
+	}
+	return "*new(" + ts + ")"
+}
+
+// standard reports whether path belongs to the standard library, whose
+// first element has no dot.
+func standard(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// lawtestPath is the module path of lawtest.
+const lawtestPath = "github.com/alexshd/lawtest"
+
+// lawtestVersion returns the version of lawtest the module of op requires,
+// or "" if it doesn't.
+func lawtestVersion(op *operation) string {
+	if op.module == nil || op.module.GoMod == "" {
+		return ""
+	}
+	data, err := os.ReadFile(op.module.GoMod)
+	if err != nil {
+		return ""
+	}
+	f, err := modfile.ParseLax(op.module.GoMod, data, nil)
+	if err != nil {
+		return ""
+	}
+	for _, r := range f.Require {
+		if r.Mod.Path == lawtestPath {
+			return r.Mod.Version
+		}
+	}
+	return ""
+}
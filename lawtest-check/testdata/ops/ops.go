@@ -0,0 +1,128 @@
+package ops
+
+import (
+	"fmt"
+	"iter"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"time"
+)
+
+type Config map[string]string
+
+// Merge is pure: it only builds a new map.
+func Merge(a, b Config) Config {
+	out := maps.Clone(a)
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+type Counter struct{ N int }
+
+var merges int
+
+// Add counts its calls in a package variable.
+func (c Counter) Add(o Counter) Counter {
+	merges++
+	return Counter{c.N + o.N}
+}
+
+// Label is not binary, and logs and reads the clock.
+func (c Counter) Label(name string) string {
+	log.Printf("labelling %s", name)
+	return fmt.Sprintf("%s@%d", name, time.Now().Unix())
+}
+
+type Stream struct {
+	ch   chan int
+	done func()
+}
+
+// Join reads from a channel, and Stream can't be compared at all.
+func Join(a, b Stream) Stream {
+	a.ch <- <-b.ch
+	return a
+}
+
+// Scale is pure: the helpers it calls are.
+func Scale(c Counter, by Counter) Counter {
+	return Counter{times(c.N, by.N)}
+}
+
+func times(a, b int) int { return a * b }
+
+// Sum reaches its side effects through two helpers.
+func Sum(a, b Counter) Counter {
+	return Counter{count(a.N + b.N)}
+}
+
+func count(n int) int {
+	return record(n)
+}
+
+func record(n int) int {
+	merges++
+	if rand.IntN(2) == 0 {
+		fmt.Println(n)
+	}
+	return n
+}
+
+type Combiner interface{ Combine(a, b int) int }
+
+var combiner Combiner
+
+// Combine calls through an interface it can't follow.
+func Combine(a, b Counter) Counter {
+	return Counter{combiner.Combine(a.N, b.N)}
+}
+
+// Tally counts strings. Its fields must agree: total is the sum of counts.
+type Tally struct {
+	counts map[string]int
+	total  int
+}
+
+// NewTally returns a Tally counting each of words once.
+func NewTally(words []string) *Tally {
+	t := &Tally{counts: make(map[string]int)}
+	for _, w := range words {
+		t.counts[w]++
+		t.total++
+	}
+	return t
+}
+
+// Add returns a Tally counting word once more.
+func (t *Tally) Add(word string) *Tally {
+	u := &Tally{counts: maps.Clone(t.counts), total: t.total + 1}
+	u.counts[word]++
+	return u
+}
+
+// Union returns a Tally with the counts of both.
+func (t *Tally) Union(o *Tally) *Tally {
+	u := &Tally{counts: maps.Clone(t.counts), total: t.total + o.total}
+	for w, n := range o.counts {
+		u.counts[w] += n
+	}
+	return u
+}
+
+// All returns the words and their counts.
+func (t *Tally) All() iter.Seq2[string, int] {
+	return maps.All(t.counts)
+}
+
+// Total returns the number of words counted.
+func (t *Tally) Total() int {
+	return t.total
+}
+
+// Watch can't be compared.
+func (t *Tally) Watch() <-chan int {
+	return nil
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexshd/lawtest-gen/shape"
+	"golang.org/x/tools/go/packages"
+)
+
+// Package groups the candidates and contracts found in one loaded Go
+// package.
+type Package struct {
+	Name       string
+	Path       string
+	Dir        string
+	Types      *types.Package
+	Candidates []Candidate
+	Contracts  []Contract // interfaces with documented laws, see contract.go
+}
+
+// loadOptions select the declarations loadPackages considers.
+type loadOptions struct {
+	// unexported includes unexported functions and methods of unexported
+	// types. They are mostly internal helpers whose arguments must keep
+	// invariants that random values break, so they are left out unless
+	// annotated with //lawtest:laws.
+	unexported bool
+}
+
+// loadCandidates is loadPackages with the default options.
+func loadCandidates(patterns []string) ([]Package, error) {
+	return loadPackages(patterns, loadOptions{})
+}
+
+// loadPackages loads the packages matched by patterns with full type
+// information and returns every lawtest candidate found in them.
+//
+// A pattern ending in .go is treated as a single file: its whole package is
+// type-checked (so types declared in sibling files resolve), but only
+// candidates declared in that file are reported.
+func loadPackages(patterns []string, opts loadOptions) ([]Package, error) {
+	var queries []string
+	onlyFiles := make(map[string]bool)
+	for _, p := range patterns {
+		if strings.HasSuffix(p, ".go") {
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := os.Stat(abs); err != nil {
+				return nil, err
+			}
+			onlyFiles[abs] = true
+			queries = append(queries, "file="+abs)
+			continue
+		}
+		queries = append(queries, p)
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo,
+	}
+	pkgs, err := packages.Load(cfg, queries...)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("packages contain errors")
+	}
+
+	var result []Package
+	for _, pkg := range pkgs {
+		p := Package{Name: pkg.Name, Path: pkg.PkgPath, Types: pkg.Types}
+		if len(pkg.GoFiles) > 0 {
+			p.Dir = filepath.Dir(pkg.GoFiles[0])
+		}
+		for _, file := range pkg.Syntax {
+			filename := pkg.Fset.Position(file.Pos()).Filename
+			if len(onlyFiles) > 0 && !onlyFiles[filename] {
+				continue
+			}
+			candidates, err := analyzeFile(pkg, file, opts)
+			if err != nil {
+				return nil, err
+			}
+			p.Candidates = append(p.Candidates, candidates...)
+		}
+		inferAbsorption(p.Candidates)
+		inferPairwise(p.Candidates)
+		inferCommutation(p.Candidates)
+		if err := checkAnnotations(p.Candidates); err != nil {
+			return nil, err
+		}
+		p.Contracts = findContracts(pkg, onlyFiles)
+		if len(p.Candidates) > 0 || len(p.Contracts) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// analyzeFile returns the candidates declared in one file of pkg. Laws
+// declared with //lawtest:laws replace the inferred ones, and functions
+// marked //lawtest:ignore are skipped (see annotate.go), as are unexported
+// ones unless opts include them.
+func analyzeFile(pkg *packages.Package, file *ast.File, opts loadOptions) ([]Candidate, error) {
+	var candidates []Candidate
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		obj, ok := pkg.TypesInfo.Defs[fn.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		c := analyzeFunc(obj, pkg.Types)
+		if c == nil {
+			continue
+		}
+		c.Pos = pkg.Fset.Position(fn.Pos())
+		dirs, err := parseDirectives(fn.Doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", relPath(c.Pos.Filename), c.Pos.Line, err)
+		}
+		switch {
+		case dirs.ignore:
+			continue
+		case dirs.laws == nil && !opts.unexported && !exported(obj):
+			continue
+		case dirs.laws != nil:
+			if err := applyAnnotation(c, dirs.laws, pkg.Types.Scope()); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", relPath(c.Pos.Filename), c.Pos.Line, err)
+			}
+		case c.Fold != nil:
+			inferFoldLaws(c)
+		case c.Transition != nil:
+			inferTransitionLaws(c)
+		default:
+			inferLaws(c, fn, funcComments(file, fn), pkg.TypesInfo, pkg.Types.Scope())
+		}
+		candidates = append(candidates, *c)
+	}
+	return candidates, nil
+}
+
+// exported reports whether fn is exported and, for a method, so is its
+// receiver type.
+func exported(fn *types.Func) bool {
+	if !fn.Exported() {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return true
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return !ok || named.Obj().Exported()
+}
+
+// funcComments returns the text of fn's doc comment and of every comment
+// inside its body.
+func funcComments(file *ast.File, fn *ast.FuncDecl) string {
+	var sb strings.Builder
+	if fn.Doc != nil {
+		sb.WriteString(fn.Doc.Text())
+	}
+	for _, cg := range file.Comments {
+		if cg.Pos() > fn.Pos() && cg.End() < fn.End() {
+			sb.WriteString(cg.Text())
+		}
+	}
+	return sb.String()
+}
+
+// analyzeFunc reports whether fn has the shape of a binary operation:
+// func(T, T) T or func (T) Method(T) T, or of a variadic fold (see Fold).
+// Operations may also take a context.Context first and return an error
+// last, like func(ctx context.Context, a, b T) (T, error). The shapes are
+// matched by package shape, which the inputmutation analyzer shares.
+//
+// Generic functions and methods of generic types are instantiated with
+// representative type arguments first (see typeArguments), and the candidate
+// describes that instantiation.
+func analyzeFunc(fn *types.Func, pkg *types.Package) *Candidate {
+	sig := fn.Type().(*types.Signature)
+	op := shape.Match(sig)
+	if op == nil {
+		return nil
+	}
+	generic := op.Type
+	tparams := sig.TypeParams()
+	if sig.Recv() != nil {
+		tparams = sig.RecvTypeParams()
+	}
+	var typeArgs []types.Type
+	if tparams.Len() > 0 {
+		typeArgs = typeArguments(tparams)
+		if sig = instantiate(fn, typeArgs); sig == nil {
+			return nil
+		}
+		if op = shape.Match(sig); op == nil {
+			return nil
+		}
+	}
+	qualifier := types.RelativeTo(pkg)
+
+	receiver := ""
+	if sig.Recv() != nil {
+		receiver = types.TypeString(op.Type, qualifier)
+	}
+	c := newCandidate(fn.Name(), op.Type, receiver, typeArgs, qualifier)
+	switch op.Kind {
+	case shape.Fold, shape.Action:
+		c.Fold = &Fold{Elem: op.Elem, Action: op.Kind == shape.Action}
+	case shape.Transition:
+		c.Transition = &Transition{Args: op.Inputs[1:]}
+	}
+	c.TakesContext, c.ReturnsError = op.TakesContext, op.ReturnsError
+	return withGeneric(c, generic)
+}
+
+func newCandidate(name string, t types.Type, receiver string, typeArgs []types.Type, qualifier types.Qualifier) *Candidate {
+	comparable, known := isComparable(t)
+	return &Candidate{
+		FuncName:     name,
+		TypeName:     types.TypeString(t, qualifier),
+		IsComparable: comparable,
+		NeedsWrapper: known && !comparable,
+		Receiver:     receiver,
+		Type:         t,
+		TypeArgs:     typeArgs,
+	}
+}
+
+// withGeneric records whether the comparability of c's instantiation holds
+// for every instantiation of the generic operand type.
+func withGeneric(c *Candidate, generic types.Type) *Candidate {
+	if len(c.TypeArgs) > 0 {
+		// Not isComparable: a type parameter's underlying type is its
+		// constraint interface, but comparability follows its type set
+		c.ComparabilityVaries = types.Comparable(generic) != c.IsComparable
+	}
+	return c
+}
+
+// representativeTypes are tried in turn as type arguments. Each type
+// parameter starts at a different offset so that, for example, a
+// map[K]V is instantiated as map[int]string rather than map[int]int.
+var representativeTypes = []types.Type{
+	types.Typ[types.Int],
+	types.Typ[types.String],
+	types.Typ[types.Float64],
+	types.Typ[types.Bool],
+}
+
+// typeArguments picks a type argument for each type parameter: the first
+// representative type that satisfies its constraint or, for constraints
+// with a core type such as ~[]E, that core type over the arguments already
+// picked. It returns nil if some parameter can't be satisfied.
+func typeArguments(tparams *types.TypeParamList) []types.Type {
+	args := make([]types.Type, tparams.Len())
+	for pass := 0; pass < 2; pass++ {
+		for i := range args {
+			if args[i] != nil {
+				continue
+			}
+			iface := tparams.At(i).Constraint().Underlying().(*types.Interface)
+			if core := coreTerm(iface); core != nil {
+				// ~[]E needs E first, which may come later in the list
+				if pass == 1 {
+					args[i] = substitute(core, tparams, args)
+				}
+				continue
+			}
+			for j := range representativeTypes {
+				t := representativeTypes[(i+j)%len(representativeTypes)]
+				if types.Satisfies(t, iface) {
+					args[i] = t
+					break
+				}
+			}
+		}
+	}
+	for _, a := range args {
+		if a == nil || typeParamIn(a) {
+			return nil
+		}
+	}
+	return args
+}
+
+// coreTerm returns T for a constraint made of the single term ~T or T where
+// T is not a basic type (basic types are covered by representativeTypes).
+func coreTerm(iface *types.Interface) types.Type {
+	if iface.NumEmbeddeds() != 1 || iface.NumExplicitMethods() > 0 {
+		return nil
+	}
+	t := iface.EmbeddedType(0)
+	if u, ok := t.(*types.Union); ok {
+		if u.Len() != 1 {
+			return nil
+		}
+		t = u.Term(0).Type()
+	}
+	if _, ok := t.Underlying().(*types.Basic); ok || types.IsInterface(t) {
+		return nil
+	}
+	return t
+}
+
+// substitute replaces the type parameters in t by args. Only the composite
+// types that appear in constraints like ~[]E or ~map[K]V are handled; other
+// types are returned as they are.
+func substitute(t types.Type, tparams *types.TypeParamList, args []types.Type) types.Type {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		if i := t.Index(); i < len(args) && tparams.At(i) == t && args[i] != nil {
+			return args[i]
+		}
+	case *types.Slice:
+		return types.NewSlice(substitute(t.Elem(), tparams, args))
+	case *types.Pointer:
+		return types.NewPointer(substitute(t.Elem(), tparams, args))
+	case *types.Array:
+		return types.NewArray(substitute(t.Elem(), tparams, args), t.Len())
+	case *types.Map:
+		return types.NewMap(substitute(t.Key(), tparams, args), substitute(t.Elem(), tparams, args))
+	}
+	return t
+}
+
+func typeParamIn(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Slice:
+		return typeParamIn(t.Elem())
+	case *types.Pointer:
+		return typeParamIn(t.Elem())
+	case *types.Array:
+		return typeParamIn(t.Elem())
+	case *types.Map:
+		return typeParamIn(t.Key()) || typeParamIn(t.Elem())
+	}
+	return false
+}
+
+// instantiate returns the signature of fn with its type parameters, or those
+// of its receiver type, replaced by args. It returns nil if args don't
+// satisfy the constraints.
+func instantiate(fn *types.Func, args []types.Type) *types.Signature {
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil {
+		inst, err := types.Instantiate(nil, sig, args, true)
+		if err != nil {
+			return nil
+		}
+		return inst.(*types.Signature)
+	}
+
+	recv := sig.Recv().Type()
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok {
+		return nil
+	}
+	inst, err := types.Instantiate(nil, named.Origin(), args, true)
+	if err != nil {
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(inst, true, fn.Pkg(), fn.Name())
+	method, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	return method.Type().(*types.Signature)
+}
+
+// isComparable reports whether values of t can be compared with ==.
+// The second result is false when the answer depends on dynamic values,
+// as with interfaces, whose comparison panics for non-comparable contents.
+func isComparable(t types.Type) (comparable, known bool) {
+	if types.IsInterface(t) {
+		return false, false
+	}
+	return types.Comparable(t), true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoadCandidates(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./examples"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("Expected 1 package, got %d", len(pkgs))
+	}
+
+	want := map[string]struct {
+		typeName     string
+		isComparable bool
+		needsWrapper bool
+	}{
+		"Add":            {"int", true, false},
+		"Concat":         {"string", true, false},
+		"MergeMap":       {"map[string]string", false, true},
+		"(State).Merge":  {"State", true, false},
+		"(Items).Merge":  {"Items", false, true},
+		"(Items).Append": {"Items", false, true},
+	}
+
+	got := pkgs[0].Candidates
+	if len(got) != len(want) {
+		t.Errorf("Expected %d candidates, got %d", len(want), len(got))
+	}
+	for _, c := range got {
+		w, ok := want[displayName(c)]
+		if !ok {
+			t.Errorf("Unexpected candidate %s", displayName(c))
+			continue
+		}
+		if c.TypeName != w.typeName {
+			t.Errorf("%s: expected type %s, got %s", displayName(c), w.typeName, c.TypeName)
+		}
+		if c.IsComparable != w.isComparable || c.NeedsWrapper != w.needsWrapper {
+			t.Errorf("%s: expected comparable=%v wrapper=%v, got comparable=%v wrapper=%v",
+				displayName(c), w.isComparable, w.needsWrapper, c.IsComparable, c.NeedsWrapper)
+		}
+	}
+}
+
+func TestLoadCandidatesSingleFile(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"examples/slice_example.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].Candidates) != 2 {
+		t.Fatalf("Expected only the candidates from slice_example.go, got %+v", pkgs)
+	}
+	for i, want := range []string{"(Items).Merge", "(Items).Append"} {
+		if c := pkgs[0].Candidates[i]; displayName(c) != want {
+			t.Errorf("Expected %s, got %s", want, displayName(c))
+		}
+	}
+}
+
+func TestLoadGenericCandidates(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/generic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("Expected 1 package, got %d", len(pkgs))
+	}
+
+	want := map[string]struct {
+		typeName string
+		instance string
+		varies   bool
+	}{
+		"Sum":              {"int", "Sum[int]", false},
+		"MergeMaps":        {"map[int]string", "MergeMaps[int, string]", false},
+		"Concat":           {"[]string", "Concat[[]string, string]", false},
+		"(Set[int]).Union": {"Set[int]", "Set[int].Union", false},
+		"(Pair[int]).Zip":  {"Pair[int]", "Pair[int].Zip", true},
+	}
+
+	got := pkgs[0].Candidates
+	if len(got) != len(want) {
+		t.Errorf("Expected %d candidates, got %d", len(want), len(got))
+	}
+	for _, c := range got {
+		w, ok := want[displayName(c)]
+		if !ok {
+			t.Errorf("Unexpected candidate %s", displayName(c))
+			continue
+		}
+		if c.TypeName != w.typeName || instanceName(c) != w.instance {
+			t.Errorf("%s: expected %s as %s, got %s as %s", displayName(c), w.typeName, w.instance, c.TypeName, instanceName(c))
+		}
+		if c.ComparabilityVaries != w.varies {
+			t.Errorf("%s: expected comparability varies=%v, got %v", displayName(c), w.varies, c.ComparabilityVaries)
+		}
+	}
+}
+
+func TestLoadFallibleCandidates(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/fallible"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct{ takesContext, returnsError bool }{
+		"Tighten": {false, true},
+		"Union":   {true, true},
+		"Add":     {true, false},
+	}
+	got := pkgs[0].Candidates
+	if len(got) != len(want) {
+		t.Errorf("Expected %d candidates, got %d", len(want), len(got))
+	}
+	for _, c := range got {
+		w, ok := want[c.FuncName]
+		if !ok {
+			t.Errorf("Unexpected candidate %s", c.FuncName)
+			continue
+		}
+		if c.TakesContext != w.takesContext || c.ReturnsError != w.returnsError {
+			t.Errorf("%s: expected context=%v error=%v, got context=%v error=%v",
+				c.FuncName, w.takesContext, w.returnsError, c.TakesContext, c.ReturnsError)
+		}
+		if (c.Law(lawStableErrors) != nil) != c.ReturnsError {
+			t.Errorf("%s: expected stable-errors law only for operations returning an error", c.FuncName)
+		}
+	}
+}
+
+func TestLoadUnexportedCandidates(t *testing.T) {
+	names := func(opts loadOptions) []string {
+		pkgs, err := loadPackages([]string{"./testdata/unexported"}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, c := range pkgs[0].Candidates {
+			names = append(names, displayName(c))
+		}
+		return names
+	}
+
+	// Helpers are left out unless annotated, whatever their shape
+	if got, want := names(loadOptions{}), []string{"(Tree).Union", "minInt"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v by default, got %v", want, got)
+	}
+	want := []string{"(Tree).Union", "(*node).union", "(*node).insert", "(*node).Merge", "maxInt", "minInt"}
+	if got := names(loadOptions{unexported: true}); !slices.Equal(got, want) {
+		t.Errorf("Expected %v with unexported, got %v", want, got)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Annotations let the author state the laws of an operation instead of
+// having them inferred:
+//
+//	//lawtest:laws associative,commutative,idempotent identity=Empty
+//	func Union(a, b Set) Set
+//
+//	//lawtest:ignore
+//	func Sub(a, b int) int
+//
+// Laws are separated by commas or spaces. Laws naming another value take it
+// after an equals sign: identity=Empty (a constructor, variable, constant
+// or Go expression; a bare identity uses the empty value), absorption=Union,
+// pairwise=Merge and commutation=Merge. Immutability is checked for every
+// candidate anyway.
+
+const (
+	directiveLaws   = "//lawtest:laws"
+	directiveIgnore = "//lawtest:ignore"
+)
+
+// annotatedLaws lists the laws each kind of candidate may declare.
+var annotatedLaws = map[string][]string{
+	"binary":     {lawImmutable, lawAssociative, lawCommutative, lawIdempotent, lawIdentity, lawAbsorption},
+	"error":      {lawImmutable, lawAssociative, lawCommutative, lawIdempotent, lawIdentity, lawStableErrors},
+	"fold":       {lawImmutable, lawAssociative, lawFlatten, lawPairwise},
+	"action":     {lawComposition},
+	"transition": {lawImmutable, lawDeterministic, lawCommutation},
+}
+
+// directives holds the lawtest directives in a doc comment. laws is nil
+// when there is no //lawtest:laws line.
+type directives struct {
+	ignore bool
+	laws   []string
+}
+
+// parseDirectives reads the //lawtest: lines of doc. CommentGroup.Text
+// drops directive lines, so they never reach the comment heuristics.
+func parseDirectives(doc *ast.CommentGroup) (directives, error) {
+	var d directives
+	if doc == nil {
+		return d, nil
+	}
+	for _, c := range doc.List {
+		switch {
+		case c.Text == directiveIgnore || strings.HasPrefix(c.Text, directiveIgnore+" "):
+			d.ignore = true
+		case strings.HasPrefix(c.Text, directiveLaws+" "):
+			d.laws = append(d.laws, strings.FieldsFunc(strings.TrimPrefix(c.Text, directiveLaws), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})...)
+		case strings.HasPrefix(c.Text, "//lawtest:"):
+			return d, fmt.Errorf("unknown directive %s (want %s or %s)", c.Text, directiveLaws, directiveIgnore)
+		}
+	}
+	if d.laws != nil && len(d.laws) == 0 {
+		return d, fmt.Errorf("%s lists no laws", directiveLaws)
+	}
+	return d, nil
+}
+
+// applyAnnotation sets the laws of c to the ones declared, after
+// immutability, in the order given.
+func applyAnnotation(c *Candidate, laws []string, scope *types.Scope) error {
+	kind := "binary"
+	switch {
+	case c.Fold != nil && c.Fold.Action:
+		kind = "action"
+	case c.Fold != nil:
+		kind = "fold"
+	case c.Transition != nil:
+		kind = "transition"
+	case c.ReturnsError:
+		kind = "error"
+	}
+	allowed := annotatedLaws[kind]
+	reason := "the doc comment declares it with " + directiveLaws
+
+	c.Annotated = true
+	c.Laws, c.Suggested = nil, nil
+	if kind != "action" {
+		c.addLaw(lawImmutable, "every candidate must leave its inputs untouched (Law I)")
+	}
+	for _, field := range laws {
+		name, value, _ := strings.Cut(field, "=")
+		if !contains(allowed, name) {
+			return fmt.Errorf("%s: law %q does not apply to %s (want one of %s)", c.FuncName, name, kind+" operations", strings.Join(allowed, ", "))
+		}
+		if c.Law(name) != nil {
+			continue
+		}
+		switch name {
+		case lawIdentity:
+			id, err := annotatedIdentity(c, value, scope)
+			if err != nil {
+				return err
+			}
+			c.addIdentity(id, reason)
+		case lawAbsorption, lawPairwise, lawCommutation:
+			if value == "" {
+				return fmt.Errorf("%s: %s needs the other operation, as in %s=Merge", c.FuncName, name, name)
+			}
+			law := Law{Name: name, Reason: reason}
+			switch name {
+			case lawAbsorption:
+				law.Dual = value
+			case lawPairwise:
+				law.Pairwise = value
+			default:
+				law.Merge = value
+			}
+			c.Laws = append(c.Laws, law)
+		default:
+			if value != "" {
+				return fmt.Errorf("%s: %s takes no value", c.FuncName, name)
+			}
+			c.addLaw(name, reason)
+		}
+	}
+	return nil
+}
+
+// annotatedIdentity resolves identity=value: a constructor taking only maps
+// and slices, any other name or expression used as is, or, when value is
+// empty, the empty value of the type.
+func annotatedIdentity(c *Candidate, value string, scope *types.Scope) (*Identity, error) {
+	if value == "" {
+		if ctor := emptyConstructor(c.Type, c.TypeArgs, scope); ctor != nil {
+			return &Identity{Constructor: ctor}, nil
+		}
+		if hasEmptyValue(c.Type) {
+			return &Identity{}, nil
+		}
+		return nil, fmt.Errorf("%s: %s has no obvious empty value, name the identity as in identity=Zero", c.FuncName, c.TypeName)
+	}
+	fn, ok := scope.Lookup(value).(*types.Func)
+	if !ok {
+		return &Identity{Value: value}, nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 {
+		sig = instantiate(fn, c.TypeArgs)
+	}
+	if sig == nil || sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), c.Type) {
+		return nil, fmt.Errorf("%s: identity constructor %s must return %s", c.FuncName, value, c.TypeName)
+	}
+	for i := range sig.Params().Len() {
+		switch sig.Params().At(i).Type().Underlying().(type) {
+		case *types.Map, *types.Slice:
+		default:
+			return nil, fmt.Errorf("%s: identity constructor %s must take no arguments or only maps and slices", c.FuncName, value)
+		}
+	}
+	return &Identity{Constructor: fn}, nil
+}
+
+// checkAnnotations makes sure the operations named by declared absorption,
+// pairwise and commutation laws exist.
+func checkAnnotations(candidates []Candidate) error {
+	for _, c := range candidates {
+		if !c.Annotated {
+			continue
+		}
+		for _, law := range c.Laws {
+			other := law.Dual + law.Pairwise + law.Merge
+			if other == "" {
+				continue
+			}
+			found := false
+			for _, d := range candidates {
+				if d.FuncName == other && d.binary() && !d.ReturnsError && types.Identical(d.Type, c.Type) {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s:%d: %s declares %s=%s, but %s is not a binary operation on %s",
+					relPath(c.Pos.Filename), c.Pos.Line, c.FuncName, law.Name, other, other, c.TypeName)
+			}
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
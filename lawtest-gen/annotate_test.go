@@ -0,0 +1,113 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestLoadAnnotatedCandidates(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/annotated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	laws := make(map[string][]string)
+	byName := make(map[string]Candidate)
+	for _, c := range pkgs[0].Candidates {
+		byName[c.FuncName] = c
+		for _, law := range c.Laws {
+			laws[c.FuncName] = append(laws[c.FuncName], law.Name)
+		}
+	}
+	if _, ok := byName["Sub"]; ok {
+		t.Error("Expected Sub to be ignored")
+	}
+
+	tests := map[string]string{
+		"Union":     "immutable associative commutative idempotent identity",
+		"Intersect": "immutable associative commutative absorption",
+		"Concat":    "immutable associative identity",
+	}
+	for name, want := range tests {
+		if got := strings.Join(laws[name], " "); got != want {
+			t.Errorf("%s: expected laws %q, got %q", name, want, got)
+		}
+		if !byName[name].Annotated {
+			t.Errorf("%s: expected Annotated", name)
+		}
+	}
+
+	if id := byName["Union"].Law(lawIdentity).Identity; id.Constructor == nil || id.Constructor.Name() != "Empty" {
+		t.Errorf("Expected Union's identity to be Empty(), got %+v", id)
+	}
+	if id := byName["Concat"].Law(lawIdentity).Identity; id.Value != `""` {
+		t.Errorf(`Expected Concat's identity to be "", got %+v`, id)
+	}
+	if law := byName["Intersect"].Law(lawAbsorption); law.Dual != "Union" || law.Reason != "the doc comment declares it with //lawtest:laws" {
+		t.Errorf("Expected Intersect to absorb Union as declared, got %+v", law)
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		src     string
+		laws    string
+		ignore  bool
+		wantErr bool
+	}{
+		{src: "// Merge merges.\n//\n//lawtest:laws associative, commutative identity=New", laws: "associative commutative identity=New"},
+		{src: "//lawtest:ignore not an operation", ignore: true},
+		{src: "// Mentions lawtest:laws in prose only."},
+		{src: "//lawtest:laws", wantErr: true},
+		{src: "//lawtest:law associative", wantErr: true},
+	}
+	for _, tt := range tests {
+		file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n\n"+tt.src+"\nfunc F() {}\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := parseDirectives(file.Decls[0].(*ast.FuncDecl).Doc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error %v", tt.src, err)
+			continue
+		}
+		if got := strings.Join(d.laws, " "); got != tt.laws || d.ignore != tt.ignore {
+			t.Errorf("%q: got laws %q, ignore %v", tt.src, got, d.ignore)
+		}
+	}
+}
+
+func TestApplyAnnotationRejectsUnknownLaws(t *testing.T) {
+	c := &Candidate{FuncName: "Merge", TypeName: "Config"}
+	if err := applyAnnotation(c, []string{"asociative"}, nil); err == nil || !strings.Contains(err.Error(), `"asociative"`) {
+		t.Errorf("Expected an error naming the unknown law, got %v", err)
+	}
+	if err := applyAnnotation(c, []string{"flatten"}, nil); err == nil {
+		t.Error("Expected flatten to be rejected for a binary operation")
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	var outputs []string
+	for range 3 {
+		pkgs, err := loadCandidates([]string{"./testdata/annotated", "./testdata/fold", "./testdata/generic"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sb strings.Builder
+		for _, pkg := range pkgs {
+			src, err := newGenerator(pkg).generateTestFile(pkg.Name, pkg.Candidates, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sb.WriteString(src)
+		}
+		outputs = append(outputs, sb.String())
+	}
+	if outputs[0] != outputs[1] || outputs[1] != outputs[2] {
+		t.Error("Expected generating the same packages to produce identical output")
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const lawtestPath = "github.com/alexshd/lawtest"
+
+// Uncovered is a candidate that no test checks with lawtest.
+type Uncovered struct {
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Type     string `json:"type"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// CheckReport is the result of -check.
+type CheckReport struct {
+	Candidates int         `json:"candidates"`
+	Covered    int         `json:"covered"`
+	Uncovered  []Uncovered `json:"uncovered"`
+}
+
+// checkCoverage reports the candidates in pkgs that no test covers.
+//
+// A candidate is covered when a Test function that uses lawtest refers to
+// it, either directly (lawtest.Associative(t, Add, gen), a.Merge(b) in a
+// hand-written loop) or through a Wrap* function, like WrapMerge, or a
+// helper declared in the test files, like a generated adapter, that does.
+func checkCoverage(pkgs []Package, patterns []string) (CheckReport, error) {
+	covered, err := coveredFuncs(patterns)
+	if err != nil {
+		return CheckReport{}, err
+	}
+
+	report := CheckReport{Uncovered: []Uncovered{}}
+	for _, pkg := range pkgs {
+		for _, c := range pkg.Candidates {
+			report.Candidates++
+			if covered[funcKey(c.Pos)] {
+				report.Covered++
+				continue
+			}
+			report.Uncovered = append(report.Uncovered, Uncovered{
+				Package:  pkg.Path,
+				Function: displayName(c),
+				Type:     c.TypeName,
+				File:     relPath(c.Pos.Filename),
+				Line:     c.Pos.Line,
+			})
+		}
+	}
+	return report, nil
+}
+
+// coveredFuncs loads the test variants of the packages matched by patterns
+// and returns the keys (see funcKey) of every function a lawtest test uses.
+func coveredFuncs(patterns []string) (map[string]bool, error) {
+	files, err := loadTestFiles(patterns, true)
+	if err != nil {
+		return nil, err
+	}
+	covered := make(map[string]bool)
+	for _, file := range files {
+		maps.Copy(covered, file.covered)
+	}
+	return covered, nil
+}
+
+// testFile is what a _test.go file holds.
+type testFile struct {
+	decls   map[string]bool // declNames, empty for external tests
+	covered map[string]bool // funcKeys of the functions its lawtest tests use
+}
+
+// loadTestFiles loads the test variants of the packages matched by patterns
+// and indexes their _test.go files by filename. Unless strict, packages with
+// errors are indexed as far as they could be type-checked: test files
+// redeclaring each other are what the index helps to avoid.
+func loadTestFiles(patterns []string, strict bool) (map[string]testFile, error) {
+	var queries []string
+	for _, p := range patterns {
+		if strings.HasSuffix(p, ".go") {
+			// Tests live next to the file, not in it
+			p = filepath.Dir(p)
+			if !filepath.IsAbs(p) && !strings.HasPrefix(p, ".") {
+				p = "./" + p
+			}
+		}
+		queries = append(queries, p)
+	}
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, queries...)
+	if err != nil {
+		return nil, err
+	}
+	if strict && packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("packages contain errors")
+	}
+
+	files := make(map[string]testFile)
+	for _, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		wrappers := wrapperDecls(pkg)
+		for _, file := range pkg.Syntax {
+			filename := pkg.Fset.Position(file.Pos()).Filename
+			if !strings.HasSuffix(filename, "_test.go") {
+				continue
+			}
+			tf := testFile{decls: make(map[string]bool), covered: make(map[string]bool)}
+			if !strings.HasSuffix(file.Name.Name, "_test") {
+				tf.decls = declNames(file)
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || fn.Body == nil || !strings.HasPrefix(fn.Name.Name, "Test") {
+					continue
+				}
+				if usesLawtest(fn, pkg.TypesInfo) {
+					m := &opMarker{pkg: pkg, wrappers: wrappers, covered: tf.covered, seen: make(map[ast.Node]bool)}
+					m.markTest(fn)
+				}
+			}
+			files[filename] = tf
+		}
+	}
+	return files, nil
+}
+
+// others returns what the test files in the directory of testFilename,
+// except testFilename, declare and cover of candidates.
+func others(files map[string]testFile, testFilename string, candidates []Candidate) otherTests {
+	result := otherTests{decls: make(map[string]bool), covered: make(map[string]bool)}
+	for filename, file := range files {
+		if filename == testFilename || filepath.Dir(filename) != filepath.Dir(testFilename) {
+			continue
+		}
+		maps.Copy(result.decls, file.decls)
+		for _, c := range candidates {
+			if file.covered[funcKey(c.Pos)] {
+				result.covered[testName(c)] = true
+			}
+		}
+	}
+	return result
+}
+
+// wrapperDecls returns the functions through which tests may call a
+// candidate, by object: Wrap* functions and every function in test files.
+func wrapperDecls(pkg *packages.Package) map[types.Object]*ast.FuncDecl {
+	wrappers := make(map[types.Object]*ast.FuncDecl)
+	for _, file := range pkg.Syntax {
+		isTest := strings.HasSuffix(pkg.Fset.Position(file.Pos()).Filename, "_test.go")
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if ok && fn.Recv == nil && fn.Body != nil && (isTest || strings.HasPrefix(fn.Name.Name, "Wrap")) {
+				wrappers[pkg.TypesInfo.Defs[fn.Name]] = fn
+			}
+		}
+	}
+	return wrappers
+}
+
+func usesLawtest(fn *ast.FuncDecl, info *types.Info) bool {
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := info.Uses[id]; obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == lawtestPath {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// opMarker records the operations tests check with lawtest, as funcKeys
+// in covered. Generators and other helpers the tests call are not
+// followed: a candidate that only builds test values isn't covered.
+type opMarker struct {
+	pkg      *packages.Package
+	wrappers map[types.Object]*ast.FuncDecl
+	covered  map[string]bool
+	seen     map[ast.Node]bool
+	vars     map[types.Object]ast.Expr // the values local variables are assigned
+}
+
+// markTest marks the operation argument of fn's lawtest checks, which
+// comes right after t, and the calls in the loops over
+// lawtest.DefaultConfig().TestCases that lawtest-gen writes where lawtest's
+// checks need comparable values.
+func (m *opMarker) markTest(fn *ast.FuncDecl) {
+	m.vars = make(map[types.Object]ast.Expr)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok {
+						m.vars[m.pkg.TypesInfo.ObjectOf(id)] = n.Rhs[i]
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i, id := range n.Names {
+					m.vars[m.pkg.TypesInfo.Defs[id]] = n.Values[i]
+				}
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if m.isLawtest(n.Fun) && len(n.Args) > 1 {
+				m.markOp(n.Args[1])
+			}
+		case *ast.RangeStmt:
+			if sel, ok := n.X.(*ast.SelectorExpr); ok && sel.Sel.Name == "TestCases" {
+				if call, ok := sel.X.(*ast.CallExpr); ok && m.isLawtest(call.Fun) {
+					m.markLoop(n.Body)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// markOp marks the functions the operation e calls: e itself, the body
+// of a closure or of a function declared in a test file, what an
+// operation factory such as a Wrap* function is passed, or the value of a
+// local variable.
+func (m *opMarker) markOp(e ast.Expr) {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		switch obj := m.pkg.TypesInfo.Uses[e].(type) {
+		case *types.Func:
+			m.covered[funcKey(m.pkg.Fset.Position(obj.Pos()))] = true
+			if w := m.wrappers[obj]; w != nil {
+				m.markCalls(w.Body)
+			}
+		case *types.Var:
+			if v := m.vars[obj]; v != nil && !m.seen[v] {
+				m.seen[v] = true
+				m.markOp(v)
+			}
+		}
+	case *ast.SelectorExpr:
+		m.markOp(e.Sel)
+	case *ast.IndexExpr:
+		m.markOp(e.X)
+	case *ast.IndexListExpr:
+		m.markOp(e.X)
+	case *ast.FuncLit:
+		m.markCalls(e.Body)
+	case *ast.CallExpr:
+		m.markOp(e.Fun)
+		for _, arg := range e.Args {
+			m.markOp(arg)
+		}
+	}
+}
+
+// markCalls marks the functions called in body, an operation's.
+func (m *opMarker) markCalls(body *ast.BlockStmt) {
+	if m.seen[body] {
+		return
+	}
+	m.seen[body] = true
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			m.markOp(call.Fun)
+		}
+		return true
+	})
+}
+
+// markLoop marks the functions called in the body of a lawtest-gen loop,
+// following Wrap* functions but not generators.
+func (m *opMarker) markLoop(body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fun := ast.Unparen(call.Fun)
+		if sel, ok := fun.(*ast.SelectorExpr); ok {
+			fun = sel.Sel
+		}
+		if id, ok := fun.(*ast.Ident); ok {
+			if obj, ok := m.pkg.TypesInfo.Uses[id].(*types.Func); ok {
+				m.covered[funcKey(m.pkg.Fset.Position(obj.Pos()))] = true
+				if w := m.wrappers[obj]; w != nil && strings.HasPrefix(w.Name.Name, "Wrap") {
+					m.markCalls(w.Body)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// isLawtest reports whether fun is a function of the lawtest package.
+func (m *opMarker) isLawtest(fun ast.Expr) bool {
+	sel, ok := ast.Unparen(fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	obj := m.pkg.TypesInfo.Uses[sel.Sel]
+	return obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == lawtestPath
+}
+
+// funcKey identifies a function declaration across the regular and test
+// variants of a package, whose types.Func objects differ.
+func funcKey(pos token.Position) string {
+	return fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+}
+
+func relPath(filename string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filename)
+}
+
+// writeCheckReport writes report as JSON or SARIF 2.1.0.
+func writeCheckReport(w io.Writer, report CheckReport, format string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	switch format {
+	case "json":
+		return enc.Encode(report)
+	case "sarif":
+		return enc.Encode(sarifReport(report))
+	}
+	return fmt.Errorf("unknown report format %q (want json or sarif)", format)
+}
+
+// sarifReport converts report to a SARIF log with one result per uncovered
+// candidate, so code scanning tools can annotate the declarations.
+func sarifReport(report CheckReport) map[string]any {
+	results := []map[string]any{}
+	for _, u := range report.Uncovered {
+		results = append(results, map[string]any{
+			"ruleId": "uncovered-law-candidate",
+			"level":  "error",
+			"message": map[string]any{
+				"text": fmt.Sprintf("%s looks like a binary operation on %s but no lawtest test covers it", u.Function, u.Type),
+			},
+			"locations": []map[string]any{{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]any{"uri": u.File},
+					"region":           map[string]any{"startLine": u.Line},
+				},
+			}},
+		})
+	}
+	return map[string]any{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "lawtest-gen",
+					"informationUri": "https://github.com/alexshd/lawtest",
+					"rules": []map[string]any{{
+						"id":               "uncovered-law-candidate",
+						"shortDescription": map[string]any{"text": "Binary operation without lawtest coverage"},
+					}},
+				},
+			},
+			"results": results,
+		}},
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestCheckCoverage(t *testing.T) {
+	patterns := []string{"./testdata/coverage"}
+	pkgs, err := loadCandidates(patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := checkCoverage(pkgs, patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Candidates != 4 || report.Covered != 2 {
+		t.Errorf("Expected 2 of 4 candidates covered, got %d of %d", report.Covered, report.Candidates)
+	}
+	var uncovered []string
+	for _, u := range report.Uncovered {
+		uncovered = append(uncovered, u.Function)
+	}
+	if !slices.Equal(uncovered, []string{"Sub", "Max"}) {
+		t.Fatalf("Expected Sub and Max, only used in a generator, uncovered, got %+v", report.Uncovered)
+	}
+	if u := report.Uncovered[0]; u.File != "testdata/coverage/ops.go" || u.Line != 12 {
+		t.Errorf("Expected testdata/coverage/ops.go:12, got %s:%d", u.File, u.Line)
+	}
+}
+
+func TestWriteCheckReportSARIF(t *testing.T) {
+	report := CheckReport{
+		Candidates: 1,
+		Uncovered:  []Uncovered{{Package: "example", Function: "Sub", Type: "int", File: "ops.go", Line: 12}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCheckReport(&buf, report, "sarif"); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("Expected one SARIF 2.1.0 result, got %s", buf.String())
+	}
+	loc := log.Runs[0].Results[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "ops.go" || loc.Region.StartLine != 12 {
+		t.Errorf("Expected ops.go:12, got %s:%d", loc.ArtifactLocation.URI, loc.Region.StartLine)
+	}
+
+	if err := writeCheckReport(&buf, report, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
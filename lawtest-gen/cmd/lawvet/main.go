@@ -0,0 +1,17 @@
+// Command lawvet runs the inputmutation analyzer, reporting law candidates
+// that write into their inputs.
+//
+// It runs on its own or as a go vet tool:
+//
+//	lawvet ./...
+//	go vet -vettool=$(which lawvet) ./...
+package main
+
+import (
+	"github.com/alexshd/lawtest-gen/inputmutation"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(inputmutation.Analyzer)
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Contract is an interface whose documented laws the type checker can't
+// verify, such as faulttest.ImmutableStore. lawtest-gen turns it into a
+// conformance suite, <Name>Conformance(t, factory), that every
+// implementation can run.
+type Contract struct {
+	Name string
+	Type *types.Named
+	Pos  token.Position
+
+	Binary      []ContractMethod // Merge(other I) I
+	Transitions []ContractMethod // Set(key, value string) I
+	Observers   []*types.Func    // Get(key string) (string, bool), Len() int: how stores are compared
+
+	Parallel bool   // documented as safe for concurrent use
+	Impls    []Impl // implementations in the package with an empty constructor
+}
+
+// ContractMethod is a method of a Contract returning the interface, with
+// the laws proposed for it.
+type ContractMethod struct {
+	Method *types.Func
+	Laws   []Law
+}
+
+// Impl is a type implementing a Contract, built empty by Factory.
+type Impl struct {
+	TypeName string
+	Factory  *types.Func
+	Pos      token.Position
+}
+
+// parallelWords mark an interface as documented to be safe for concurrent use.
+var parallelWords = []string{"parallel", "concurrent", "goroutine", "thread-safe", "thread safe"}
+
+// findContracts returns the interfaces declared in files (all files of pkg
+// when files is empty) that have methods returning the interface and
+// observers to compare results with.
+func findContracts(pkg *packages.Package, files map[string]bool) []Contract {
+	var contracts []Contract
+	for _, file := range pkg.Syntax {
+		if len(files) > 0 && !files[pkg.Fset.Position(file.Pos()).Filename] {
+			continue
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok || ts.TypeParams != nil {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+				if c := analyzeContract(pkg, ts, it, doc); c != nil {
+					contracts = append(contracts, *c)
+				}
+			}
+		}
+	}
+	return contracts
+}
+
+func analyzeContract(pkg *packages.Package, ts *ast.TypeSpec, it *ast.InterfaceType, doc *ast.CommentGroup) *Contract {
+	named, ok := pkg.TypesInfo.Defs[ts.Name].Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	iface := named.Underlying().(*types.Interface)
+	docs := make(map[string]string)
+	for _, f := range it.Methods.List {
+		for _, n := range f.Names {
+			docs[n.Name] = f.Doc.Text() + f.Comment.Text()
+		}
+	}
+	ifaceDoc := strings.ToLower(doc.Text())
+
+	c := &Contract{
+		Name:     ts.Name.Name,
+		Type:     named,
+		Pos:      pkg.Fset.Position(ts.Pos()),
+		Parallel: mentions(ifaceDoc, parallelWords...),
+	}
+	for i := range iface.NumMethods() {
+		m := iface.Method(i)
+		sig := m.Type().(*types.Signature)
+		returnsSelf := sig.Results().Len() == 1 && types.Identical(sig.Results().At(0).Type(), named)
+		takesSelf := false
+		for j := range sig.Params().Len() {
+			if types.Identical(sig.Params().At(j).Type(), named) {
+				takesSelf = true
+			}
+		}
+		comments := ifaceDoc + strings.ToLower(docs[m.Name()])
+		switch {
+		case returnsSelf && sig.Params().Len() == 1 && takesSelf:
+			c.Binary = append(c.Binary, ContractMethod{Method: m, Laws: contractLaws(c.Name, m.Name(), comments)})
+		case returnsSelf && !takesSelf && !sig.Variadic():
+			c.Transitions = append(c.Transitions, ContractMethod{Method: m, Laws: []Law{
+				{Name: lawImmutable, Reason: "methods returning a new " + c.Name + " must leave the receiver untouched"},
+				{Name: lawDeterministic, Reason: "the same update applied to the same " + c.Name + " must give the same result"},
+			}})
+		case isObserver(sig, named):
+			c.Observers = append(c.Observers, m)
+		}
+	}
+	// Values to check the laws on are built with the transitions
+	if len(c.Transitions) == 0 || len(c.Observers) == 0 {
+		return nil
+	}
+	c.Impls = findImpls(pkg, named)
+	return c
+}
+
+// contractLaws proposes laws for a binary method of a contract from the
+// interface and method documentation: immutability and associativity, as
+// for any func(T, T) T, and the others where they are documented.
+func contractLaws(iface, name, comments string) []Law {
+	laws := []Law{{Name: lawImmutable, Reason: "every candidate must leave its inputs untouched (Law I)"}}
+	if !mentions(comments, "not associative", "non-associative") {
+		laws = append(laws, Law{Name: lawAssociative, Reason: name + " combines two " + iface + " values, which is only safe to chain if grouping doesn't matter"})
+	}
+	if mentions(comments, "commutative") && !mentions(comments, "not commutative", "non-commutative") {
+		laws = append(laws, Law{Name: lawCommutative, Reason: "the documentation describes " + name + " as commutative"})
+	}
+	if mentions(comments, "idempotent") {
+		laws = append(laws, Law{Name: lawIdempotent, Reason: "the documentation describes " + name + " as idempotent"})
+	}
+	if mentions(comments, "identity") {
+		laws = append(laws, Law{Name: lawIdentity, Reason: "the documentation gives " + name + " an identity, the empty value from the factory"})
+	}
+	return laws
+}
+
+// isObserver reports whether sig reads a value without returning the
+// interface, taking at most one argument of a comparable type. Funcs and
+// channels never compare equal, so the results can't hold any, except for
+// a single iter.Seq2 with comparable keys, which is collected into a map.
+func isObserver(sig *types.Signature, self types.Type) bool {
+	if sig.Results().Len() == 0 || sig.Params().Len() > 1 || sig.Variadic() {
+		return false
+	}
+	for i := range sig.Results().Len() {
+		t := sig.Results().At(i).Type()
+		if types.Identical(t, self) {
+			return false
+		}
+		switch t.Underlying().(type) {
+		case *types.Signature, *types.Chan:
+			if sig.Results().Len() > 1 || !isSeq2(t) {
+				return false
+			}
+		}
+	}
+	return sig.Params().Len() == 0 || types.Comparable(sig.Params().At(0).Type())
+}
+
+// isSeq2 reports whether t is an iterator over pairs, like iter.Seq2,
+// with a comparable key, so that maps.Collect accepts it.
+func isSeq2(t types.Type) bool {
+	sig, ok := t.Underlying().(*types.Signature)
+	if !ok || sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+		return false
+	}
+	yield, ok := sig.Params().At(0).Type().Underlying().(*types.Signature)
+	if !ok || yield.Params().Len() != 2 || yield.Results().Len() != 1 {
+		return false
+	}
+	return types.Identical(yield.Results().At(0).Type(), types.Typ[types.Bool]) &&
+		types.Comparable(yield.Params().At(0).Type())
+}
+
+// observes reports whether an observer of c takes an argument of type t.
+func (c Contract) observes(t types.Type) bool {
+	for _, m := range c.Observers {
+		sig := m.Type().(*types.Signature)
+		if sig.Params().Len() == 1 && types.Identical(sig.Params().At(0).Type(), t) {
+			return true
+		}
+	}
+	return false
+}
+
+// findImpls returns the named types of pkg implementing iface, directly or
+// through a pointer, that have a constructor building an empty value.
+func findImpls(pkg *packages.Package, iface *types.Named) []Impl {
+	var impls []Impl
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		for _, t := range []types.Type{named, types.NewPointer(named)} {
+			if !types.Implements(t, iface.Underlying().(*types.Interface)) {
+				continue
+			}
+			if ctor := emptyConstructor(t, nil, scope); ctor != nil {
+				impls = append(impls, Impl{TypeName: name, Factory: ctor, Pos: pkg.Fset.Position(tn.Pos())})
+				break
+			}
+		}
+	}
+	sort.Slice(impls, func(i, j int) bool { return impls[i].Pos.Offset < impls[j].Pos.Offset })
+	return impls
+}
+
+// generateConformanceFile writes the conformance suite of c. It is a
+// regular (non-test) file so implementations in other packages can call
+// it, and it declares nothing but the suite so it never clashes with the
+// helpers of the _law_test.go files.
+func (g *generator) generateConformanceFile(pkgName string, c Contract) (string, error) {
+	var body strings.Builder
+	g.sb = &body
+	g.imports = map[string]string{
+		"reflect":                    "reflect",
+		"testing":                    "testing",
+		"github.com/alexshd/lawtest": "lawtest",
+	}
+	if c.Parallel {
+		g.imports["sync"] = "sync"
+	}
+	g.writeConformance(c)
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by lawtest-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&sb, "package %s\n\n", pkgName)
+	sb.WriteString("import (\n")
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if path == "github.com/alexshd/lawtest" {
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%q\n", path)
+	}
+	sb.WriteString("\n\t\"github.com/alexshd/lawtest\"\n")
+	sb.WriteString(")\n\n")
+	sb.WriteString(body.String())
+	return formatSource(sb.String(), nil, true)
+}
+
+// conformanceReserved are the names the suite uses itself, which argument
+// variables must not shadow.
+var conformanceReserved = map[string]bool{
+	"t": true, "a": true, "p": true, "found": true, "b": true, "c": true, "e": true, "s": true, "v": true, "wg": true,
+	"factory": true, "gen": true, "observe": true, "obs": true, "before": true, "beforeA": true, "beforeB": true,
+	"left": true, "right": true, "got": true, "want": true, "lawtest": true, "reflect": true, "sync": true, "testing": true,
+}
+
+// conformance holds what the suite of one contract is written from.
+type conformance struct {
+	Contract
+	typ    string       // the interface as written in the file
+	probes []types.Type // argument types observers take, each recorded in probes<i>
+}
+
+func (g *generator) writeConformance(c Contract) {
+	cf := conformance{Contract: c, typ: g.typeString(c.Type)}
+	for _, m := range c.Transitions {
+		sig := m.Method.Type().(*types.Signature)
+		for i := range sig.Params().Len() {
+			if t := sig.Params().At(i).Type(); cf.probe(t) < 0 && c.observes(t) {
+				cf.probes = append(cf.probes, t)
+			}
+		}
+	}
+	var observers []string
+	for _, m := range c.Observers {
+		observers = append(observers, m.Name())
+	}
+
+	name := c.Name + "Conformance"
+	fmt.Fprintf(g.sb, "// %s checks that the %s built by factory obeys the laws\n", name, c.Name)
+	fmt.Fprintf(g.sb, "// documented on %s, comparing values through %s.\n", c.Name, strings.Join(observers, " and "))
+	g.sb.WriteString("// factory must return a new, empty value on every call.\n//\n")
+	g.sb.WriteString("// Call it from the tests of every implementation:\n//\n")
+	fmt.Fprintf(g.sb, "//\tfunc TestMy%s(t *testing.T) {\n", name)
+	fmt.Fprintf(g.sb, "//\t\t%s(t, func() %s { return NewMy%s() })\n", name, cf.typ, c.Name)
+	g.sb.WriteString("//\t}\n")
+	fmt.Fprintf(g.sb, "func %s(t *testing.T, factory func() %s) {\n", name, cf.typ)
+	g.sb.WriteString("t.Helper()\n\n")
+
+	if len(cf.probes) > 0 {
+		g.sb.WriteString("// Arguments the values were built with, to probe them with\n")
+		for i, p := range cf.probes {
+			typ := g.typeString(p)
+			fmt.Fprintf(g.sb, "var probes%d []%s\n", i, typ)
+			fmt.Fprintf(g.sb, "seen%d := make(map[%s]bool)\n", i, typ)
+			fmt.Fprintf(g.sb, "record%[1]d := func(v %[2]s) {\nif !seen%[1]d[v] {\nseen%[1]d[v] = true\nprobes%[1]d = append(probes%[1]d, v)\n}\n}\n", i, typ)
+		}
+		g.sb.WriteString("\n")
+	}
+
+	g.sb.WriteString("// gen builds a value by applying random updates to an empty one\n")
+	fmt.Fprintf(g.sb, "gen := func() %s {\ns := factory()\n", cf.typ)
+	fmt.Fprintf(g.sb, "for range %s {\n", g.intGen("0", "4"))
+	if len(c.Transitions) > 1 {
+		fmt.Fprintf(g.sb, "switch %s {\n", g.intGen("0", strconv.Itoa(len(c.Transitions)-1)))
+	}
+	for i, m := range c.Transitions {
+		if len(c.Transitions) > 1 {
+			fmt.Fprintf(g.sb, "case %d:\n", i)
+		}
+		args := cf.args(g, m.Method, "")
+		fmt.Fprintf(g.sb, "s = s.%s(%s)\n", m.Method.Name(), strings.Join(args, ", "))
+	}
+	if len(c.Transitions) > 1 {
+		g.sb.WriteString("}\n")
+	}
+	g.sb.WriteString("}\nreturn s\n}\n\n")
+
+	// Probes that find nothing are left out, which keeps failure
+	// messages readable without making different values look the same
+	g.sb.WriteString("// found reports whether an observation holds anything but zero values\n")
+	g.sb.WriteString("found := func(r ...any) bool {\nfor _, x := range r {\nif x != nil && !reflect.ValueOf(x).IsZero() {\nreturn true\n}\n}\nreturn false\n}\n\n")
+	fmt.Fprintf(g.sb, "// observe returns what %s report about v\n", strings.Join(observers, " and "))
+	fmt.Fprintf(g.sb, "observe := func(v %s) map[string]any {\nobs := make(map[string]any)\n", cf.typ)
+	for _, m := range c.Observers {
+		sig := m.Type().(*types.Signature)
+		results := make([]string, sig.Results().Len())
+		for i := range results {
+			results[i] = fmt.Sprintf("r%d", i)
+		}
+		value := results[0]
+		if len(results) > 1 {
+			value = "[]any{" + strings.Join(results, ", ") + "}"
+		}
+		// Iterators are observed through what they yield
+		call := "v." + m.Name() + "(%s)"
+		if isSeq2(sig.Results().At(0).Type()) {
+			g.imports["maps"] = "maps"
+			call = "maps.Collect(" + call + ")"
+		}
+		switch {
+		case sig.Params().Len() == 0 && len(results) == 1:
+			fmt.Fprintf(g.sb, "obs[%q] = %s\n", m.Name()+"()", fmt.Sprintf(call, ""))
+			continue
+		case sig.Params().Len() == 0:
+			fmt.Fprintf(g.sb, "{\n%s := %s\nobs[%q] = %s\n}\n", strings.Join(results, ", "), fmt.Sprintf(call, ""), m.Name()+"()", value)
+			continue
+		}
+		i := cf.probe(sig.Params().At(0).Type())
+		if i < 0 {
+			// Nothing the values are built with can be passed to it
+			continue
+		}
+		g.imports["fmt"] = "fmt"
+		fmt.Fprintf(g.sb, "for _, p := range probes%d {\nif %s := %s; found(%s) {\nobs[fmt.Sprintf(%q, p)] = %s\n}\n}\n",
+			i, strings.Join(results, ", "), fmt.Sprintf(call, "p"), strings.Join(results, ", "), m.Name()+"(%#v)", value)
+	}
+	g.sb.WriteString("return obs\n}\n")
+
+	for _, m := range c.Binary {
+		g.contractBinary(cf, m)
+	}
+	for _, m := range c.Transitions {
+		g.contractTransition(cf, m)
+	}
+	if c.Parallel {
+		g.contractParallel(cf)
+	}
+	g.sb.WriteString("}\n\n")
+}
+
+// args writes the declaration of random arguments for m, recording those
+// observers can be probed with, and returns their names, which end in
+// suffix.
+func (cf conformance) args(g *generator, m *types.Func, suffix string) []string {
+	sig := m.Type().(*types.Signature)
+	var names, exprs []string
+	for i := range sig.Params().Len() {
+		p := sig.Params().At(i)
+		name := p.Name()
+		if name == "" || name == "_" || conformanceReserved[name] || hasPrefix(name, "probes", "seen", "record", "want") {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		names = append(names, name+suffix)
+		// The first argument is usually a key: draw it from a small
+		// domain so that values built independently overlap
+		if i == 0 {
+			exprs = append(exprs, g.keyExpr(p.Type(), 0))
+		} else {
+			exprs = append(exprs, g.genExpr(p.Type(), 0))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	fmt.Fprintf(g.sb, "%s := %s\n", strings.Join(names, ", "), strings.Join(exprs, ", "))
+	for i, name := range names {
+		if j := cf.probe(sig.Params().At(i).Type()); j >= 0 {
+			fmt.Fprintf(g.sb, "record%d(%s)\n", j, name)
+		}
+	}
+	return names
+}
+
+// probe returns the index of the probes recorded for arguments of type t,
+// or -1.
+func (cf conformance) probe(t types.Type) int {
+	for i, p := range cf.probes {
+		if types.Identical(p, t) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *generator) contractBinary(cf conformance, m ContractMethod) {
+	op := m.Method.Name()
+	call := func(x, y string) string { return x + "." + op + "(" + y + ")" }
+	for _, law := range m.Laws {
+		switch law.Name {
+		case lawImmutable:
+			g.subtest(op+"Immutability", law.Reason)
+			g.sb.WriteString("a, b := gen(), gen()\nbeforeA, beforeB := observe(a), observe(b)\n")
+			fmt.Fprintf(g.sb, "_ = %s\n", call("a", "b"))
+			g.sb.WriteString("if !reflect.DeepEqual(observe(a), beforeA) || !reflect.DeepEqual(observe(b), beforeB) {\n")
+			fmt.Fprintf(g.sb, "t.Fatalf(\"Immutability failed: %s modified its operands\\n  before: a=%%v, b=%%v\\n  after:  a=%%v, b=%%v\", beforeA, beforeB, observe(a), observe(b))\n}\n", call("a", "b"))
+		case lawAssociative:
+			g.subtest(op+"Associativity", law.Reason)
+			g.sb.WriteString("a, b, c := gen(), gen(), gen()\n")
+			fmt.Fprintf(g.sb, "left, right := observe(%s), observe(%s)\n", call(call("a", "b"), "c"), call("a", call("b", "c")))
+			g.sb.WriteString("if !reflect.DeepEqual(left, right) {\n")
+			g.sb.WriteString("t.Fatalf(\"Associativity failed: (a∘b)∘c != a∘(b∘c)\\n  a=%v, b=%v, c=%v\\n  left=%v, right=%v\", observe(a), observe(b), observe(c), left, right)\n}\n")
+		case lawCommutative:
+			g.subtest(op+"Commutativity", law.Reason)
+			g.sb.WriteString("a, b := gen(), gen()\n")
+			fmt.Fprintf(g.sb, "if ab, ba := observe(%s), observe(%s); !reflect.DeepEqual(ab, ba) {\n", call("a", "b"), call("b", "a"))
+			g.sb.WriteString("t.Fatalf(\"Commutativity failed: a∘b != b∘a\\n  a=%v, b=%v\\n  a∘b=%v, b∘a=%v\", observe(a), observe(b), ab, ba)\n}\n")
+		case lawIdempotent:
+			g.subtest(op+"Idempotence", law.Reason)
+			g.sb.WriteString("a := gen()\n")
+			fmt.Fprintf(g.sb, "if got, want := observe(%s), observe(a); !reflect.DeepEqual(got, want) {\n", call("a", "a"))
+			g.sb.WriteString("t.Fatalf(\"Idempotence failed: a∘a != a\\n  a=%v, a∘a=%v\", want, got)\n}\n")
+		case lawIdentity:
+			g.subtest(op+"Identity", law.Reason)
+			g.sb.WriteString("a, e := gen(), factory()\n")
+			fmt.Fprintf(g.sb, "if got, want := observe(%s), observe(a); !reflect.DeepEqual(got, want) {\n", call("a", "e"))
+			g.sb.WriteString("t.Fatalf(\"Right identity failed: a∘e != a\\n  a=%v, a∘e=%v\", want, got)\n}\n")
+			fmt.Fprintf(g.sb, "if got, want := observe(%s), observe(a); !reflect.DeepEqual(got, want) {\n", call("e", "a"))
+			g.sb.WriteString("t.Fatalf(\"Left identity failed: e∘a != a\\n  a=%v, e∘a=%v\", want, got)\n}\n")
+		default:
+			continue
+		}
+		g.sb.WriteString("}\n})\n")
+	}
+}
+
+func (g *generator) contractTransition(cf conformance, m ContractMethod) {
+	name := m.Method.Name()
+	for _, law := range m.Laws {
+		switch law.Name {
+		case lawImmutable:
+			g.subtest(name+"Immutability", law.Reason)
+			g.sb.WriteString("s := gen()\n")
+			args := strings.Join(cf.args(g, m.Method, ""), ", ")
+			g.sb.WriteString("before := observe(s)\n")
+			fmt.Fprintf(g.sb, "_ = s.%s(%s)\n", name, args)
+			g.sb.WriteString("if got := observe(s); !reflect.DeepEqual(got, before) {\n")
+			fmt.Fprintf(g.sb, "t.Fatalf(\"Immutability failed: %s modified the receiver\\n  before: %%v\\n  after:  %%v\", before, got)\n}\n", name)
+		case lawDeterministic:
+			g.subtest(name+"Determinism", law.Reason)
+			g.sb.WriteString("s := gen()\n")
+			args := strings.Join(cf.args(g, m.Method, ""), ", ")
+			fmt.Fprintf(g.sb, "if got, want := observe(s.%[1]s(%[2]s)), observe(s.%[1]s(%[2]s)); !reflect.DeepEqual(got, want) {\n", name, args)
+			fmt.Fprintf(g.sb, "t.Fatalf(\"Determinism failed: %s gave different results for the same arguments\\n  s=%%v\\n  first=%%v, second=%%v\", observe(s), want, got)\n}\n", name)
+		default:
+			continue
+		}
+		g.sb.WriteString("}\n})\n")
+	}
+}
+
+// contractParallel checks that concurrent calls on shared values give the
+// results sequential ones do; run it with -race to catch data races too.
+func (g *generator) contractParallel(cf conformance) {
+	fmt.Fprintf(g.sb, "\n// %s is documented as safe for concurrent use\n", cf.Name)
+	g.sb.WriteString("t.Run(\"ParallelSafety\", func(t *testing.T) {\n")
+	g.sb.WriteString("a, b := gen(), gen()\n")
+	// Arguments are drawn before any observation, so that all observations
+	// probe the same keys
+	var exprs []string
+	for _, m := range cf.Binary {
+		exprs = append(exprs, "observe(a."+m.Method.Name()+"(b))")
+	}
+	for i, m := range cf.Transitions {
+		args := strings.Join(cf.args(g, m.Method, strconv.Itoa(i)), ", ")
+		exprs = append(exprs, "observe(a."+m.Method.Name()+"("+args+"))")
+	}
+	type check struct{ want, expr string }
+	var checks []check
+	for i, expr := range exprs {
+		fmt.Fprintf(g.sb, "want%d := %s\n", i, expr)
+		checks = append(checks, check{fmt.Sprintf("want%d", i), expr})
+	}
+	g.sb.WriteString("wantA := observe(a)\n\n")
+	g.sb.WriteString("var wg sync.WaitGroup\nfor range 8 {\nwg.Add(1)\ngo func() {\ndefer wg.Done()\nfor range 10 {\n")
+	for _, ch := range checks {
+		fmt.Fprintf(g.sb, "if got := %s; !reflect.DeepEqual(got, %s) {\n", ch.expr, ch.want)
+		fmt.Fprintf(g.sb, "t.Errorf(\"Parallel safety failed: concurrent %s gave %%v, want %%v\", got, %s)\nreturn\n}\n",
+			strings.TrimSuffix(strings.TrimPrefix(ch.expr, "observe("), ")"), ch.want)
+	}
+	g.sb.WriteString("}\n}()\n}\nwg.Wait()\n")
+	g.sb.WriteString("if got := observe(a); !reflect.DeepEqual(got, wantA) {\n")
+	g.sb.WriteString("t.Errorf(\"Parallel safety failed: concurrent calls modified a\\n  before: %v\\n  after:  %v\", wantA, got)\n}\n")
+	g.sb.WriteString("})\n")
+}
+
+// subtest opens the subtest name of a conformance suite, looping over
+// lawtest's default number of test cases.
+func (g *generator) subtest(name, reason string) {
+	fmt.Fprintf(g.sb, "\n// %s: %s\n", name, reason)
+	fmt.Fprintf(g.sb, "t.Run(%q, func(t *testing.T) {\n", name)
+	g.sb.WriteString("for range lawtest.DefaultConfig().TestCases {\n")
+}
+
+// generateConformanceTests writes a test running the suite of each contract
+// against each of its Impls.
+func (g *generator) generateConformanceTests(contracts []Contract) {
+	for _, c := range contracts {
+		for _, impl := range c.Impls {
+			name := "Test" + impl.TypeName + c.Name + "Conformance"
+			g.tests[name] = impl.TypeName + c.Name
+			sig := impl.Factory.Type().(*types.Signature)
+			args := make([]string, sig.Params().Len())
+			for i := range args {
+				args[i] = g.typeString(sig.Params().At(i).Type()) + "{}"
+			}
+			fmt.Fprintf(g.sb, "// %s runs the %s conformance suite against %s.\n", name, c.Name, impl.TypeName)
+			fmt.Fprintf(g.sb, "func %s(t *testing.T) {\n", name)
+			fmt.Fprintf(g.sb, "%sConformance(t, func() %s {\nreturn %s(%s)\n})\n}\n\n",
+				c.Name, g.typeString(c.Type), impl.Factory.Name(), strings.Join(args, ", "))
+		}
+	}
+}
+
+// contractsIn returns contracts with only the Impls declared in filename,
+// leaving out those with none.
+func contractsIn(contracts []Contract, filename string) []Contract {
+	var result []Contract
+	for _, c := range contracts {
+		var impls []Impl
+		for _, impl := range c.Impls {
+			if impl.Pos.Filename == filename {
+				impls = append(impls, impl)
+			}
+		}
+		if len(impls) > 0 {
+			c.Impls = impls
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// conformanceFile returns the name of the file holding the suite of c.
+func conformanceFile(dir string, c Contract) string {
+	return filepath.Join(dir, strings.ToLower(c.Name)+"_conformance.go")
+}
+
+func hasPrefix(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
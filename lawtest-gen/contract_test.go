@@ -0,0 +1,73 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestFindContracts(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/contract"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].Contracts) != 1 {
+		t.Fatalf("got %d packages, want one with one contract", len(pkgs))
+	}
+	c := pkgs[0].Contracts[0]
+	if c.Name != "Store" || !c.Parallel {
+		t.Errorf("got contract %s (parallel %v), want Store, documented as parallel-safe", c.Name, c.Parallel)
+	}
+
+	if len(c.Binary) != 1 || c.Binary[0].Method.Name() != "Join" {
+		t.Fatalf("got binary methods %v, want Join", c.Binary)
+	}
+	var laws []string
+	for _, law := range c.Binary[0].Laws {
+		laws = append(laws, law.Name)
+	}
+	if got, want := strings.Join(laws, ","), "immutable,associative,commutative,idempotent,identity"; got != want {
+		t.Errorf("Join laws = %s, want %s", got, want)
+	}
+
+	var names []string
+	for _, m := range c.Transitions {
+		names = append(names, m.Method.Name())
+	}
+	for _, m := range c.Observers {
+		names = append(names, m.Name())
+	}
+	if got, want := strings.Join(names, ","), "Delete,Put,All,Get,Len"; got != want {
+		t.Errorf("transitions and observers = %s, want %s", got, want)
+	}
+
+	if len(c.Impls) != 1 || c.Impls[0].TypeName != "MapStore" || c.Impls[0].Factory.Name() != "NewMapStore" {
+		t.Errorf("got implementations %v, want MapStore built with NewMapStore", c.Impls)
+	}
+}
+
+func TestGenerateConformance(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/contract"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := pkgs[0]
+	g := newGenerator(pkg)
+
+	suite, err := g.generateConformanceFile(pkg.Name, pkg.Contracts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", suite, 0); err != nil {
+		t.Fatalf("conformance suite does not parse: %v\n%s", err, suite)
+	}
+
+	filename := pkg.Contracts[0].Impls[0].Pos.Filename
+	if _, err := g.generateTestFile(pkg.Name, candidatesIn(pkg.Candidates, filename), contractsIn(pkg.Contracts, filename)); err != nil {
+		t.Fatal(err)
+	}
+	if g.tests["TestMapStoreStoreConformance"] != "MapStoreStore" {
+		t.Errorf("conformance test not recorded for merging: %v", g.tests)
+	}
+}
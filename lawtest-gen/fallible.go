@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operations that take a context.Context or return an error don't fit
+// lawtest's BinaryOp. They are called with context.Background(), and laws
+// other than immutability are checked only where the operation succeeds:
+// a failed call tells nothing about associativity, but the same inputs must
+// always fail the same way (lawStableErrors).
+
+// callExpr calls the binary operation c on x and y, passing a background
+// context if it takes one.
+func (g *generator) callExpr(c Candidate, x, y string) string {
+	var args []string
+	if c.TakesContext {
+		g.imports["context"] = "context"
+		args = append(args, "context.Background()")
+	}
+	if c.Receiver != "" {
+		return x + "." + c.FuncName + "(" + strings.Join(append(args, y), ", ") + ")"
+	}
+	return c.FuncName + "(" + strings.Join(append(args, x, y), ", ") + ")"
+}
+
+// adapter declares c as a BinaryOp. Errors are dropped: the adapter is only
+// used on its own for immutability, which must hold even when c fails.
+func (g *generator) adapter(c Candidate) string {
+	name := g.unique(lowerFirst(testName(c)) + "Op")
+	if !g.declare(name) {
+		return name
+	}
+	typ := g.typeString(c.Type)
+	if c.ReturnsError {
+		fmt.Fprintf(g.sb, "// %s adapts %s to lawtest's BinaryOp, dropping the error\n", name, c.FuncName)
+		fmt.Fprintf(g.sb, "func %s(a, b %s) %s {\n\tv, _ := %s\n\treturn v\n}\n\n", name, typ, typ, g.callExpr(c, "a", "b"))
+	} else {
+		fmt.Fprintf(g.sb, "// %s adapts %s to lawtest's BinaryOp\n", name, c.FuncName)
+		fmt.Fprintf(g.sb, "func %s(a, b %s) %s {\n\treturn %s\n}\n\n", name, typ, typ, g.callExpr(c, "a", "b"))
+	}
+	return name
+}
+
+// generateErrorTests writes the test for law on an operation returning an
+// error, skipping the inputs it rejects. Values are unwrapped, as in
+// generateFoldTests.
+func (g *generator) generateErrorTests(c Candidate, op operand, law Law) {
+	gen := g.rawGen(op)
+	call := func(x, y string) string { return g.callExpr(c, x, y) }
+	differ := func(x, y string) string { return g.rawDiffer(op, x, y) }
+
+	switch law.Name {
+	case lawAssociative:
+		g.lawHeader(c, law, "Associativity", "be associative where it succeeds")
+		g.loop("a, b, c := %[1]s, %[1]s, %[1]s", gen)
+		fmt.Fprintf(g.sb, "ab, err := %s\nif err != nil {\ncontinue\n}\n", call("a", "b"))
+		fmt.Fprintf(g.sb, "bc, err := %s\nif err != nil {\ncontinue\n}\n", call("b", "c"))
+		fmt.Fprintf(g.sb, "left, errLeft := %s\nright, errRight := %s\n", call("ab", "c"), call("a", "bc"))
+		g.sb.WriteString("if (errLeft == nil) != (errRight == nil) {\n")
+		g.sb.WriteString("t.Fatalf(\"Associativity failed: only one grouping succeeded\\n  a=%v, b=%v, c=%v\\n  (a∘b)∘c error: %v\\n  a∘(b∘c) error: %v\", a, b, c, errLeft, errRight)\n}\n")
+		fmt.Fprintf(g.sb, "if errLeft == nil && %s {\n", differ("left", "right"))
+		g.sb.WriteString("t.Fatalf(\"Associativity failed: (a∘b)∘c != a∘(b∘c)\\n  a=%v, b=%v, c=%v\\n  left=%v, right=%v\", a, b, c, left, right)\n}\n")
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawCommutative:
+		g.lawHeader(c, law, "Commutativity", "be commutative where it succeeds")
+		g.loop("a, b := %[1]s, %[1]s", gen)
+		fmt.Fprintf(g.sb, "ab, errAB := %s\nba, errBA := %s\n", call("a", "b"), call("b", "a"))
+		g.sb.WriteString("if (errAB == nil) != (errBA == nil) {\n")
+		g.sb.WriteString("t.Fatalf(\"Commutativity failed: only one order succeeded\\n  a=%v, b=%v\\n  a∘b error: %v\\n  b∘a error: %v\", a, b, errAB, errBA)\n}\n")
+		fmt.Fprintf(g.sb, "if errAB == nil && %s {\n", differ("ab", "ba"))
+		g.sb.WriteString("t.Fatalf(\"Commutativity failed: a∘b != b∘a\\n  a=%v, b=%v\\n  a∘b=%v, b∘a=%v\", a, b, ab, ba)\n}\n")
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawIdempotent:
+		g.lawHeader(c, law, "Idempotence", "be idempotent where it succeeds")
+		g.loop("a := %s", gen)
+		fmt.Fprintf(g.sb, "if got, err := %s; err == nil && %s {\n", call("a", "a"), differ("got", "a"))
+		g.sb.WriteString("t.Fatalf(\"Idempotence failed: a∘a != a\\n  a=%v, a∘a=%v\", a, got)\n}\n")
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawIdentity:
+		g.lawHeader(c, law, "Identity", "have this identity element")
+		raw := op
+		raw.wrapper = ""
+		fmt.Fprintf(g.sb, "e := %s\n", g.identityExpr(c, raw, law.Identity))
+		g.loop("a := %s", gen)
+		fmt.Fprintf(g.sb, "if got, err := %s; err == nil && %s {\n", call("a", "e"), differ("got", "a"))
+		g.sb.WriteString("t.Fatalf(\"Right identity failed: a∘e != a\\n  a=%v, e=%v, a∘e=%v\", a, e, got)\n}\n")
+		fmt.Fprintf(g.sb, "if got, err := %s; err == nil && %s {\n", call("e", "a"), differ("got", "a"))
+		g.sb.WriteString("t.Fatalf(\"Left identity failed: e∘a != a\\n  e=%v, a=%v, e∘a=%v\", e, a, got)\n}\n")
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawStableErrors:
+		g.imports["fmt"] = "fmt"
+		g.lawHeader(c, law, "ErrorStability", "fail deterministically")
+		g.loop("a, b := %[1]s, %[1]s", gen)
+		fmt.Fprintf(g.sb, "_, err1 := %[1]s\n_, err2 := %[1]s\n", call("a", "b"))
+		g.sb.WriteString("if fmt.Sprint(err1) != fmt.Sprint(err2) {\n")
+		g.sb.WriteString("t.Fatalf(\"Error stability failed: the same inputs failed differently\\n  a=%v, b=%v\\n  first: %v\\n  second: %v\", a, b, err1, err2)\n}\n")
+		g.sb.WriteString("}\n}\n\n")
+	}
+}
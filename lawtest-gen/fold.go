@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// Fold describes a variadic operation that folds its arguments:
+// func(...T) T, func(T, ...T) T, func (T) M(...T) T, or func (T) M(...E) T
+// for an action applying updates of type E, such as
+// MergeAll(cs ...Config) Config or (State).Apply(ops ...Op) State.
+type Fold struct {
+	// Elem is the type of the variadic arguments: the operand type for
+	// n-ary combines, or the type of the updates for actions like Apply.
+	Elem types.Type
+	// Action is set when the receiver is a state the arguments are applied
+	// to, rather than combined with.
+	Action bool
+}
+
+// inferFoldLaws proposes the laws of a fold: it must not touch its inputs,
+// must not depend on how its arguments are grouped and, for actions,
+// applying updates at once must equal applying them one call at a time.
+func inferFoldLaws(c *Candidate) {
+	if c.Fold.Action {
+		c.addLaw(lawComposition, "applying updates in one "+c.FuncName+" call should equal applying them one call at a time")
+		return
+	}
+	c.addLaw(lawImmutable, "every candidate must leave its inputs untouched (Law I)")
+	c.addLaw(lawAssociative, "variadic folds are called on partial results, which is only safe if grouping doesn't matter")
+	c.addLaw(lawFlatten, "folding several values at once should equal folding them in steps")
+}
+
+// inferPairwise proposes that each fold agrees with the binary operation on
+// the same type: the one it is named after (Merge for MergeAll) or the only
+// one there is.
+func inferPairwise(candidates []Candidate) {
+	for i := range candidates {
+		c := &candidates[i]
+		if c.Fold == nil || c.Fold.Action || c.Annotated {
+			continue
+		}
+		var pairs []*Candidate
+		var named *Candidate
+		for j := range candidates {
+			d := &candidates[j]
+			if !d.binary() || d.TakesContext || d.ReturnsError || !types.Identical(d.Type, c.Type) {
+				continue
+			}
+			pairs = append(pairs, d)
+			if strings.HasPrefix(c.FuncName, d.FuncName) {
+				named = d
+			}
+		}
+		if named == nil && len(pairs) == 1 {
+			named = pairs[0]
+		}
+		if named == nil {
+			continue
+		}
+		c.Laws = append(c.Laws, Law{
+			Name:     lawPairwise,
+			Reason:   c.FuncName + " folds " + c.TypeName + " values like repeated " + named.FuncName,
+			Pairwise: named.FuncName,
+		})
+	}
+}
+
+// foldPair declares c restricted to two operands, which is the BinaryOp
+// lawtest checks immutability and associativity on.
+func (g *generator) foldPair(c Candidate) string {
+	name := g.unique(lowerFirst(testName(c)) + "Pair")
+	if !g.declare(name) {
+		return name
+	}
+	typ := g.typeString(c.Type)
+	fmt.Fprintf(g.sb, "// %s is %s restricted to two operands, as lawtest expects\n", name, c.FuncName)
+	fmt.Fprintf(g.sb, "func %s(a, b %s) %s {\n\treturn %s\n}\n\n", name, typ, typ, foldCall(c, "a", "b"))
+	return name
+}
+
+// generateFoldTests writes the tests of the fold-specific laws, working on
+// unwrapped values since folds take any number of them.
+func (g *generator) generateFoldTests(c Candidate, op operand, law Law) {
+	a, b, x := g.rawGen(op), g.rawGen(op), g.rawGen(op)
+	switch law.Name {
+	case lawFlatten:
+		g.lawHeader(c, law, "Flattening", "flatten")
+		g.loop("a, b, c := %s, %s, %s", a, b, x)
+		fmt.Fprintf(g.sb, "if got := %s; %s {\n", foldCall(c, "a"), g.rawDiffer(op, "got", "a"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Flattening failed: %s != a\\n  a=%%v, got=%%v\", a, got)\n}\n", foldCall(c, "a"))
+		all, steps := foldCall(c, "a", "b", "c"), foldCall(c, foldCall(c, "a", "b"), "c")
+		fmt.Fprintf(g.sb, "if got, want := %s, %s; %s {\n", all, steps, g.rawDiffer(op, "got", "want"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Flattening failed: %s != %s\\n  a=%%v, b=%%v, c=%%v\\n  got=%%v, want=%%v\", a, b, c, got, want)\n}\n", all, steps)
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawPairwise:
+		pair, ok := g.candidate(law.Pairwise, c.Type)
+		if !ok {
+			return
+		}
+		g.lawHeader(c, law, "Pairwise", "agree with "+law.Pairwise)
+		g.loop("a, b, c := %s, %s, %s", a, b, x)
+		all, steps := foldCall(c, "a", "b", "c"), binaryCall(pair, binaryCall(pair, "a", "b"), "c")
+		fmt.Fprintf(g.sb, "if got, want := %s, %s; %s {\n", all, steps, g.rawDiffer(op, "got", "want"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Pairwise failed: %s != %s\\n  a=%%v, b=%%v, c=%%v\\n  got=%%v, want=%%v\", a, b, c, got, want)\n}\n", all, steps)
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawComposition:
+		elem := g.elemGen(c.Fold.Elem)
+		g.lawHeader(c, law, "Composition", "compose")
+		g.loop("s, x, y := %s, %s(), %s()", a, elem, elem)
+		fmt.Fprintf(g.sb, "if got := s.%s(); %s {\n", c.FuncName, g.rawDiffer(op, "got", "s"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Composition failed: s.%s() != s\\n  s=%%v, got=%%v\", s, got)\n}\n", c.FuncName)
+		fmt.Fprintf(g.sb, "if got, want := s.%[1]s(x, y), s.%[1]s(x).%[1]s(y); %s {\n", c.FuncName, g.rawDiffer(op, "got", "want"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Composition failed: s.%[1]s(x, y) != s.%[1]s(x).%[1]s(y)\\n  s=%%v, x=%%v, y=%%v\\n  got=%%v, want=%%v\", s, x, y, got, want)\n}\n", c.FuncName)
+		g.sb.WriteString("}\n}\n\n")
+	}
+}
+
+// elemGen declares the generator for the updates an action applies.
+func (g *generator) elemGen(t types.Type) string {
+	base := "Value"
+	switch t := t.(type) {
+	case *types.Named:
+		base = upperFirst(t.Obj().Name())
+	case *types.Basic:
+		base = upperFirst(t.Name())
+	}
+	return g.declareGen("gen"+base, g.typeString(t), g.genExpr(t, 0))
+}
+
+// rawGen returns an expression generating an unwrapped operand.
+func (g *generator) rawGen(op operand) string {
+	if op.wrapper != "" {
+		return op.gen + "()." + op.field
+	}
+	return op.gen + "()"
+}
+
+// rawDiffer is differ for unwrapped operands.
+func (g *generator) rawDiffer(op operand, x, y string) string {
+	if op.wrapper != "" {
+		g.imports["reflect"] = "reflect"
+		return "!reflect.DeepEqual(" + x + ", " + y + ")"
+	}
+	return op.differ(x, y)
+}
+
+// candidate returns the binary candidate named name on type t.
+func (g *generator) candidate(name string, t types.Type) (Candidate, bool) {
+	for _, d := range g.candidates {
+		if d.binary() && d.FuncName == name && types.Identical(d.Type, t) {
+			return d, true
+		}
+	}
+	return Candidate{}, false
+}
+
+// foldCall calls the fold c on args; methods take the first as receiver.
+func foldCall(c Candidate, args ...string) string {
+	if c.Receiver != "" {
+		return args[0] + "." + c.FuncName + "(" + strings.Join(args[1:], ", ") + ")"
+	}
+	return c.FuncName + "(" + strings.Join(args, ", ") + ")"
+}
+
+// binaryCall calls the binary operation c on x and y.
+func binaryCall(c Candidate, x, y string) string {
+	return foldCall(c, x, y)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Fuzz targets check the binary laws on values decoded from the fuzzer's
+// input rather than drawn from lawtest's generators, so coverage guidance
+// can steer towards the inputs that break a law. The decoders are built by
+// genExpr like the generators, with every primitive taken from the input
+// bytes instead (see intGen and friends).
+
+// fuzzLaws are the laws fuzz targets check.
+var fuzzLaws = map[string]bool{
+	lawImmutable:   true,
+	lawAssociative: true,
+	lawCommutative: true,
+	lawIdempotent:  true,
+	lawIdentity:    true,
+}
+
+// generateFuzzTarget writes Fuzz<name>Laws, checking the laws proposed for
+// c on unwrapped values decoded from the fuzz input. Operations returning an
+// error are only checked on inputs where every call succeeds.
+func (g *generator) generateFuzzTarget(c Candidate, op operand) {
+	if c.Fold != nil && c.Fold.Action || c.Transition != nil {
+		return
+	}
+	has := make(map[string]bool)
+	var laws []Law
+	for _, law := range c.Laws {
+		if fuzzLaws[law.Name] {
+			has[law.Name] = true
+			laws = append(laws, law)
+		}
+	}
+	if len(laws) == 0 {
+		return
+	}
+
+	decode := g.decoder(c)
+	typ := g.typeString(c.Type)
+	call := func(x, y string) string {
+		if c.Fold != nil {
+			return foldCall(c, x, y)
+		}
+		return g.callExpr(c, x, y)
+	}
+	differ := func(x, y string) string { return g.rawDiffer(op, x, y) }
+
+	name := "Fuzz" + testName(c) + "Laws"
+	g.tests[name] = testName(c)
+	fmt.Fprintf(g.sb, "// %s checks the laws proposed for %s on values decoded from the\n", name, c.FuncName)
+	fmt.Fprintf(g.sb, "// fuzzer's input. Run it with go test -fuzz=%s\n", name)
+	fmt.Fprintf(g.sb, "func %s(f *testing.F) {\n", name)
+	g.sb.WriteString("f.Add([]byte{})\nf.Add([]byte(\"lawtest fuzz seed\"))\n")
+	g.sb.WriteString("f.Fuzz(func(t *testing.T, data []byte) {\n")
+	g.sb.WriteString("in := lawFuzzInput(data)\n")
+	switch {
+	case has[lawAssociative]:
+		fmt.Fprintf(g.sb, "a, b, c := %[1]s(&in), %[1]s(&in), %[1]s(&in)\n", decode)
+	case has[lawImmutable] || has[lawCommutative]:
+		fmt.Fprintf(g.sb, "a, b := %[1]s(&in), %[1]s(&in)\n", decode)
+	default:
+		fmt.Fprintf(g.sb, "a := %s(&in)\n", decode)
+	}
+
+	if has[lawImmutable] {
+		g.sb.WriteString("\n// Decoding the same input again yields equal, independent copies\n")
+		fmt.Fprintf(g.sb, "in = lawFuzzInput(data)\naOrig, bOrig := %[1]s(&in), %[1]s(&in)\n", decode)
+		if c.ReturnsError {
+			fmt.Fprintf(g.sb, "_, _ = %s\n", call("a", "b"))
+		} else {
+			fmt.Fprintf(g.sb, "_ = %s\n", call("a", "b"))
+		}
+		fmt.Fprintf(g.sb, "if %s || %s {\n", differ("a", "aOrig"), differ("b", "bOrig"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Immutability failed: %s modified its inputs\\n  before: a=%%v, b=%%v\\n  after:  a=%%v, b=%%v\", aOrig, bOrig, a, b)\n}\n", c.FuncName)
+	}
+
+	if c.ReturnsError && len(laws) > 1 {
+		// The other laws only hold where the operation succeeds
+		g.sb.WriteString("\n")
+		fmt.Fprintf(g.sb, "op := func(x, y %s) %s {\n", typ, typ)
+		fmt.Fprintf(g.sb, "v, err := %s\nif err != nil {\nt.Skip(err)\n}\nreturn v\n}\n", call("x", "y"))
+		call = func(x, y string) string { return "op(" + x + ", " + y + ")" }
+	}
+
+	for _, law := range laws {
+		switch law.Name {
+		case lawAssociative:
+			fmt.Fprintf(g.sb, "\nif left, right := %s, %s; %s {\n", call(call("a", "b"), "c"), call("a", call("b", "c")), differ("left", "right"))
+			g.sb.WriteString("t.Fatalf(\"Associativity failed: (a∘b)∘c != a∘(b∘c)\\n  a=%v, b=%v, c=%v\\n  left=%v, right=%v\", a, b, c, left, right)\n}\n")
+		case lawCommutative:
+			fmt.Fprintf(g.sb, "\nif ab, ba := %s, %s; %s {\n", call("a", "b"), call("b", "a"), differ("ab", "ba"))
+			g.sb.WriteString("t.Fatalf(\"Commutativity failed: a∘b != b∘a\\n  a=%v, b=%v\\n  a∘b=%v, b∘a=%v\", a, b, ab, ba)\n}\n")
+		case lawIdempotent:
+			fmt.Fprintf(g.sb, "\nif got := %s; %s {\n", call("a", "a"), differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Idempotence failed: a∘a != a\\n  a=%v, a∘a=%v\", a, got)\n}\n")
+		case lawIdentity:
+			raw := op
+			raw.wrapper = ""
+			fmt.Fprintf(g.sb, "\ne := %s\n", g.identityExpr(c, raw, law.Identity))
+			fmt.Fprintf(g.sb, "if got := %s; %s {\n", call("a", "e"), differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Right identity failed: a∘e != a\\n  a=%v, e=%v, a∘e=%v\", a, e, got)\n}\n")
+			fmt.Fprintf(g.sb, "if got := %s; %s {\n", call("e", "a"), differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Left identity failed: e∘a != a\\n  e=%v, a=%v, e∘a=%v\", e, a, got)\n}\n")
+		}
+	}
+	g.sb.WriteString("})\n}\n\n")
+}
+
+// decoder declares the function decoding an unwrapped operand of c from the
+// fuzz input.
+func (g *generator) decoder(c Candidate) string {
+	name := g.unique("decode" + baseName(c))
+	if !g.declare(name) {
+		return name
+	}
+	typ := g.typeString(c.Type)
+	g.fuzz = true
+	expr := g.genExpr(c.Type, 0)
+	g.fuzz = false
+
+	fmt.Fprintf(g.sb, "// %s decodes a %s from the fuzz input\n", name, typ)
+	fmt.Fprintf(g.sb, "func %s(in *lawFuzzInput) %s {\n\treturn %s\n}\n\n", name, typ, expr)
+	return name
+}
+
+// intGen returns an expression drawing an int in [lo, hi].
+func (g *generator) intGen(lo, hi string) string {
+	if g.fuzz {
+		g.declareFuzzInput()
+		return "fuzzInt(in, " + lo + ", " + hi + ")"
+	}
+	return "lawtest.IntGen(" + lo + ", " + hi + ")()"
+}
+
+// float64Gen returns an expression drawing a float64 in [lo, hi].
+func (g *generator) float64Gen(lo, hi string) string {
+	if g.fuzz {
+		g.declareFuzzInput()
+		return "fuzzFloat64(in, " + lo + ", " + hi + ")"
+	}
+	return "lawtest.Float64Gen(" + lo + ", " + hi + ")()"
+}
+
+// stringGen returns an expression drawing an alphanumeric string of length
+// n, or of at most n when fuzzing.
+func (g *generator) stringGen(n int) string {
+	if g.fuzz {
+		g.declareFuzzInput()
+		return "fuzzString(in, " + strconv.Itoa(n) + ")"
+	}
+	return "lawtest.StringGen(" + strconv.Itoa(n) + ")()"
+}
+
+// boolGen returns an expression drawing a bool.
+func (g *generator) boolGen() string {
+	if g.fuzz {
+		g.declareFuzzInput()
+		return "fuzzBool(in)"
+	}
+	return "lawtest.BoolGen()()"
+}
+
+// declareFuzzInput emits the lawFuzzInput type the decoders read from.
+// Its helpers are plain functions rather than methods so that merging into
+// an existing test file picks up the ones the appended code needs.
+func (g *generator) declareFuzzInput() {
+	if !g.declare("lawFuzzInput") {
+		return
+	}
+	g.sb.WriteString(`// lawFuzzInput is the fuzzer's input, consumed by the decode functions.
+// Once it runs out every value decodes as zero, so any input is valid.
+type lawFuzzInput []byte
+
+// fuzzByte consumes one byte of in.
+func fuzzByte(in *lawFuzzInput) byte {
+	if len(*in) == 0 {
+		return 0
+	}
+	b := (*in)[0]
+	*in = (*in)[1:]
+	return b
+}
+
+// fuzzInt decodes an int in [lo, hi], from a single byte for small ranges.
+func fuzzInt(in *lawFuzzInput, lo, hi int) int {
+	n := uint64(fuzzByte(in))
+	if hi-lo >= 255 {
+		for range 3 {
+			n = n<<8 | uint64(fuzzByte(in))
+		}
+	}
+	return lo + int(n%uint64(hi-lo+1))
+}
+
+// fuzzFloat64 decodes a float64 in [lo, hi].
+func fuzzFloat64(in *lawFuzzInput, lo, hi float64) float64 {
+	return lo + float64(fuzzInt(in, 0, 1<<16))/(1<<16)*(hi-lo)
+}
+
+// fuzzString decodes an alphanumeric string of at most n characters.
+func fuzzString(in *lawFuzzInput, n int) string {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, fuzzInt(in, 0, n))
+	for i := range b {
+		b[i] = charset[int(fuzzByte(in))%len(charset)]
+	}
+	return string(b)
+}
+
+// fuzzBool decodes a bool.
+func fuzzBool(in *lawFuzzInput) bool {
+	return fuzzByte(in)&1 == 1
+}
+
+`)
+}
@@ -0,0 +1,794 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	pathpkg "path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// maxGenDepth bounds how deep generators descend into nested types, so
+// recursive types such as linked lists still produce finite values.
+const maxGenDepth = 4
+
+// generator writes the _law_test.go files for one package. Helper
+// declarations (wrapper types, equality functions, generators) are shared
+// by all files of the package, so each is emitted only once.
+type generator struct {
+	pkg      *types.Package
+	declared map[string]bool
+	wrappers map[string]bool // existing WrapXxx functions of other candidates
+
+	candidates []Candidate
+
+	// per-file state
+	sb      *strings.Builder
+	imports map[string]string // path -> name
+	tests   map[string]string // test function -> testName of its candidate
+
+	fuzz bool // genExpr draws from the fuzz input rather than lawtest's generators
+}
+
+func newGenerator(pkg Package) *generator {
+	g := &generator{
+		pkg:      pkg.Types,
+		declared: make(map[string]bool),
+		wrappers: make(map[string]bool),
+
+		candidates: pkg.Candidates,
+	}
+	// A hand-written WrapMerge around Merge is itself a candidate, but
+	// testing it separately would only repeat the tests for Merge.
+	for _, c := range pkg.Candidates {
+		if c.NeedsWrapper {
+			g.wrappers["Wrap"+testName(c)] = true
+		}
+	}
+	return g
+}
+
+// operand describes how the generated tests handle one candidate's type.
+type operand struct {
+	typ      types.Type
+	typeName string // T as written in the test file
+
+	wrapper  string // wrapper type name, "" if T is used directly
+	field    string // wrapper field holding T
+	valueTyp string // type passed to lawtest: T, or *Wrapper
+
+	eq  string // custom equality function, "" when == suffices
+	gen string // generator function
+	op  string // BinaryOp passed to lawtest
+}
+
+// generateTestFile returns the _law_test.go file testing candidates and
+// running the conformance suites of contracts against their Impls.
+func (g *generator) generateTestFile(pkgName string, candidates []Candidate, contracts []Contract) (string, error) {
+	var body strings.Builder
+	g.sb = &body
+	g.tests = make(map[string]string)
+	g.imports = map[string]string{
+		"testing":                    "testing",
+		"github.com/alexshd/lawtest": "lawtest",
+	}
+
+	for _, c := range candidates {
+		if c.Receiver == "" && g.wrappers[c.FuncName] {
+			continue
+		}
+		op := g.prepareOperand(c)
+		g.generateTests(c, op)
+		g.generateFuzzTarget(c, op)
+	}
+	g.generateConformanceTests(contracts)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "package %s\n\n", pkgName)
+	sb.WriteString("import (\n")
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if path == "github.com/alexshd/lawtest" {
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%q\n", path)
+	}
+	sb.WriteString("\n\t\"github.com/alexshd/lawtest\"\n")
+	sb.WriteString(")\n\n")
+
+	sb.WriteString("// This file was auto-generated by lawtest-gen\n")
+	sb.WriteString("// Review the generators and verify each operation SHOULD obey the tested laws\n\n")
+	sb.WriteString(body.String())
+
+	return formatSource(sb.String(), nil, true)
+}
+
+// formatSource gofmts src after adding the imports in add. Imports nothing
+// uses are dropped: any of them if pruneAll is set (for freshly generated
+// files, whose imports are collected speculatively), otherwise only those
+// from add.
+func formatSource(src string, add []string, pruneAll bool) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("parsing generated tests: %w", err)
+	}
+	added := make(map[string]bool)
+	for _, path := range add {
+		added[path] = astutil.AddImport(fset, file, path)
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	for _, imp := range slices.Clone(file.Imports) {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if !used[importName(path)] && (pruneAll || added[path]) {
+			astutil.DeleteImport(fset, file, path)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return "", fmt.Errorf("formatting generated tests: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// importName guesses the name a package is referred to by from its path,
+// skipping major version suffixes such as /v2 and .v3.
+func importName(path string) string {
+	name := pathpkg.Base(path)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = pathpkg.Base(pathpkg.Dir(path))
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// prepareOperand emits the wrapper, equality and generator helpers c needs
+// (unless an earlier candidate already did) and describes how to call them.
+func (g *generator) prepareOperand(c Candidate) operand {
+	base := baseName(c)
+	op := operand{typ: c.Type, typeName: g.typeString(c.Type)}
+	op.valueTyp = op.typeName
+
+	if c.NeedsWrapper {
+		op.wrapper, op.field = g.wrapperType(base, c.Type)
+		op.valueTyp = "*" + op.wrapper
+		op.eq = g.declareEqual(lowerFirst(base)+"Equal", op.valueTyp,
+			"reflect.DeepEqual(a."+op.field+", b."+op.field+")")
+		op.gen = g.declareGen("gen"+base, op.valueTyp,
+			"&"+op.wrapper+"{"+op.field+": "+g.genExpr(c.Type, 0)+"}")
+		if c.binary() || c.Fold != nil && !c.Fold.Action {
+			op.op = g.wrapFunc(c, op)
+		}
+		return op
+	}
+
+	if eq := g.equalMethod(c.Type); eq != "" {
+		// The type defines which values are equal, such as sets holding
+		// the same elements in differently shaped trees
+		op.eq = eq
+	} else if hasReferences(c.Type) {
+		// Pointers are comparable, but only by identity: every call
+		// returns fresh ones, so compare what they point to, even from
+		// inside a comparable struct.
+		op.eq = g.declareEqual(lowerFirst(base)+"Equal", op.valueTyp, "reflect.DeepEqual(a, b)")
+	}
+	op.gen = g.declareGen("gen"+base, op.valueTyp, g.genExpr(c.Type, 0))
+	op.op = g.opExpr(c)
+	return op
+}
+
+// equalMethod returns the method expression of t's Equal(t) bool, or "" if
+// it has none.
+func (g *generator) equalMethod(t types.Type) string {
+	sel := types.NewMethodSet(t).Lookup(g.pkg, "Equal")
+	if sel == nil {
+		return ""
+	}
+	sig := sel.Type().(*types.Signature)
+	if sig.Params().Len() != 1 || sig.Results().Len() != 1 || !types.Identical(sig.Params().At(0).Type(), t) ||
+		!types.Identical(sig.Results().At(0).Type(), types.Typ[types.Bool]) {
+		return ""
+	}
+	if isPointer(t) {
+		return "(" + g.typeString(t) + ").Equal"
+	}
+	return g.typeString(t) + ".Equal"
+}
+
+// wrapperType returns the wrapper struct for t, reusing one already declared
+// in the package (such as config-merge-example's ConfigWrapper) when it has a
+// single field of type t.
+func (g *generator) wrapperType(base string, t types.Type) (name, field string) {
+	name = base + "Wrapper"
+	if obj := g.pkg.Scope().Lookup(name); obj != nil {
+		if st, ok := obj.Type().Underlying().(*types.Struct); ok && st.NumFields() == 1 &&
+			types.Identical(st.Field(0).Type(), t) {
+			return name, st.Field(0).Name()
+		}
+		name = g.unique(base + "LawWrapper")
+	}
+	if !g.declare(name) {
+		return name, wrapperField(t)
+	}
+	field = wrapperField(t)
+
+	fmt.Fprintf(g.sb, "// %s wraps %s to make it work with lawtest.\n", name, g.typeString(t))
+	fmt.Fprintf(g.sb, "// %s is not comparable, but pointers to the wrapper are.\n", g.typeString(t))
+	fmt.Fprintf(g.sb, "type %s struct {\n\t%s %s\n}\n\n", name, field, g.typeString(t))
+	return name, field
+}
+
+// wrapFunc returns the BinaryOp over wrapped values for c, reusing an
+// existing WrapXxx function with the right signature.
+func (g *generator) wrapFunc(c Candidate, op operand) string {
+	name := "Wrap" + testName(c)
+	if obj, ok := g.pkg.Scope().Lookup(name).(*types.Func); ok {
+		sig := obj.Type().(*types.Signature)
+		if sig.Params().Len() == 2 && sig.Results().Len() == 1 &&
+			g.typeString(sig.Results().At(0).Type()) == op.valueTyp &&
+			g.typeString(sig.Params().At(0).Type()) == op.valueTyp &&
+			g.typeString(sig.Params().At(1).Type()) == op.valueTyp {
+			return name
+		}
+		name = g.unique(name + "Law")
+	}
+	if !g.declare(name) {
+		return name
+	}
+
+	call := g.callExpr(c, "a."+op.field, "b."+op.field)
+	if c.Fold != nil {
+		call = foldCall(c, "a."+op.field, "b."+op.field)
+	}
+	fmt.Fprintf(g.sb, "// %s wraps %s for lawtest compatibility\n", name, c.FuncName)
+	fmt.Fprintf(g.sb, "func %s(a, b %s) %s {\n", name, op.valueTyp, op.valueTyp)
+	if c.ReturnsError {
+		// errors are dropped, as in adapter
+		fmt.Fprintf(g.sb, "\tv, _ := %s\n\treturn &%s{%s: v}\n", call, op.wrapper, op.field)
+	} else {
+		fmt.Fprintf(g.sb, "\treturn &%s{%s: %s}\n", op.wrapper, op.field, call)
+	}
+	g.sb.WriteString("}\n\n")
+	return name
+}
+
+// opExpr returns c as a BinaryOp: the function itself, or a method
+// expression such as (*State).Merge for methods.
+func (g *generator) opExpr(c Candidate) string {
+	if c.TakesContext || c.ReturnsError {
+		return g.adapter(c)
+	}
+	if c.Transition != nil {
+		return ""
+	}
+	if c.Fold != nil {
+		if c.Fold.Action {
+			return ""
+		}
+		return g.foldPair(c)
+	}
+	if c.Receiver == "" {
+		return c.FuncName + g.typeArgs(c.TypeArgs)
+	}
+	if isPointer(c.Type) {
+		return "(" + c.Receiver + ")." + c.FuncName
+	}
+	return c.Receiver + "." + c.FuncName
+}
+
+func (g *generator) declareEqual(name, typ, expr string) string {
+	name = g.unique(name)
+	if !g.declare(name) {
+		return name
+	}
+	g.imports["reflect"] = "reflect"
+
+	fmt.Fprintf(g.sb, "// %s compares %s values by content rather than identity\n", name, strings.TrimPrefix(typ, "*"))
+	fmt.Fprintf(g.sb, "func %s(a, b %s) bool {\n\treturn %s\n}\n\n", name, typ, expr)
+	return name
+}
+
+func (g *generator) declareGen(name, typ, expr string) string {
+	name = g.unique(name)
+	if !g.declare(name) {
+		return name
+	}
+
+	fmt.Fprintf(g.sb, "// %s returns a random %s\n", name, strings.TrimPrefix(typ, "*"))
+	fmt.Fprintf(g.sb, "func %s() %s {\n\treturn %s\n}\n\n", name, typ, expr)
+	return name
+}
+
+// generateTests writes one test per law proposed for c.
+func (g *generator) generateTests(c Candidate, op operand) {
+	for _, law := range c.Suggested {
+		// Documents the first test, which keeps it when merging
+		fmt.Fprintf(g.sb, "// %s was not proposed: %s.\n", lawTitles[law.Name], law.Reason)
+		fmt.Fprintf(g.sb, "// If %s should be %s, declare it with //lawtest:laws.\n", c.FuncName, law.Name)
+	}
+	for _, law := range c.Laws {
+		if c.ReturnsError && law.Name != lawImmutable {
+			g.generateErrorTests(c, op, law)
+			continue
+		}
+		if c.Transition != nil {
+			g.generateTransitionTests(c, op, law)
+			continue
+		}
+		switch law.Name {
+		case lawImmutable:
+			g.openTest(c, "Immutability")
+			if op.eq == "" {
+				fmt.Fprintf(g.sb, "\tlawtest.ImmutableOp(t, %s, %s)\n", op.op, op.gen)
+				g.sb.WriteString("}\n\n")
+				continue
+			}
+			// lawtest's checks need comparable types: compare the %#v
+			// formatting of the inputs instead, as for transitions
+			g.imports["fmt"] = "fmt"
+			g.loop("a, b := %[1]s(), %[1]s()", op.gen)
+			g.sb.WriteString("before := fmt.Sprintf(\"%#v %#v\", a, b)\n")
+			fmt.Fprintf(g.sb, "_ = %s(a, b)\n", op.op)
+			g.sb.WriteString("if after := fmt.Sprintf(\"%#v %#v\", a, b); after != before {\n")
+			fmt.Fprintf(g.sb, "t.Fatalf(\"Immutability failed: %s modified its inputs\\n  before: %%s\\n  after:  %%s\", before, after)\n", c.FuncName)
+			g.sb.WriteString("}\n}\n}\n\n")
+
+		case lawAssociative:
+			g.lawHeader(c, law, "Associativity", "be associative")
+			if op.eq == "" {
+				fmt.Fprintf(g.sb, "\tlawtest.Associative(t, %s, %s)\n", op.op, op.gen)
+				g.sb.WriteString("}\n\n")
+				continue
+			}
+			g.loop("a, b, c := %[1]s(), %[1]s(), %[1]s()", op.gen)
+			fmt.Fprintf(g.sb, "if left, right := %[1]s(%[1]s(a, b), c), %[1]s(a, %[1]s(b, c)); %s {\n", op.op, op.differ("left", "right"))
+			g.sb.WriteString("t.Fatalf(\"Associativity failed: (a∘b)∘c != a∘(b∘c)\\n  a=%v, b=%v, c=%v\\n  (a∘b)∘c=%v, a∘(b∘c)=%v\", a, b, c, left, right)\n")
+			g.sb.WriteString("}\n}\n}\n\n")
+
+		case lawCommutative:
+			g.lawHeader(c, law, "Commutativity", "be commutative")
+			if op.eq == "" {
+				fmt.Fprintf(g.sb, "\tlawtest.Commutative(t, %s, %s)\n", op.op, op.gen)
+				g.sb.WriteString("}\n\n")
+				continue
+			}
+			g.loop("a, b := %[1]s(), %[1]s()", op.gen)
+			fmt.Fprintf(g.sb, "if ab, ba := %[1]s(a, b), %[1]s(b, a); %s {\n", op.op, op.differ("ab", "ba"))
+			g.sb.WriteString("t.Fatalf(\"Commutativity failed: a∘b != b∘a\\n  a=%v, b=%v\\n  a∘b=%v, b∘a=%v\", a, b, ab, ba)\n")
+			g.sb.WriteString("}\n}\n}\n\n")
+
+		case lawIdempotent:
+			g.lawHeader(c, law, "Idempotence", "be idempotent")
+			g.loop("a := %s()", op.gen)
+			fmt.Fprintf(g.sb, "if got := %s(a, a); %s {\n", op.op, op.differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Idempotence failed: a∘a != a\\n  a=%v, a∘a=%v\", a, got)\n")
+			g.sb.WriteString("}\n}\n}\n\n")
+
+		case lawIdentity:
+			g.lawHeader(c, law, "Identity", "have this identity element")
+			e := g.identityExpr(c, op, law.Identity)
+			if op.eq == "" {
+				fmt.Fprintf(g.sb, "\tlawtest.Identity(t, %s, %s, %s)\n", op.op, e, op.gen)
+				g.sb.WriteString("}\n\n")
+				continue
+			}
+			fmt.Fprintf(g.sb, "e := %s\n", e)
+			g.loop("a := %s()", op.gen)
+			fmt.Fprintf(g.sb, "if got := %s(a, e); %s {\n", op.op, op.differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Right identity failed: a∘e != a\\n  a=%v, e=%v, a∘e=%v\", a, e, got)\n}\n")
+			fmt.Fprintf(g.sb, "if got := %s(e, a); %s {\n", op.op, op.differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Left identity failed: e∘a != a\\n  e=%v, a=%v, e∘a=%v\", e, a, got)\n}\n")
+			g.sb.WriteString("}\n}\n\n")
+
+		case lawAbsorption:
+			dual, ok := g.dual(c, law.Dual)
+			if !ok {
+				continue // generated with the dual operation
+			}
+			dualOp := g.prepareOperand(dual)
+			fmt.Fprintf(g.sb, "// Absorption with %s was proposed because %s.\n", law.Dual, law.Reason)
+			fmt.Fprintf(g.sb, "// If %s and %s do not form a lattice, delete this test.\n", c.FuncName, law.Dual)
+			g.openTest(c, upperFirst(dual.FuncName)+"Absorption")
+			g.loop("a, b := %[1]s(), %[1]s()", op.gen)
+			fmt.Fprintf(g.sb, "if got := %s(a, %s(a, b)); %s {\n", op.op, dualOp.op, op.differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Absorption failed: a∘(a•b) != a\\n  a=%v, b=%v, a∘(a•b)=%v\", a, b, got)\n}\n")
+			fmt.Fprintf(g.sb, "if got := %s(a, %s(a, b)); %s {\n", dualOp.op, op.op, op.differ("got", "a"))
+			g.sb.WriteString("t.Fatalf(\"Absorption failed: a•(a∘b) != a\\n  a=%v, b=%v, a•(a∘b)=%v\", a, b, got)\n}\n")
+			g.sb.WriteString("}\n}\n\n")
+
+		default:
+			g.generateFoldTests(c, op, law)
+		}
+	}
+}
+
+// lawHeader opens the test for law, explaining why it was proposed.
+func (g *generator) lawHeader(c Candidate, law Law, test, should string) {
+	fmt.Fprintf(g.sb, "// %s was proposed because %s.\n", test, law.Reason)
+	fmt.Fprintf(g.sb, "// If %s should not %s, delete this test.\n", c.FuncName, should)
+	g.openTest(c, test)
+}
+
+// openTest starts the test function Test<name><suffix> for c and records it
+// in g.tests.
+func (g *generator) openTest(c Candidate, suffix string) {
+	name := "Test" + testName(c) + suffix
+	g.tests[name] = testName(c)
+	fmt.Fprintf(g.sb, "func %s(t *testing.T) {\n", name)
+}
+
+// loop opens a loop over lawtest's default number of test cases, starting
+// with the given statement.
+func (g *generator) loop(format string, args ...any) {
+	g.sb.WriteString("for range lawtest.DefaultConfig().TestCases {\n")
+	fmt.Fprintf(g.sb, format+"\n", args...)
+}
+
+// differ returns an expression reporting whether x and y differ under the
+// operand's equality.
+func (op operand) differ(x, y string) string {
+	if op.eq == "" {
+		return x + " != " + y
+	}
+	return "!" + op.eq + "(" + x + ", " + y + ")"
+}
+
+// identityExpr builds the identity element described by id, wrapped if the
+// operand is.
+func (g *generator) identityExpr(c Candidate, op operand, id *Identity) string {
+	var e string
+	switch {
+	case id.Constructor != nil:
+		sig := id.Constructor.Type().(*types.Signature)
+		name := id.Constructor.Name()
+		if sig.TypeParams().Len() > 0 {
+			sig = instantiate(id.Constructor, c.TypeArgs)
+			name += g.typeArgs(c.TypeArgs)
+		}
+		args := make([]string, sig.Params().Len())
+		for i := range args {
+			args[i] = g.typeString(sig.Params().At(i).Type()) + "{}"
+		}
+		e = name + "(" + strings.Join(args, ", ") + ")"
+	case id.Value != "":
+		e = id.Value
+	default:
+		e = g.emptyExpr(c.Type)
+	}
+	if op.wrapper != "" {
+		e = "&" + op.wrapper + "{" + op.field + ": " + e + "}"
+	}
+	return e
+}
+
+// emptyExpr returns the empty value of t: an empty map, slice or struct.
+func (g *generator) emptyExpr(t types.Type) string {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return "&" + g.typeString(p.Elem()) + "{}"
+	}
+	if _, ok := t.Underlying().(*types.Map); ok {
+		return "make(" + g.typeString(t) + ")"
+	}
+	return g.typeString(t) + "{}"
+}
+
+// dual returns the candidate named name on c's type, if c is declared first
+// or is the only one of the pair to propose absorption (as can happen with
+// //lawtest:laws); the absorption test for a pair is generated once.
+func (g *generator) dual(c Candidate, name string) (Candidate, bool) {
+	for _, d := range g.candidates {
+		if d.FuncName == name && types.Identical(d.Type, c.Type) {
+			first := c.Pos.Offset < d.Pos.Offset || c.Pos.Filename < d.Pos.Filename
+			return d, first || !absorbs(d, c.FuncName)
+		}
+	}
+	return Candidate{}, false
+}
+
+// absorbs reports whether c proposes absorption with the operation dual.
+func absorbs(c Candidate, dual string) bool {
+	for _, law := range c.Laws {
+		if law.Name == lawAbsorption && law.Dual == dual {
+			return true
+		}
+	}
+	return false
+}
+
+// genExpr returns an expression producing a random value of t. Strings and
+// integers are drawn from small ranges so that generated maps and slices
+// overlap often enough to exercise conflict handling.
+func (g *generator) genExpr(t types.Type, depth int) string {
+	typ := g.typeString(t)
+	if depth > maxGenDepth {
+		return zeroExpr(typ)
+	}
+
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Time" {
+		g.imports["time"] = "time"
+		return "time.Unix(int64(" + g.intGen("0", "1<<30") + "), 0)"
+	}
+
+	// Unexported types nested in a value are its internals, with invariants
+	// random values break (a sorted tree, a cached size): build them with
+	// their constructor, or leave them zero.
+	if depth > 0 && g.internal(t) {
+		return g.constructorExpr(t, depth)
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return g.basicExpr(u, typ, t != u)
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Struct); ok {
+			return "&" + g.genExpr(u.Elem(), depth+1)
+		}
+		return fmt.Sprintf("func() %s {\nv := %s\nreturn &v\n}()", typ, g.genExpr(u.Elem(), depth+1))
+	case *types.Slice:
+		return fmt.Sprintf("func() %s {\nv := make(%s, %s)\nfor i := range v {\nv[i] = %s\n}\nreturn v\n}()",
+			typ, typ, g.intGen("0", "3"), g.genExpr(u.Elem(), depth+1))
+	case *types.Array:
+		return fmt.Sprintf("func() %s {\nvar v %s\nfor i := range v {\nv[i] = %s\n}\nreturn v\n}()",
+			typ, typ, g.genExpr(u.Elem(), depth+1))
+	case *types.Map:
+		return fmt.Sprintf("func() %s {\nv := make(%s)\nfor range %s {\nv[%s] = %s\n}\nreturn v\n}()",
+			typ, typ, g.intGen("1", "3"), g.keyExpr(u.Key(), depth+1), g.genExpr(u.Elem(), depth+1))
+	case *types.Struct:
+		var fields []string
+		for i := range u.NumFields() {
+			f := u.Field(i)
+			if !f.Exported() && f.Pkg() != g.pkg {
+				continue
+			}
+			expr := g.genExpr(f.Type(), depth+1)
+			if expr == zeroExpr(g.typeString(f.Type())) {
+				continue
+			}
+			fields = append(fields, f.Name()+": "+expr)
+		}
+		if len(fields) == 0 {
+			return typ + "{}"
+		}
+		return typ + "{\n" + strings.Join(fields, ",\n") + ",\n}"
+	case *types.Interface:
+		if u.Empty() {
+			return g.stringGen(5)
+		}
+	}
+	return zeroExpr(typ)
+}
+
+func zeroExpr(typ string) string {
+	return "*new(" + typ + ")"
+}
+
+// internal reports whether t is, or points to, a type the package under
+// test doesn't export.
+func (g *generator) internal(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() == g.pkg && !named.Obj().Exported()
+}
+
+// constructorExpr calls the constructor of t, newT or NewT returning a t
+// from arguments of other types, or returns the zero t if there is none.
+func (g *generator) constructorExpr(t types.Type, depth int) string {
+	named := t
+	if p, ok := t.(*types.Pointer); ok {
+		named = p.Elem()
+	}
+	name := upperFirst(named.(*types.Named).Obj().Name())
+	for _, ctor := range []string{"new" + name, "New" + name} {
+		fn, ok := g.pkg.Scope().Lookup(ctor).(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.TypeParams().Len() > 0 || sig.Variadic() || sig.Results().Len() != 1 ||
+			!types.Identical(sig.Results().At(0).Type(), t) {
+			continue
+		}
+		var args []string
+		for v := range sig.Params().Variables() {
+			if types.Identical(v.Type(), t) {
+				break
+			}
+			args = append(args, g.genExpr(v.Type(), depth+1))
+		}
+		if len(args) == sig.Params().Len() {
+			return ctor + "(" + strings.Join(args, ", ") + ")"
+		}
+	}
+	return zeroExpr(g.typeString(t))
+}
+
+// keyExpr is genExpr for map keys, drawn from a tiny domain so that keys
+// collide between generated maps.
+func (g *generator) keyExpr(t types.Type, depth int) string {
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		switch {
+		case b.Info()&types.IsString != 0:
+			return convert(g.typeString(t), t != b, g.stringGen(1))
+		case b.Info()&types.IsInteger != 0:
+			return convert(g.typeString(t), true, g.intGen("0", "9"))
+		}
+	}
+	return g.genExpr(t, depth)
+}
+
+func (g *generator) basicExpr(b *types.Basic, typ string, named bool) string {
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return convert(typ, named, g.boolGen())
+	case info&types.IsString != 0:
+		return convert(typ, named, g.stringGen(5))
+	case info&types.IsUnsigned != 0:
+		return convert(typ, true, g.intGen("0", "100"))
+	case info&types.IsInteger != 0:
+		return convert(typ, b.Kind() != types.Int || named, g.intGen("-100", "100"))
+	case info&types.IsFloat != 0:
+		return convert(typ, b.Kind() != types.Float64 || named, g.float64Gen("-100", "100"))
+	case info&types.IsComplex != 0:
+		return convert(typ, true, "complex("+g.float64Gen("-100", "100")+", "+g.float64Gen("-100", "100")+")")
+	}
+	return zeroExpr(typ)
+}
+
+func convert(typ string, needed bool, expr string) string {
+	if !needed {
+		return expr
+	}
+	return typ + "(" + expr + ")"
+}
+
+// typeString writes t as it must appear in the test file, recording the
+// imports it needs.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		if g.imports != nil {
+			g.imports[p.Path()] = p.Name()
+		}
+		return p.Name()
+	})
+}
+
+// typeArgs returns the explicit instantiation [A, B] for args, or "".
+func (g *generator) typeArgs(args []types.Type) string {
+	if len(args) == 0 {
+		return ""
+	}
+	s := make([]string, len(args))
+	for i, t := range args {
+		s[i] = g.typeString(t)
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+// declare records that name is being emitted, reporting false if an
+// earlier candidate of the package already emitted it.
+func (g *generator) declare(name string) bool {
+	if g.declared[name] {
+		return false
+	}
+	g.declared[name] = true
+	return true
+}
+
+// unique returns name, or name with a numeric suffix if the package
+// already declares it.
+func (g *generator) unique(name string) string {
+	candidate := name
+	for i := 2; g.pkg.Scope().Lookup(candidate) != nil; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	return candidate
+}
+
+// baseName names the helpers generated for c: the operand's type name when it
+// has one (Config, State, Int), otherwise the function name (SafeMerge).
+func baseName(c Candidate) string {
+	t := c.Type
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		return upperFirst(t.Obj().Name())
+	case *types.Basic:
+		return upperFirst(t.Name())
+	}
+	return upperFirst(c.FuncName)
+}
+
+// testName names the tests for c. Methods are prefixed with their type so
+// that State.Merge and Items.Merge do not collide.
+func testName(c Candidate) string {
+	if c.Receiver == "" {
+		return upperFirst(c.FuncName)
+	}
+	return baseName(c) + upperFirst(c.FuncName)
+}
+
+func wrapperField(t types.Type) string {
+	if named, ok := t.(*types.Named); ok {
+		return lowerFirst(named.Obj().Name())
+	}
+	return "value"
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+// hasReferences reports whether values of t reach a pointer, map, slice,
+// func or channel, which == compares by identity if at all.
+func hasReferences(t types.Type) bool {
+	seen := make(map[types.Type]bool)
+	var reaches func(t types.Type) bool
+	reaches = func(t types.Type) bool {
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		switch u := t.Underlying().(type) {
+		case *types.Pointer, *types.Map, *types.Slice, *types.Signature, *types.Chan:
+			return true
+		case *types.Array:
+			return reaches(u.Elem())
+		case *types.Struct:
+			for i := range u.NumFields() {
+				if reaches(u.Field(i).Type()) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return reaches(t)
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TestGenerateTestFiles checks snippets of the files generated for each
+// kind of candidate.
+func TestGenerateTestFiles(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		notWant []string
+	}{
+		{
+			pattern: "examples/slice_example.go",
+			want: []string{
+				"type ItemsWrapper struct",
+				"func itemsEqual(a, b *ItemsWrapper) bool",
+				"func genItems() *ItemsWrapper",
+				"func WrapItemsMerge(a, b *ItemsWrapper) *ItemsWrapper",
+				"if left, right := WrapItemsMerge(WrapItemsMerge(a, b), c), WrapItemsMerge(a, WrapItemsMerge(b, c)); !itemsEqual(left, right) {",
+				"// Idempotence was not proposed: the name Merge suggests it",
+			},
+			notWant: []string{"panic(", "t.Skip", "TestItemsMergeIdempotence"},
+		},
+		{
+			// Existing wrappers are reused, not tested separately
+			pattern: "../config-merge-example/config.go",
+			want: []string{
+				"WrapDeepMerge(WrapDeepMerge(a, b), c), WrapDeepMerge(a, WrapDeepMerge(b, c)); !configEqual(left, right)",
+			},
+			notWant: []string{"type ConfigWrapper", "func WrapMerge", "TestWrapMerge"},
+		},
+		{
+			pattern: "./testdata/generic",
+			want: []string{
+				"lawtest.Associative(t, Sum[int], genInt)",
+				"return &MergeMapsWrapper{value: MergeMaps(a.value, b.value)}",
+				"type SetWrapper struct {\n\tset Set[int]\n}",
+				"e := &SetWrapper{set: NewSet[int]()}",
+				"lawtest.Associative(t, Pair[int].Zip, genPair)",
+			},
+		},
+		{
+			pattern: "./testdata/fold",
+			want: []string{
+				"return &ConfigWrapper{config: MergeAll(a.config, b.config)}",
+				"got, want := MergeAll(a, b, c), Merge(Merge(a, b), c); !reflect.DeepEqual(got, want)",
+				"func sumPair(a, b int) int {\n\treturn Sum(a, b)\n}",
+				"lawtest.Associative(t, sumPair, genInt)",
+				"got, want := Sum(a, b, c), Sum(Sum(a, b), c); got != want",
+				"s, x, y := genCounter(), genOp(), genOp()",
+				"got, want := s.Apply(x, y), s.Apply(x).Apply(y); got != want",
+			},
+		},
+		{
+			pattern: "./testdata/fallible",
+			want: []string{
+				"func tightenOp(a, b Limits) Limits {\n\tv, _ := Tighten(a, b)\n\treturn v\n}",
+				"lawtest.ImmutableOp(t, tightenOp, genLimits)",
+				"ab, err := Tighten(a, b)\n\t\tif err != nil {\n\t\t\tcontinue\n\t\t}",
+				"func TestTightenErrorStability(t *testing.T)",
+				"v, _ := Union(context.Background(), a.tags, b.tags)",
+				"if got, err := Union(context.Background(), a, a); err == nil && !reflect.DeepEqual(got, a)",
+				"lawtest.Commutative(t, addOp, genInt)",
+				"func FuzzUnionLaws(f *testing.F) {",
+				"a, b, c := decodeTags(&in), decodeTags(&in), decodeTags(&in)",
+				"aOrig, bOrig := decodeTags(&in), decodeTags(&in)",
+				"_, _ = Union(context.Background(), a, b)",
+				"v, err := Union(context.Background(), x, y)\n\t\t\tif err != nil {\n\t\t\t\tt.Skip(err)",
+				"if left, right := op(op(a, b), c), op(a, op(b, c)); !reflect.DeepEqual(left, right)",
+				"func FuzzAddLaws(f *testing.F) {",
+				"return fuzzInt(in, -100, 100)",
+				"func fuzzByte(in *lawFuzzInput) byte {",
+			},
+		},
+		{
+			// Decoding again gives new pointers: Tree values only compare
+			// equal by content
+			pattern: "./testdata/unexported",
+			want: []string{
+				"root: newNode(lawtest.IntGen(-100, 100)()),",
+				"root: newNode(fuzzInt(in, -100, 100)),",
+				"if got := Tree.Union(a, e); !Tree.Equal(got, a) {",
+				"if !Tree.Equal(a, aOrig) || !Tree.Equal(b, bOrig) {",
+				"if left, right := a.Union(b).Union(c), a.Union(b.Union(c)); !Tree.Equal(left, right) {",
+			},
+			notWant: []string{"*new("},
+		},
+		{
+			pattern: "./testdata/transition",
+			want: []string{
+				"func TestCountersSetImmutability(t *testing.T) {",
+				"name, n := lawtest.StringGen(1)(), lawtest.IntGen(-100, 100)()",
+				`before := fmt.Sprintf("%#v", s)`,
+				"if got, want := s.Delete(name), s.Delete(name); !reflect.DeepEqual(got, want) {",
+				"if left, right := a.Merge(b.Set(name, n)), a.Merge(b).Set(name, n); !reflect.DeepEqual(left, right) {",
+				"if got, want := Normalize(s), Normalize(s); !reflect.DeepEqual(got, want) {",
+			},
+			notWant: []string{"FuzzCountersSetLaws", "WrapCountersSet"},
+		},
+		{
+			pattern: "./testdata/contract",
+			want: []string{
+				"// Code generated by lawtest-gen. DO NOT EDIT.",
+				"func StoreConformance(t *testing.T, factory func() Store) {",
+				"s = s.Put(key, n)",
+				`t.Run("JoinAssociativity"`,
+				`t.Run("JoinCommutativity"`,
+				`t.Run("JoinIdentity"`,
+				`t.Run("PutImmutability"`,
+				`t.Run("DeleteDeterminism"`,
+				`t.Run("ParallelSafety"`,
+				`obs["All()"] = maps.Collect(v.All())`,
+				"StoreConformance(t, func() Store {\n\t\treturn NewMapStore(map[string]int{})\n\t})",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			_, files, _ := generateFiles(t, tt.pattern)
+			var all strings.Builder
+			for _, filename := range slices.Sorted(maps.Keys(files)) {
+				if n := strings.Count(string(files[filename]), "type lawFuzzInput"); n > 1 {
+					t.Errorf("%s declares lawFuzzInput %d times", filename, n)
+				}
+				all.Write(files[filename])
+			}
+			src := all.String()
+			for _, want := range tt.want {
+				if !strings.Contains(src, want) {
+					t.Errorf("Generated tests missing %q\n%s", want, src)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(src, notWant) {
+					t.Errorf("Generated tests contain %q\n%s", notWant, src)
+				}
+			}
+		})
+	}
+}
+
+// TestGeneratedTestsPass runs the generated tests, and the seeds of the
+// generated fuzz targets, with go test against the lawtest version of
+// go.mod, which has no Custom variants of its checks. The laws proposed for
+// these packages hold, so every test must pass, not just compile.
+func TestGeneratedTestsPass(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on each package")
+	}
+	for _, pattern := range []string{
+		"./examples",
+		"../config-merge-example/config.go",
+		"./testdata/annotated",
+		"./testdata/contract",
+		"./testdata/fallible",
+		"./testdata/fold",
+		"./testdata/generic",
+		"./testdata/lattice",
+		"./testdata/transition",
+		"./testdata/unexported",
+	} {
+		t.Run(pattern, func(t *testing.T) {
+			dir, files, tests := generateFiles(t, pattern)
+
+			// Point go test at the generated files through an overlay,
+			// leaving the package directory untouched
+			tmp := t.TempDir()
+			replace := make(map[string]string)
+			for filename, content := range files {
+				path := filepath.Join(tmp, filepath.Base(filename))
+				if err := os.WriteFile(path, content, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				replace[filename] = path
+			}
+			overlay, err := json.Marshal(map[string]any{"Replace": replace})
+			if err != nil {
+				t.Fatal(err)
+			}
+			overlayFile := filepath.Join(tmp, "overlay.json")
+			if err := os.WriteFile(overlayFile, overlay, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// Only the generated tests: config-merge-example's own demo
+			// tests fail on purpose
+			run := "^(" + strings.Join(tests, "|") + ")$"
+			cmd := exec.Command("go", "test", "-count=1", "-overlay="+overlayFile, "-run="+run, ".")
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("go test: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+// generateFiles generates the test and conformance files of the package
+// matched by pattern, by path, and returns its directory and the names of
+// the generated tests and fuzz targets.
+func generateFiles(t *testing.T, pattern string) (dir string, files map[string][]byte, tests []string) {
+	t.Helper()
+	pkgs, err := loadCandidates([]string{pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("Expected one package for %s, got %d", pattern, len(pkgs))
+	}
+	pkg := pkgs[0]
+	g := newGenerator(pkg)
+	files = make(map[string][]byte)
+	for _, c := range pkg.Contracts {
+		content, err := g.generateConformanceFile(pkg.Name, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[conformanceFile(pkg.Dir, c)] = []byte(content)
+	}
+	for _, filename := range sourceFiles(pkg) {
+		content, err := g.generateTestFile(pkg.Name, candidatesIn(pkg.Candidates, filename), contractsIn(pkg.Contracts, filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[strings.TrimSuffix(filename, ".go")+"_law_test.go"] = []byte(content)
+		tests = append(tests, slices.Collect(maps.Keys(g.tests))...)
+	}
+	return pkg.Dir, files, tests
+}
+
+// typeCheck loads the package in dir and its tests, with overlay replacing
+// or adding files, and fails t on any error.
+func typeCheck(t *testing.T, dir string, overlay map[string][]byte) {
+	t.Helper()
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:     abs,
+		Tests:   true,
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			t.Errorf("%s: %v", pkg.ID, err)
+		}
+	})
+	if t.Failed() {
+		for filename, content := range overlay {
+			t.Logf("%s:\n%s", filename, content)
+		}
+	}
+}
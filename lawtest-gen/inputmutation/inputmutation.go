@@ -0,0 +1,264 @@
+// Package inputmutation defines an Analyzer that reports law candidates
+// writing into their inputs.
+//
+// lawtest-gen proposes immutability for every binary operation, fold and
+// state transition, and the generated test catches a mutation at run time,
+// if the generators happen to hit it. This analyzer finds the same bugs
+// statically, so they show up in go vet and in the editor before any test
+// runs.
+package inputmutation
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/alexshd/lawtest-gen/shape"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const doc = `report law candidates that mutate their inputs
+
+Functions shaped like the operations lawtest-gen tests (func(T, T) T,
+func (T) M(T) T, variadic folds, optionally with a context.Context and an
+error, and transitions such as func (T) Set(k, v string) T) must leave
+their operands untouched (Law I: immutability). The
+analyzer reports writes that reach memory shared with the caller: map and
+slice elements, pointer targets, delete, clear, copy and in-place sorts of
+an input, directly or through a local variable aliasing it.`
+
+// Analyzer reports law candidates that mutate their inputs.
+var Analyzer = &analysis.Analyzer{
+	Name:     "inputmutation",
+	Doc:      doc,
+	URL:      "https://pkg.go.dev/github.com/alexshd/lawtest-gen/inputmutation",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// inPlace are the library functions that modify their first argument.
+var inPlace = map[string]bool{
+	"sort.Float64s":         true,
+	"sort.Ints":             true,
+	"sort.Slice":            true,
+	"sort.SliceStable":      true,
+	"sort.Strings":          true,
+	"slices.Reverse":        true,
+	"slices.Sort":           true,
+	"slices.SortFunc":       true,
+	"slices.SortStableFunc": true,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		fn := n.(*ast.FuncDecl)
+		if fn.Body == nil || ignored(fn.Doc) {
+			return
+		}
+		obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+		if !ok {
+			return
+		}
+		op := shape.Match(obj.Type().(*types.Signature))
+		if op == nil {
+			return
+		}
+		c := &checker{
+			pass:    pass,
+			name:    fn.Name.Name,
+			aliases: make(map[*types.Var]*types.Var),
+			rebound: make(map[string]bool),
+		}
+		for _, v := range op.Inputs {
+			if v.Name() != "" && v.Name() != "_" {
+				c.aliases[v] = v
+			}
+		}
+		ast.Inspect(fn.Body, c.visit)
+	})
+	return nil, nil
+}
+
+// checker follows one candidate's body in source order.
+type checker struct {
+	pass *analysis.Pass
+	name string
+	// aliases maps each input, and each local variable derived from one,
+	// to the input. Writes through an alias mutate the input only when
+	// they reach shared memory.
+	aliases map[*types.Var]*types.Var
+	// rebound holds the fields, such as c.items, that were assigned a fresh
+	// value and no longer share memory with the caller.
+	rebound map[string]bool
+}
+
+func (c *checker) visit(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range n.Lhs {
+			c.write(lhs, lhs)
+		}
+		if n.Tok != token.DEFINE && n.Tok != token.ASSIGN {
+			return true
+		}
+		for i, lhs := range n.Lhs {
+			var rhs ast.Expr
+			if len(n.Rhs) == len(n.Lhs) {
+				rhs = n.Rhs[i]
+			}
+			c.bind(lhs, rhs)
+		}
+
+	case *ast.ValueSpec:
+		for i, name := range n.Names {
+			var rhs ast.Expr
+			if len(n.Values) == len(n.Names) {
+				rhs = n.Values[i]
+			}
+			c.bind(name, rhs)
+		}
+
+	case *ast.RangeStmt:
+		if n.Value != nil && n.Tok != token.ILLEGAL {
+			c.bind(n.Value, n.X)
+		}
+
+	case *ast.IncDecStmt:
+		c.write(n.X, n.X)
+
+	case *ast.CallExpr:
+		if len(n.Args) == 0 {
+			return true
+		}
+		switch callee := typeutil.Callee(c.pass.TypesInfo, n).(type) {
+		case *types.Builtin:
+			switch callee.Name() {
+			case "delete", "clear", "copy":
+				c.modify(n.Args[0], n)
+			}
+		case *types.Func:
+			if callee.Pkg() != nil && inPlace[callee.Pkg().Path()+"."+callee.Name()] {
+				c.modify(n.Args[0], n)
+			}
+		}
+	}
+	return true
+}
+
+// write reports an assignment to lhs that reaches an input's memory.
+func (c *checker) write(lhs, at ast.Expr) {
+	if input, shared := c.root(lhs); input != nil && shared {
+		c.report(input, at)
+	}
+}
+
+// modify reports a call modifying the map or slice arg in place.
+func (c *checker) modify(arg, at ast.Expr) {
+	if input, _ := c.root(arg); input != nil {
+		switch c.pass.TypesInfo.TypeOf(arg).Underlying().(type) {
+		case *types.Map, *types.Slice:
+			c.report(input, at)
+		}
+	}
+}
+
+func (c *checker) report(input *types.Var, at ast.Expr) {
+	c.pass.Reportf(at.Pos(), "%s mutates its input %s in %s: law candidates must leave their operands untouched (Law I)",
+		c.name, input.Name(), types.ExprString(at))
+}
+
+// bind records what lhs refers to after being assigned rhs; rhs is nil
+// when it can't be told, as with a multi-valued call.
+func (c *checker) bind(lhs, rhs ast.Expr) {
+	var input *types.Var
+	if rhs != nil {
+		input, _ = c.root(rhs)
+	}
+	if id, ok := lhs.(*ast.Ident); ok {
+		v, ok := c.object(id).(*types.Var)
+		if !ok {
+			return
+		}
+		if input != nil {
+			c.aliases[v] = input
+		} else {
+			// A fresh value, like a = slices.Clone(a)
+			delete(c.aliases, v)
+		}
+		return
+	}
+	if to, shared := c.root(lhs); to != nil && !shared && input == nil {
+		// A field of a value receiver given a fresh value, like
+		// c.items = maps.Clone(c.items)
+		c.rebound[types.ExprString(lhs)] = true
+	}
+}
+
+// root returns the input e is derived from, if any, and whether reaching e
+// from that input goes through shared memory: a map or slice element, or a
+// pointer indirection.
+func (c *checker) root(e ast.Expr) (input *types.Var, shared bool) {
+	if c.rebound[types.ExprString(e)] {
+		return nil, false
+	}
+	switch e := e.(type) {
+	case *ast.Ident:
+		v, ok := c.object(e).(*types.Var)
+		if !ok {
+			return nil, false
+		}
+		return c.aliases[v], false
+	case *ast.ParenExpr:
+		return c.root(e.X)
+	case *ast.StarExpr:
+		input, _ := c.root(e.X)
+		return input, true
+	case *ast.IndexExpr:
+		input, shared := c.root(e.X)
+		switch c.pass.TypesInfo.TypeOf(e.X).Underlying().(type) {
+		case *types.Map, *types.Slice, *types.Pointer:
+			shared = true
+		}
+		return input, shared
+	case *ast.SliceExpr:
+		input, shared := c.root(e.X)
+		if _, ok := c.pass.TypesInfo.TypeOf(e.X).Underlying().(*types.Slice); ok {
+			shared = true
+		}
+		return input, shared
+	case *ast.SelectorExpr:
+		sel, ok := c.pass.TypesInfo.Selections[e]
+		if !ok || sel.Kind() != types.FieldVal {
+			return nil, false
+		}
+		input, shared := c.root(e.X)
+		return input, shared || sel.Indirect()
+	}
+	return nil, false
+}
+
+// ignored reports whether doc marks the function //lawtest:ignore, as
+// lawtest-gen does for operations that are not meant to obey any law.
+func ignored(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if c.Text == "//lawtest:ignore" || strings.HasPrefix(c.Text, "//lawtest:ignore ") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) object(id *ast.Ident) types.Object {
+	if obj := c.pass.TypesInfo.Defs[id]; obj != nil {
+		return obj
+	}
+	return c.pass.TypesInfo.Uses[id]
+}
@@ -0,0 +1,12 @@
+package inputmutation_test
+
+import (
+	"testing"
+
+	"github.com/alexshd/lawtest-gen/inputmutation"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), inputmutation.Analyzer, "ops")
+}
@@ -0,0 +1,157 @@
+package ops
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+)
+
+type Config map[string]int
+
+// Merge writes b's keys into a instead of a copy.
+func Merge(a, b Config) Config {
+	for k, v := range b {
+		a[k] = v // want `Merge mutates its input a in a\[k\]`
+	}
+	return a
+}
+
+// MergeAlias does the same through a local variable.
+func MergeAlias(a, b Config) Config {
+	out := a
+	for k, v := range b {
+		out[k] = v // want `MergeAlias mutates its input a in out\[k\]`
+	}
+	return out
+}
+
+// MergeCopy is correct: it writes into a fresh map.
+func MergeCopy(a, b Config) Config {
+	out := maps.Clone(a)
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+// Intersect deletes from its input.
+func Intersect(a, b Config) Config {
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			delete(a, k) // want `Intersect mutates its input a in delete\(a, k\)`
+		}
+	}
+	return a
+}
+
+type Set struct {
+	items map[string]bool
+	size  int
+}
+
+// Union writes into the receiver's map.
+func (s Set) Union(o Set) Set {
+	for k := range o.items {
+		s.items[k] = true // want `Union mutates its input s in s.items\[k\]`
+		s.size++
+	}
+	return s
+}
+
+// Intersect replaces the receiver's map first, which is fine.
+func (s Set) Intersect(o Set) Set {
+	s.items = maps.Clone(s.items)
+	for k := range s.items {
+		if !o.items[k] {
+			delete(s.items, k)
+			s.size--
+		}
+	}
+	return s
+}
+
+type Counter struct{ n int }
+
+// Add increments through a pointer receiver.
+func (c *Counter) Add(o *Counter) *Counter {
+	c.n += o.n // want `Add mutates its input c in c.n`
+	return c
+}
+
+// Max mutates nothing: a and b are copies.
+func Max(a, b Counter) Counter {
+	if b.n > a.n {
+		a.n = b.n
+	}
+	return a
+}
+
+// Sorted sorts its input in place.
+func Sorted(a, b []int) []int {
+	sort.Ints(a) // want `Sorted mutates its input a in sort.Ints\(a\)`
+	return append(slices.Clone(a), b...)
+}
+
+// SortedCopy sorts a clone, which is fine.
+func SortedCopy(a, b []int) []int {
+	a = slices.Clone(a)
+	slices.Sort(a)
+	a[0] = 0
+	return append(a, b...)
+}
+
+// Concat writes into b's backing array.
+func Concat(ctx context.Context, a, b []int) ([]int, error) {
+	copy(b, a) // want `Concat mutates its input b in copy\(b, a\)`
+	return b, ctx.Err()
+}
+
+// MergeAll folds into its first argument.
+func MergeAll(cs ...Config) Config {
+	for _, c := range cs[1:] {
+		for k, v := range c {
+			cs[0][k] = v // want `MergeAll mutates its input cs in cs\[0\]\[k\]`
+		}
+	}
+	return cs[0]
+}
+
+type Point struct{ X, Y *int }
+
+// Shift writes through a pointer field it copied.
+func Shift(a, b Point) Point {
+	x := a.X
+	*x += *b.X // want `Shift mutates its input a in \*x`
+	return a
+}
+
+// Set writes into the receiver's map instead of a copy.
+func (c Config) Set(k string, v int) Config {
+	c[k] = v // want `Set mutates its input c in c\[k\]`
+	return c
+}
+
+// With sets k in a copy, which is fine.
+func (c Config) With(k string, v int) Config {
+	out := maps.Clone(c)
+	out[k] = v
+	return out
+}
+
+// Scale is not a law candidate, so it is not checked.
+func Scale(c Config, f int) {
+	for k := range c {
+		c[k] *= f
+	}
+}
+
+// AddAll is a builder that fills s in place on purpose.
+//
+//lawtest:ignore
+func (s *Set) AddAll(o *Set) *Set {
+	for k := range o.items {
+		s.items[k] = true
+	}
+	return s
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Law names, in the order tests are generated.
+const (
+	lawImmutable   = "immutable"
+	lawAssociative = "associative"
+	lawCommutative = "commutative"
+	lawIdempotent  = "idempotent"
+	lawIdentity    = "identity"
+	lawAbsorption  = "absorption"
+
+	// Laws of variadic folds, see fold.go.
+	lawFlatten     = "flatten"
+	lawPairwise    = "pairwise"
+	lawComposition = "composition"
+
+	// lawStableErrors is proposed for operations returning an error.
+	lawStableErrors = "stable-errors"
+
+	// Laws of state transitions such as Set(key, value string), see
+	// transition.go and contract.go.
+	lawDeterministic = "deterministic"
+	lawCommutation   = "commutation"
+)
+
+// lawTitles name the laws that may only be suggested, see Candidate.Suggested.
+var lawTitles = map[string]string{lawIdempotent: "Idempotence"}
+
+// Law is an algebraic law proposed for a candidate, with the reason it was
+// proposed so the user can judge whether the operation really should obey it.
+type Law struct {
+	Name   string
+	Reason string
+
+	// Identity is the identity element, for lawIdentity.
+	Identity *Identity
+	// Dual is the FuncName of the other operation, for lawAbsorption.
+	Dual string
+	// Pairwise is the FuncName of the binary operation, for lawPairwise.
+	Pairwise string
+	// Merge is the FuncName of the binary operation a transition commutes
+	// with, for lawCommutation. MergeRight is set when the transition
+	// applies to the argument of Merge before merging, rather than to the
+	// receiver.
+	Merge      string
+	MergeRight bool
+}
+
+// Identity describes an identity element: a constructor called with empty
+// arguments, a constant, or (when both are empty) the empty value of the type.
+type Identity struct {
+	Constructor *types.Func
+	Value       string
+}
+
+// operatorLaws lists what the Go operators (and the max/min builtins) are
+// known to satisfy on integers.
+var operatorLaws = map[string]struct {
+	associative, commutative, idempotent bool
+	identity                             string
+}{
+	"+":   {true, true, false, "0"},
+	"*":   {true, true, false, "1"},
+	"&":   {true, true, true, ""},
+	"|":   {true, true, true, "0"},
+	"^":   {true, true, false, "0"},
+	"&&":  {true, true, true, "true"},
+	"||":  {true, true, true, "false"},
+	"max": {true, true, true, ""},
+	"min": {true, true, true, ""},
+	"-":   {},
+	"/":   {},
+	"%":   {},
+	"<<":  {},
+	">>":  {},
+	"&^":  {},
+}
+
+// dualOperators and dualNames pair operations that absorb each other:
+// a ∘ (a • b) = a.
+var (
+	dualOperators = [][2]string{{"&", "|"}, {"&&", "||"}, {"max", "min"}}
+	dualNames     = [][2]string{{"union", "intersect"}, {"max", "min"}, {"or", "and"}, {"join", "meet"}, {"lcm", "gcd"}}
+)
+
+// Name fragments that hint at a law when nothing more precise is known.
+var (
+	commutativeNames = []string{"union", "intersect", "join", "meet", "add", "sum", "max", "min", "mul", "gcd", "lcm"}
+	idempotentNames  = []string{"union", "intersect", "join", "meet", "max", "min"}
+	identityNames    = []string{"merge", "union", "join", "combine", "concat", "append", "add", "sum"}
+	precedenceWords  = []string{"precedence", "overwrite", "override", "wins", "priority"}
+)
+
+// inferLaws proposes laws for c from its body, its name and the comments in
+// and around its declaration.
+func inferLaws(c *Candidate, fn *ast.FuncDecl, comments string, info *types.Info, scope *types.Scope) {
+	name := strings.ToLower(c.FuncName)
+	comments = strings.ToLower(comments)
+	op := bodyOperator(fn, info)
+	known, hasOp := operatorLaws[op]
+	isString := hasBasicInfo(c.Type, types.IsString)
+	isFloat := hasBasicInfo(c.Type, types.IsFloat)
+
+	c.addLaw(lawImmutable, "every candidate must leave its inputs untouched (Law I)")
+
+	switch {
+	case hasOp && isFloat && (op == "+" || op == "*"):
+		// floating-point rounding breaks associativity; don't propose it
+	case hasOp && !known.associative:
+		// a - b, a / b, ... are not associative
+	case hasOp:
+		c.addLaw(lawAssociative, "body is "+describeOp(op)+", which is associative")
+	default:
+		c.addLaw(lawAssociative, "func(T, T) T operations are chained and parallelized, which is only safe if grouping doesn't matter")
+	}
+
+	switch {
+	case hasOp && known.commutative && !(isString && op == "+"):
+		c.addLaw(lawCommutative, "body is "+describeOp(op)+", which is commutative")
+	case hasOp:
+	case mentions(comments, "commutative") && !mentions(comments, "not commutative", "non-commutative"):
+		c.addLaw(lawCommutative, "comments describe "+c.FuncName+" as commutative")
+	case hasAny(name, commutativeNames) && !mentions(comments, precedenceWords...):
+		c.addLaw(lawCommutative, "the name "+c.FuncName+" suggests a symmetric operation")
+	case strings.Contains(name, "merge") && !mentions(comments, precedenceWords...) && mentions(comments, "crdt", "unique", "dedup"):
+		c.addLaw(lawCommutative, "comments describe a merge that keeps all unique entries, which should not depend on operand order")
+	}
+
+	switch {
+	case hasOp && known.idempotent:
+		c.addLaw(lawIdempotent, "body is "+describeOp(op)+", which is idempotent")
+	case hasOp:
+	case mentions(comments, "idempotent"):
+		c.addLaw(lawIdempotent, "comments describe "+c.FuncName+" as idempotent")
+	case hasAny(name, idempotentNames):
+		c.addLaw(lawIdempotent, "combining a value with itself through "+c.FuncName+" should change nothing")
+	case strings.Contains(name, "merge") && overwrites(fn, info):
+		c.addLaw(lawIdempotent, "the body only copies entries into the result, so merging a value with itself changes nothing")
+	case strings.Contains(name, "merge"):
+		// A merge may as well sum or concatenate: leave it to the user
+		c.Suggested = append(c.Suggested, Law{Name: lawIdempotent,
+			Reason: "the name " + c.FuncName + " suggests it, but the body may accumulate, as sums and concatenations do"})
+	}
+
+	switch {
+	case hasOp && isString && op == "+":
+		c.addIdentity(&Identity{Value: `""`}, `body is a + b on strings, whose identity is ""`)
+	case hasOp && known.identity != "":
+		c.addIdentity(&Identity{Value: known.identity}, "body is "+describeOp(op)+", whose identity is "+known.identity)
+	case hasOp:
+	case mentions(comments, "identity", "empty") || hasAny(name, identityNames):
+		if ctor := emptyConstructor(c.Type, c.TypeArgs, scope); ctor != nil {
+			c.addIdentity(&Identity{Constructor: ctor}, ctor.Name()+" with empty input should build a value that "+c.FuncName+" leaves unchanged")
+		} else if hasEmptyValue(c.Type) {
+			c.addIdentity(&Identity{}, "the empty "+c.TypeName+" should leave the other operand of "+c.FuncName+" unchanged")
+		}
+	}
+
+	if c.ReturnsError {
+		c.addLaw(lawStableErrors, "errors should depend only on the inputs, so the same inputs always fail the same way")
+	}
+
+	c.operator = op
+	c.precedence = precedence(comments)
+}
+
+// inferAbsorption proposes absorption for pairs of candidates on the same
+// type whose operators or names are lattice duals, such as Union/Intersect.
+func inferAbsorption(candidates []Candidate) {
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			a, b := &candidates[i], &candidates[j]
+			if !a.binary() || !b.binary() || a.ReturnsError || b.ReturnsError || a.Annotated || b.Annotated ||
+				!types.Identical(a.Type, b.Type) {
+				continue
+			}
+			var reason string
+			if isDual(a.operator, b.operator, dualOperators) {
+				reason = describeOp(a.operator) + " and " + describeOp(b.operator) + " are lattice duals"
+			} else if a.operator == "" && b.operator == "" && isDualName(a.FuncName, b.FuncName) {
+				reason = "the names " + a.FuncName + " and " + b.FuncName + " suggest a lattice pair"
+			} else {
+				continue
+			}
+			a.Laws = append(a.Laws, Law{Name: lawAbsorption, Reason: reason, Dual: b.FuncName})
+			b.Laws = append(b.Laws, Law{Name: lawAbsorption, Reason: reason, Dual: a.FuncName})
+		}
+	}
+}
+
+func (c *Candidate) addLaw(name, reason string) {
+	c.Laws = append(c.Laws, Law{Name: name, Reason: reason})
+}
+
+func (c *Candidate) addIdentity(id *Identity, reason string) {
+	c.Laws = append(c.Laws, Law{Name: lawIdentity, Reason: reason, Identity: id})
+}
+
+// Law returns the proposed law with the given name, or nil.
+func (c Candidate) Law(name string) *Law {
+	for i := range c.Laws {
+		if c.Laws[i].Name == name {
+			return &c.Laws[i]
+		}
+	}
+	return nil
+}
+
+// bodyOperator returns the operator fn applies to its two operands when its
+// body is a single `return a op b` or `return max(a, b)`.
+func bodyOperator(fn *ast.FuncDecl, info *types.Info) string {
+	if fn.Body == nil || len(fn.Body.List) != 1 {
+		return ""
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return ""
+	}
+	a, b := operandNames(fn)
+	if a == "" || b == "" {
+		return ""
+	}
+
+	switch e := ast.Unparen(ret.Results[0]).(type) {
+	case *ast.BinaryExpr:
+		if isIdent(e.X, a) && isIdent(e.Y, b) {
+			return e.Op.String()
+		}
+	case *ast.CallExpr:
+		id, ok := e.Fun.(*ast.Ident)
+		if !ok || len(e.Args) != 2 || !isIdent(e.Args[0], a) || !isIdent(e.Args[1], b) {
+			return ""
+		}
+		if _, ok := info.Uses[id].(*types.Builtin); ok && (id.Name == "max" || id.Name == "min") {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+// overwrites reports whether fn's body writes map entries, as maps.Copy or
+// result[k] = v do, without accumulating: no arithmetic beyond sizes, no
+// append, no copy. Overwriting or keeping a set of entries is idempotent.
+func overwrites(fn *ast.FuncDecl, info *types.Info) bool {
+	if fn.Body == nil {
+		return false
+	}
+	writes, accumulates := false, false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok != token.ASSIGN && n.Tok != token.DEFINE {
+				accumulates = true
+			}
+			for _, lhs := range n.Lhs {
+				if ix, ok := lhs.(*ast.IndexExpr); ok {
+					if _, ok := info.TypeOf(ix.X).Underlying().(*types.Map); ok {
+						writes = true
+					}
+				}
+			}
+		case *ast.IncDecStmt:
+			accumulates = true
+		case *ast.BinaryExpr:
+			switch n.Op {
+			case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+				accumulates = accumulates || !isSize(n, info)
+			}
+		case *ast.CallExpr:
+			switch fun := typeutil.Callee(info, n).(type) {
+			case *types.Builtin:
+				accumulates = accumulates || fun.Name() == "append" || fun.Name() == "copy"
+			case *types.Func:
+				writes = writes || fun.Pkg() != nil && fun.Pkg().Path() == "maps" && fun.Name() == "Copy"
+			}
+		}
+		return true
+	})
+	return writes && !accumulates
+}
+
+// isSize reports whether e only computes a size, such as
+// len(a)+len(b), from lengths and constants.
+func isSize(e ast.Expr, info *types.Info) bool {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.BinaryExpr:
+		return isSize(e.X, info) && isSize(e.Y, info)
+	case *ast.CallExpr:
+		fun, ok := typeutil.Callee(info, e).(*types.Builtin)
+		return ok && (fun.Name() == "len" || fun.Name() == "cap")
+	}
+	return info.Types[e].Value != nil
+}
+
+// operandNames returns the names of fn's two operands: both parameters of a
+// function, or the receiver and parameter of a method.
+func operandNames(fn *ast.FuncDecl) (string, string) {
+	var names []string
+	if fn.Recv != nil {
+		for _, f := range fn.Recv.List {
+			for _, n := range f.Names {
+				names = append(names, n.Name)
+			}
+		}
+	}
+	for _, f := range fn.Type.Params.List {
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+	}
+	if len(names) != 2 {
+		return "", ""
+	}
+	return names[0], names[1]
+}
+
+// emptyConstructor finds a New* function in scope returning t whose
+// parameters are all maps or slices (or that takes none), so calling it with
+// empty arguments builds an empty value, like NewState(map[string]string{}).
+// Generic constructors such as NewSet[T] are instantiated with typeArgs.
+func emptyConstructor(t types.Type, typeArgs []types.Type, scope *types.Scope) *types.Func {
+	var found *types.Func
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !strings.HasPrefix(name, "New") {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if n := sig.TypeParams().Len(); n > 0 {
+			if n != len(typeArgs) {
+				continue
+			}
+			if sig = instantiate(fn, typeArgs); sig == nil {
+				continue
+			}
+		}
+		if sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), t) {
+			continue
+		}
+		emptyArgs := true
+		for i := range sig.Params().Len() {
+			switch sig.Params().At(i).Type().Underlying().(type) {
+			case *types.Map, *types.Slice:
+			default:
+				emptyArgs = false
+			}
+		}
+		if emptyArgs && (found == nil || name == "New"+typeBaseName(t)) {
+			found = fn
+		}
+	}
+	return found
+}
+
+// hasEmptyValue reports whether t has an obvious empty value: an empty map or
+// slice, or the zero value of a struct or array.
+func hasEmptyValue(t types.Type) bool {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	switch t.Underlying().(type) {
+	case *types.Map, *types.Slice, *types.Struct, *types.Array:
+		return true
+	}
+	return false
+}
+
+func hasBasicInfo(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&info != 0
+}
+
+func typeBaseName(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+func describeOp(op string) string {
+	if op == "max" || op == "min" {
+		return op + "(a, b)"
+	}
+	return "a " + op + " b"
+}
+
+func isDual(a, b string, pairs [][2]string) bool {
+	for _, p := range pairs {
+		if (a == p[0] && b == p[1]) || (a == p[1] && b == p[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDualName(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for _, p := range dualNames {
+		if (strings.Contains(a, p[0]) && strings.Contains(b, p[1])) ||
+			(strings.Contains(a, p[1]) && strings.Contains(b, p[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func hasAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func mentions(comments string, words ...string) bool {
+	return hasAny(comments, words)
+}
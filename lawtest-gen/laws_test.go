@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInferLaws(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./examples", "./testdata/lattice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	laws := make(map[string][]string)
+	suggested := make(map[string][]string)
+	for _, pkg := range pkgs {
+		for _, c := range pkg.Candidates {
+			for _, law := range c.Laws {
+				laws[displayName(c)] = append(laws[displayName(c)], law.Name)
+			}
+			for _, law := range c.Suggested {
+				suggested[displayName(c)] = append(suggested[displayName(c)], law.Name)
+			}
+		}
+	}
+
+	want := map[string]string{
+		"Add":            "immutable associative commutative identity",
+		"Concat":         "immutable associative identity",
+		"Max":            "immutable associative commutative idempotent absorption",
+		"Min":            "immutable associative commutative idempotent absorption",
+		"Sub":            "immutable",
+		"(Scores).Merge": "immutable associative idempotent identity",
+		"MergeMap":       "immutable associative idempotent identity",
+		"(State).Merge":  "immutable associative identity",
+		"(Items).Merge":  "immutable associative identity",
+	}
+	for name, w := range want {
+		if got := strings.Join(laws[name], " "); got != w {
+			t.Errorf("%s: expected laws %q, got %q", name, w, got)
+		}
+	}
+
+	// Merges that sum or concatenate are only suggested idempotence
+	wantSuggested := map[string]string{
+		"(State).Merge": "idempotent",
+		"(Items).Merge": "idempotent",
+	}
+	for name := range laws {
+		if got := strings.Join(suggested[name], " "); got != wantSuggested[name] {
+			t.Errorf("%s: expected suggested laws %q, got %q", name, wantSuggested[name], got)
+		}
+	}
+}
+
+func TestInferIdentityConstructor(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/lattice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range pkgs[0].Candidates {
+		if c.FuncName != "Merge" {
+			continue
+		}
+		law := c.Law(lawIdentity)
+		if law == nil || law.Identity.Constructor == nil || law.Identity.Constructor.Name() != "NewScores" {
+			t.Fatalf("Expected NewScores as the identity constructor, got %+v", law)
+		}
+		return
+	}
+	t.Fatal("Merge not found")
+}
+
+func TestInferFoldLaws(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/fold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Merge":           "immutable associative idempotent identity",
+		"MergeAll":        "immutable associative flatten pairwise",
+		"Sum":             "immutable associative flatten",
+		"(Counter).Apply": "composition",
+	}
+	got := make(map[string]string)
+	for _, c := range pkgs[0].Candidates {
+		var names []string
+		for _, law := range c.Laws {
+			names = append(names, law.Name)
+		}
+		got[displayName(c)] = strings.Join(names, " ")
+		if law := c.Law(lawPairwise); law != nil && law.Pairwise != "Merge" {
+			t.Errorf("%s: expected to be compared with Merge, got %s", displayName(c), law.Pairwise)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected candidates %v, got %v", want, got)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: expected laws %q, got %q", name, w, got[name])
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
 	"go/token"
+	"go/types"
+	"maps"
 	"os"
 	"strings"
 )
@@ -15,269 +16,315 @@ type Candidate struct {
 	IsComparable bool
 	NeedsWrapper bool
 	Receiver     string
+
+	Type types.Type     // operand type as seen by the type checker
+	Pos  token.Position // location of the declaration
+	Laws []Law          // laws proposed for the operation, with reasons
+
+	// Suggested are laws only the name hints at, mentioned in the
+	// generated file rather than tested.
+	Suggested []Law
+
+	// TypeArgs instantiate a generic function or receiver type; Type and
+	// comparability describe that instantiation.
+	TypeArgs            []types.Type
+	ComparabilityVaries bool // other instantiations may differ in comparability
+
+	Fold       *Fold       // set for variadic folds such as MergeAll(cs ...Config) Config
+	Transition *Transition // set for updates such as Set(key, value string) *State
+
+	TakesContext bool // the first parameter is a context.Context
+	ReturnsError bool // a second result reports failure
+
+	Annotated bool // Laws were declared with //lawtest:laws rather than inferred
+
+	operator   string // operator applied by a `return a op b` body, if any
+	precedence string // operand winning conflicts, see precedence
+}
+
+// binary reports whether c is a binary operation, not a fold or transition.
+func (c Candidate) binary() bool {
+	return c.Fold == nil && c.Transition == nil
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: lawtest-gen <file.go>")
-		fmt.Println()
-		fmt.Println("Analyzes Go source file and generates lawtest skeleton")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing any file")
+	showDiff := flag.Bool("diff", false, "print a unified diff of each test file instead of writing it")
+	overwrite := flag.Bool("overwrite", false, "regenerate test files from scratch, discarding edits")
+	check := flag.Bool("check", false, "report candidates without lawtest coverage and exit 1 if there are any")
+	report := flag.String("report", "json", "report format for -check: json or sarif")
+	format := flag.String("format", "text", "candidate listing: text, or json or markdown to print it without generating tests")
+	quiet := flag.Bool("q", false, "only report the test files written, as suits go:generate")
+	unexported := flag.Bool("unexported", false, "also analyze unexported functions and methods of unexported types")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lawtest-gen [flags] <packages | file.go>")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Analyzes Go packages and generates lawtest skeletons, e.g.:")
+		fmt.Fprintln(os.Stderr, "  lawtest-gen ./...")
+		fmt.Fprintln(os.Stderr, "  lawtest-gen -diff config.go")
+		fmt.Fprintln(os.Stderr, "  lawtest-gen -check -report=sarif ./... > lawtest.sarif")
+		fmt.Fprintln(os.Stderr, "  lawtest-gen -format=json ./... > candidates.json")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Under go generate, with no arguments, the file holding the directive is used:")
+		fmt.Fprintln(os.Stderr, "  //go:generate lawtest-gen -q")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Annotate operations to state their laws instead of having them guessed:")
+		fmt.Fprintln(os.Stderr, "  //lawtest:laws associative,commutative identity=Empty")
+		fmt.Fprintln(os.Stderr, "  //lawtest:ignore")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Existing _law_test.go files are updated, not replaced: tests you edited or")
+		fmt.Fprintln(os.Stderr, "deleted stay that way and only new candidates get tests appended.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if file := os.Getenv("GOFILE"); len(args) == 0 && file != "" {
+		// go generate runs in the package directory and names the file
+		args = []string{file}
+	}
+	if len(args) == 0 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	filename := os.Args[1]
-	candidates, pkgName, err := analyzeFile(filename)
+	pkgs, err := loadPackages(args, loadOptions{unexported: *unexported})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	if len(candidates) == 0 {
-		fmt.Println("No lawtest candidates found.")
-		fmt.Println()
-		fmt.Println("lawtest works with:")
-		fmt.Println("  • func(T, T) T                - Binary functions")
-		fmt.Println("  • func (T) Method(T) T        - Methods on types")
-		fmt.Println("  • func ([]T) Method([]T) []T  - Methods on slices")
-		os.Exit(0)
+	if *check {
+		result, err := checkCoverage(pkgs, args)
+		if err == nil {
+			err = writeCheckReport(os.Stdout, result, *report)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if len(result.Uncovered) > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %d candidates have no lawtest coverage\n", len(result.Uncovered), result.Candidates)
+			os.Exit(1)
+		}
+		return
 	}
 
-	fmt.Println()
-	fmt.Println("═══════════════════════════════════════════════════════════")
-	fmt.Println("  lawtest Candidates Found")
-	fmt.Println("═══════════════════════════════════════════════════════════")
-	fmt.Println()
-
-	for i, c := range candidates {
-		fmt.Printf("%d. %s\n", i+1, c.FuncName)
-		fmt.Printf("   Type: %s\n", c.TypeName)
-		if c.NeedsWrapper {
-			fmt.Printf("   ⚠️  Type is NOT comparable - needs wrapper (see example)\n")
-		} else if c.IsComparable {
-			fmt.Printf("   ✅ Type is comparable\n")
-		} else {
-			fmt.Printf("   ❓ Comparability unknown - may need wrapper\n")
+	if *format != "text" {
+		// Reports for tools contain the candidates only
+		if err := writeCandidates(os.Stdout, pkgs, *format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		fmt.Println()
+		return
 	}
-
-	// Generate test file
-	testFilename := strings.TrimSuffix(filename, ".go") + "_law_test.go"
-	content := generateTestFile(pkgName, candidates)
-
-	err = os.WriteFile(testFilename, []byte(content), 0o644)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing test file: %v\n", err)
-		os.Exit(1)
+	if !*quiet {
+		writeCandidatesText(os.Stdout, pkgs)
+	}
+	if len(pkgs) == 0 {
+		return
 	}
 
-	fmt.Printf("Generated: %s\n", testFilename)
-	fmt.Println()
-	fmt.Println("Next steps:")
-	fmt.Println("  1. Review generated tests")
-	fmt.Println("  2. Complete TODO items")
-	fmt.Println("  3. Verify operations should have tested properties")
-	fmt.Println("  4. Run: go test -v")
-	fmt.Println()
-}
-
-func analyzeFile(filename string) ([]Candidate, string, error) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	// Generate one test file per source file that declares candidates or
+	// implementations of contracts, merging into the test file when it
+	// already exists. The conformance suites those call are regenerated
+	// wholesale: they are not meant to be edited. Either way, what the other
+	// test files of the package declare or test is left out.
+	testFiles, err := loadTestFiles(args, false)
 	if err != nil {
-		return nil, "", err
+		fmt.Fprintf(os.Stderr, "Error loading tests: %v\n", err)
+		os.Exit(1)
 	}
-
-	var candidates []Candidate
-	pkgName := node.Name.Name
-
-	ast.Inspect(node, func(n ast.Node) bool {
-		switch x := n.(type) {
-		case *ast.FuncDecl:
-			if c := analyzeFunc(x); c != nil {
-				candidates = append(candidates, *c)
+	for _, pkg := range pkgs {
+		g := newGenerator(pkg)
+		for _, c := range pkg.Contracts {
+			filename := conformanceFile(pkg.Dir, c)
+			content, err := g.generateConformanceFile(pkg.Name, c)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			existing, err := os.ReadFile(filename)
+			switch {
+			case err == nil && string(existing) == content:
+				fmt.Printf("Up to date: %s\n", filename)
+			case err == nil:
+				fmt.Printf("Regenerated: %s\n", filename)
+			case os.IsNotExist(err):
+				fmt.Printf("Generated: %s\n", filename)
+			default:
+				fmt.Fprintf(os.Stderr, "Error reading conformance file: %v\n", err)
+				os.Exit(1)
+			}
+			if *showDiff {
+				fmt.Print(unifiedDiff(filename, filename, string(existing), content))
+				continue
+			}
+			if *dryRun || content == string(existing) {
+				continue
+			}
+			if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing conformance file: %v\n", err)
+				os.Exit(1)
 			}
 		}
-		return true
-	})
-
-	return candidates, pkgName, nil
-}
-
-func analyzeFunc(fn *ast.FuncDecl) *Candidate {
-	if fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
-		return nil
-	}
-
-	// Check for func(T, T) T pattern
-	if fn.Recv == nil && len(fn.Type.Params.List) == 2 {
-		param1Type := exprToString(fn.Type.Params.List[0].Type)
-		param2Type := exprToString(fn.Type.Params.List[1].Type)
-		returnType := exprToString(fn.Type.Results.List[0].Type)
 
-		if param1Type == param2Type && param1Type == returnType {
-			return &Candidate{
-				FuncName:     fn.Name.Name,
-				TypeName:     param1Type,
-				IsComparable: isLikelyComparable(param1Type),
-				NeedsWrapper: isNonComparable(param1Type),
+		// Each file is generated with every helper it needs; merging leaves
+		// out those the files written before it declare
+		written := make(map[string]bool)
+		for _, filename := range sourceFiles(pkg) {
+			testFilename := strings.TrimSuffix(filename, ".go") + "_law_test.go"
+			candidates := candidatesIn(pkg.Candidates, filename)
+			clear(g.declared)
+			content, err := g.generateTestFile(pkg.Name, candidates, contractsIn(pkg.Contracts, filename))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
 			}
-		}
-	}
 
-	// Check for method(T) T pattern (with receiver)
-	// Handles both: (T) Method(T) T and ([]T) Method([]T) []T
-	if fn.Recv != nil && len(fn.Type.Params.List) == 1 {
-		receiverType := exprToString(fn.Recv.List[0].Type)
-		paramType := exprToString(fn.Type.Params.List[0].Type)
-		returnType := exprToString(fn.Type.Results.List[0].Type)
+			existing, err := os.ReadFile(testFilename)
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error reading test file: %v\n", err)
+				os.Exit(1)
+			}
+			merge := string(existing)
+			if *overwrite {
+				merge = ""
+			}
+			other := others(testFiles, testFilename, candidates)
+			maps.Copy(other.decls, written)
+			result, err := mergeTestFile(merge, content, g.tests, other)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error merging %s: %v\n", testFilename, err)
+				os.Exit(1)
+			}
+			content = result.Content
+			switch {
+			case merge != "":
+				reportMerge(testFilename, result)
+			case len(result.Added) == 0:
+				fmt.Printf("Skipped: %s, other test files cover its candidates\n", testFilename)
+				continue
+			case existing != nil:
+				fmt.Printf("Regenerated: %s\n", testFilename)
+			default:
+				fmt.Printf("Generated: %s\n", testFilename)
+			}
+			if merge == "" && len(result.Kept) > 0 {
+				fmt.Printf("   Tested in other files: %s\n", strings.Join(result.Kept, ", "))
+			}
+			maps.Copy(written, result.Decls)
 
-		if receiverType == paramType && receiverType == returnType {
-			return &Candidate{
-				FuncName:     fn.Name.Name,
-				TypeName:     receiverType,
-				IsComparable: isLikelyComparable(receiverType),
-				NeedsWrapper: isNonComparable(receiverType),
-				Receiver:     receiverType,
+			if *showDiff {
+				fmt.Print(unifiedDiff(testFilename, testFilename, string(existing), content))
+				continue
+			}
+			if *dryRun || content == string(existing) {
+				continue
+			}
+			err = os.WriteFile(testFilename, []byte(content), 0o644)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing test file: %v\n", err)
+				os.Exit(1)
 			}
 		}
 	}
+	if *dryRun || *showDiff {
+		fmt.Println()
+		fmt.Println("No files were written.")
+		return
+	}
 
-	return nil
-}
-
-func exprToString(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		return t.Name
-	case *ast.StarExpr:
-		return "*" + exprToString(t.X)
-	case *ast.ArrayType:
-		return "[]" + exprToString(t.Elt)
-	case *ast.MapType:
-		return "map[" + exprToString(t.Key) + "]" + exprToString(t.Value)
-	case *ast.SelectorExpr:
-		return exprToString(t.X) + "." + t.Sel.Name
-	default:
-		return "unknown"
+	if *quiet {
+		return
 	}
+	fmt.Println()
+	fmt.Println("Next steps:")
+	fmt.Println("  1. Review generated tests")
+	fmt.Println("  2. Adjust generators to produce realistic values")
+	fmt.Println("  3. Verify operations should have tested properties")
+	fmt.Println("  4. Run: go test -v")
+	fmt.Println()
 }
 
-func isLikelyComparable(typeName string) bool {
-	// Basic comparable types
-	comparable := []string{
-		"int", "int8", "int16", "int32", "int64",
-		"uint", "uint8", "uint16", "uint32", "uint64",
-		"float32", "float64", "string", "bool", "byte", "rune",
+// reportMerge prints what merging into an existing test file changed.
+func reportMerge(testFilename string, result mergeResult) {
+	if len(result.Added) == 0 {
+		fmt.Printf("Up to date: %s\n", testFilename)
+	} else {
+		fmt.Printf("Updated: %s\n", testFilename)
 	}
-
-	for _, c := range comparable {
-		if typeName == c {
-			return true
-		}
+	for _, name := range result.Added {
+		fmt.Printf("   + %s\n", name)
 	}
-
-	// Pointers are comparable
-	if strings.HasPrefix(typeName, "*") {
-		return true
+	if len(result.Kept) > 0 {
+		fmt.Printf("   Kept existing tests for: %s\n", strings.Join(result.Kept, ", "))
+	}
+	for _, name := range result.Stale {
+		fmt.Printf("   ⚠️  %s: candidate no longer found, delete the test if the operation is gone\n", name)
 	}
-
-	return false
 }
 
-func isNonComparable(typeName string) bool {
-	// Known non-comparable types
-	if strings.HasPrefix(typeName, "[]") {
-		return true // slices
+// displayName returns the function name, qualified by its receiver for methods.
+func displayName(c Candidate) string {
+	if c.Receiver == "" {
+		return c.FuncName
 	}
-	if strings.HasPrefix(typeName, "map[") {
-		return true // maps
+	return "(" + c.Receiver + ")." + c.FuncName
+}
+
+// instanceName returns the instantiated operation, such as Merge[int, string]
+// or Set[int].Union.
+func instanceName(c Candidate) string {
+	if c.Receiver != "" {
+		return c.Receiver + "." + c.FuncName
 	}
-	if strings.HasPrefix(typeName, "func(") {
-		return true // functions
+	args := make([]string, len(c.TypeArgs))
+	for i, t := range c.TypeArgs {
+		args[i] = t.String()
 	}
-	return false
+	return c.FuncName + "[" + strings.Join(args, ", ") + "]"
 }
 
-func generateTestFile(pkgName string, candidates []Candidate) string {
-	var sb strings.Builder
-
-	fmt.Fprintf(&sb, "package %s\n\n", pkgName)
-	sb.WriteString("import (\n")
-	sb.WriteString("\t\"testing\"\n\n")
-	sb.WriteString("\t\"github.com/alexshd/lawtest\"\n")
-	sb.WriteString(")\n\n")
-
-	sb.WriteString("// This file was auto-generated by lawtest-gen\n")
-	sb.WriteString("// Review and complete the TODO items before running tests\n\n")
-
+// candidateFiles returns the distinct source files of candidates in order.
+func candidateFiles(candidates []Candidate) []string {
+	var files []string
+	seen := make(map[string]bool)
 	for _, c := range candidates {
-		if c.NeedsWrapper {
-			generateWrapperTests(&sb, c)
-		} else {
-			generateDirectTests(&sb, c)
+		if !seen[c.Pos.Filename] {
+			seen[c.Pos.Filename] = true
+			files = append(files, c.Pos.Filename)
 		}
 	}
-
-	return sb.String()
+	return files
 }
 
-func generateDirectTests(sb *strings.Builder, c Candidate) {
-	funcToTest := c.FuncName
-	if c.Receiver != "" {
-		// For methods, we need to show pattern
-		fmt.Fprintf(sb, "// TODO: Create wrapper function for method %s\n", c.FuncName)
-		fmt.Fprintf(sb, "// func Wrap%s(a, b %s) %s {\n", c.FuncName, c.Receiver, c.Receiver)
-		fmt.Fprintf(sb, "//     return a.%s(b)\n", c.FuncName)
-		sb.WriteString("// }\n\n")
-		funcToTest = "Wrap" + c.FuncName
+// sourceFiles returns the files of pkg that declare candidates or
+// implementations of its contracts, in order.
+func sourceFiles(pkg Package) []string {
+	files := candidateFiles(pkg.Candidates)
+	seen := make(map[string]bool)
+	for _, f := range files {
+		seen[f] = true
 	}
-
-	// Immutability test
-	fmt.Fprintf(sb, "func Test%sImmutability(t *testing.T) {\n", c.FuncName)
-	fmt.Fprintf(sb, "\t// TODO: Create a generator for %s\n", c.TypeName)
-	sb.WriteString("\tgen := func() " + c.TypeName + " {\n")
-	sb.WriteString("\t\t// TODO: Return a valid instance of " + c.TypeName + "\n")
-	sb.WriteString("\t\tpanic(\"implement generator\")\n")
-	sb.WriteString("\t}\n\n")
-	fmt.Fprintf(sb, "\tlawtest.ImmutableOp(t, %s, gen)\n", funcToTest)
-	sb.WriteString("}\n\n")
-
-	// Associativity test
-	fmt.Fprintf(sb, "func Test%sAssociativity(t *testing.T) {\n", c.FuncName)
-	fmt.Fprintf(sb, "\t// TODO: Verify that %s SHOULD be associative\n", c.FuncName)
-	sb.WriteString("\t// Question: Does (a op b) op c = a op (b op c) for your operation?\n")
-	sb.WriteString("\t// If NO, remove this test - not all operations are associative\n\n")
-	sb.WriteString("\tgen := func() " + c.TypeName + " {\n")
-	sb.WriteString("\t\t// TODO: Return a valid instance of " + c.TypeName + "\n")
-	sb.WriteString("\t\tpanic(\"implement generator\")\n")
-	sb.WriteString("\t}\n\n")
-	fmt.Fprintf(sb, "\tlawtest.Associative(t, %s, gen)\n", funcToTest)
-	sb.WriteString("}\n\n")
+	for _, c := range pkg.Contracts {
+		for _, impl := range c.Impls {
+			if !seen[impl.Pos.Filename] {
+				seen[impl.Pos.Filename] = true
+				files = append(files, impl.Pos.Filename)
+			}
+		}
+	}
+	return files
 }
 
-func generateWrapperTests(sb *strings.Builder, c Candidate) {
-	wrapperName := c.TypeName + "Wrapper"
-	fmt.Fprintf(sb, "// %s is NOT comparable - needs wrapper for lawtest\n", c.TypeName)
-	sb.WriteString("// See config-merge-example for pattern\n\n")
-
-	sb.WriteString("// TODO: Create wrapper type in your source file:\n")
-	fmt.Fprintf(sb, "// type %s struct {\n", wrapperName)
-	fmt.Fprintf(sb, "//     data %s\n", c.TypeName)
-	sb.WriteString("// }\n\n")
-
-	sb.WriteString("// TODO: Create wrapper function:\n")
-	fmt.Fprintf(sb, "// func Wrap%s(a, b *%s) *%s {\n", c.FuncName, wrapperName, wrapperName)
-	fmt.Fprintf(sb, "//     return &%s{data: %s(a.data, b.data)}\n", wrapperName, c.FuncName)
-	sb.WriteString("// }\n\n")
-
-	fmt.Fprintf(sb, "func Test%sImmutability(t *testing.T) {\n", c.FuncName)
-	sb.WriteString("\t// TODO: Implement after creating wrapper type\n")
-	sb.WriteString("\tt.Skip(\"TODO: Create wrapper type first - see comments above\")\n")
-	sb.WriteString("}\n\n")
-
-	fmt.Fprintf(sb, "func Test%sAssociativity(t *testing.T) {\n", c.FuncName)
-	sb.WriteString("\t// TODO: Implement after creating wrapper type\n")
-	sb.WriteString("\tt.Skip(\"TODO: Create wrapper type first - see comments above\")\n")
-	sb.WriteString("}\n\n")
+// candidatesIn returns the candidates declared in filename.
+func candidatesIn(candidates []Candidate, filename string) []Candidate {
+	var result []Candidate
+	for _, c := range candidates {
+		if c.Pos.Filename == filename {
+			result = append(result, c)
+		}
+	}
+	return result
 }
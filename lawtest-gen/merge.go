@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lawSuffixes are the suffixes of generated test names, used to recognize
+// tests whose candidate no longer exists.
+var lawSuffixes = []string{"Immutability", "Associativity", "Commutativity", "Idempotence", "Identity", "Absorption",
+	"Flattening", "Pairwise", "Composition", "ErrorStability", "Laws", "Conformance", "Determinism", "Commutation"}
+
+// mergeResult describes how an existing _law_test.go was updated.
+type mergeResult struct {
+	Content string
+	Added   []string        // test functions appended for new candidates
+	Kept    []string        // candidates whose tests were left as they are
+	Stale   []string        // test functions whose candidate has disappeared
+	Decls   map[string]bool // declNames of the resulting file
+}
+
+// otherTests is what the other test files of a package hold: their
+// declarations, by declName, and the testNames of the candidates their
+// lawtest tests cover.
+type otherTests struct {
+	decls   map[string]bool
+	covered map[string]bool
+}
+
+// mergeTestFile updates existing with the tests in generated without
+// touching anything already there. Candidates that already have at least one
+// test in existing or in others are left alone, so deleted or edited tests
+// stay that way; candidates with no test get all their generated tests
+// appended, together with the helpers those tests use that neither existing
+// nor others declare yet. An empty existing stands for a new file: generated
+// is then kept but for what others already declare or cover.
+//
+// tests maps each generated test function to the testName of its candidate.
+func mergeTestFile(existing, generated string, tests map[string]string, others otherTests) (mergeResult, error) {
+	var result mergeResult
+
+	fset := token.NewFileSet()
+	oldFile := &ast.File{}
+	if existing != "" {
+		var err error
+		if oldFile, err = parser.ParseFile(fset, "existing", existing, parser.ParseComments); err != nil {
+			return result, fmt.Errorf("parsing existing tests: %w", err)
+		}
+	}
+	newFile, err := parser.ParseFile(fset, "generated", generated, parser.ParseComments)
+	if err != nil {
+		return result, fmt.Errorf("parsing generated tests: %w", err)
+	}
+
+	existingDecls := declNames(oldFile)
+	declared := maps.Clone(existingDecls)
+	maps.Copy(declared, others.decls)
+	covered := make(map[string]bool)
+	for name, candidate := range tests {
+		if declared[name] || others.covered[candidate] {
+			covered[candidate] = true
+		}
+	}
+
+	// Pick the generated tests of uncovered candidates, then every helper
+	// they (transitively) need that is not declared yet, with its methods.
+	newDecls := make(map[string]ast.Decl)
+	methods := make(map[string][]string)
+	for _, decl := range newFile.Decls {
+		if name := declName(decl); name != "" {
+			newDecls[name] = decl
+			if recv, _, ok := strings.Cut(name, "."); ok {
+				methods[recv] = append(methods[recv], name)
+			}
+		}
+	}
+	add := make(map[string]bool)
+	var queue []string
+	for name, candidate := range tests {
+		if !covered[candidate] && !declared[name] {
+			add[name] = true
+			result.Added = append(result.Added, name)
+			queue = append(queue, name)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		need := func(name string) {
+			if !add[name] && !declared[name] && newDecls[name] != nil {
+				add[name] = true
+				queue = append(queue, name)
+			}
+		}
+		for _, method := range methods[name] {
+			need(method)
+		}
+		ast.Inspect(newDecls[name], func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				need(id.Name)
+			}
+			return true
+		})
+	}
+
+	switch {
+	case existing == "":
+		if result.Content, err = keepDecls(generated, fset, newFile, add); err != nil {
+			return result, err
+		}
+	case len(result.Added) > 0:
+		if result.Content, err = appendDecls(existing, generated, fset, newFile, add); err != nil {
+			return result, err
+		}
+	default:
+		result.Content = existing
+	}
+
+	result.Decls = maps.Clone(existingDecls)
+	maps.Copy(result.Decls, add)
+
+	current := make(map[string]bool)
+	for _, candidate := range tests {
+		current[candidate] = true
+	}
+	for name := range existingDecls {
+		if tests[name] == "" && isStale(name, current) {
+			result.Stale = append(result.Stale, name)
+		}
+	}
+	for candidate := range covered {
+		result.Kept = append(result.Kept, candidate)
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Kept)
+	sort.Strings(result.Stale)
+	return result, nil
+}
+
+// keepDecls returns generated without the named declarations not in keep,
+// and without the imports only those used.
+func keepDecls(generated string, fset *token.FileSet, newFile *ast.File, keep map[string]bool) (string, error) {
+	var sb strings.Builder
+	offset := 0
+	for _, decl := range newFile.Decls {
+		if name := declName(decl); name == "" || keep[name] {
+			continue
+		}
+		start := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			start = doc.Pos()
+		}
+		sb.WriteString(generated[offset:fset.Position(start).Offset])
+		offset = fset.Position(decl.End()).Offset
+	}
+	sb.WriteString(generated[offset:])
+	return formatSource(sb.String(), nil, true)
+}
+
+// appendDecls appends the declarations of generated named in add to existing,
+// in generated order with their doc comments, and the imports they need.
+func appendDecls(existing, generated string, fset *token.FileSet, newFile *ast.File, add map[string]bool) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(existing)
+	for _, decl := range newFile.Decls {
+		if !add[declName(decl)] {
+			continue
+		}
+		start := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			start = doc.Pos()
+		}
+		sb.WriteString("\n")
+		sb.WriteString(generated[fset.Position(start).Offset:fset.Position(decl.End()).Offset])
+		sb.WriteString("\n")
+	}
+	return addImports(sb.String(), newFile)
+}
+
+// addImports adds the imports of generated that src is missing, keeping
+// only those the appended code uses.
+func addImports(src string, generated *ast.File) (string, error) {
+	var add []string
+	for _, imp := range generated.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		add = append(add, path)
+	}
+	return formatSource(src, add, false)
+}
+
+// isStale reports whether name looks like a generated test (Test<candidate><law>)
+// or fuzz target (Fuzz<candidate>Laws) for a candidate that is not among
+// current. Absorption tests are named after both operations, as in
+// TestMaxMinAbsorption, so they only need to start with a current candidate.
+func isStale(name string, current map[string]bool) bool {
+	var prefix string
+	switch {
+	case strings.HasPrefix(name, "Test"):
+		prefix = "Test"
+	case strings.HasPrefix(name, "Fuzz") && strings.HasSuffix(name, "Laws"):
+		prefix = "Fuzz"
+	default:
+		return false
+	}
+	for _, suffix := range lawSuffixes {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		candidate := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		if suffix != "Absorption" {
+			return !current[candidate]
+		}
+		for c := range current {
+			if strings.HasPrefix(candidate, c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func declNames(file *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if name := declName(decl); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// declName returns the name declared by a top-level function or single type
+// declaration, Recv.Name for methods; imports and grouped declarations
+// return "".
+func declName(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil || len(d.Recv.List) == 0 {
+			return d.Name.Name
+		}
+		return recvName(d.Recv.List[0].Type) + "." + d.Name.Name
+	case *ast.GenDecl:
+		if d.Tok == token.TYPE && len(d.Specs) == 1 {
+			return d.Specs[0].(*ast.TypeSpec).Name.Name
+		}
+	}
+	return ""
+}
+
+// recvName returns the name of the type of a receiver, without pointer and
+// type parameters.
+func recvName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return recvName(e.X)
+	case *ast.IndexExpr:
+		return recvName(e.X)
+	case *ast.IndexListExpr:
+		return recvName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const existingLawTests = `package examples
+
+import (
+	"testing"
+
+	"github.com/alexshd/lawtest"
+)
+
+func TestAddImmutability(t *testing.T) {
+	// edited by hand
+	lawtest.ImmutableOp(t, Add, lawtest.IntGen(0, 10))
+}
+
+func TestRemovedAssociativity(t *testing.T) {
+	lawtest.Associative(t, Removed, lawtest.IntGen(0, 10))
+}
+`
+
+func TestMergeTestFile(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"examples/example.go", "examples/slice_example.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator(pkgs[0])
+	generated, err := g.generateTestFile(pkgs[0].Name, pkgs[0].Candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mergeTestFile(existingLawTests, generated, g.tests, otherTests{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(result.Content, "// edited by hand\n\tlawtest.ImmutableOp(t, Add, lawtest.IntGen(0, 10))") {
+		t.Errorf("Expected edited test to be kept\n%s", result.Content)
+	}
+	if strings.Contains(result.Content, "TestAddAssociativity") {
+		t.Errorf("Expected no new tests for Add, which already has one\n%s", result.Content)
+	}
+	for _, want := range []string{
+		"func TestItemsMergeAssociativity(t *testing.T)",
+		"type ItemsWrapper struct",
+		"func genItems() *ItemsWrapper",
+	} {
+		if !strings.Contains(result.Content, want) {
+			t.Errorf("Merged tests missing %q\n%s", want, result.Content)
+		}
+	}
+	if strings.Count(result.Content, "func TestAddImmutability") != 1 {
+		t.Errorf("Expected TestAddImmutability once\n%s", result.Content)
+	}
+
+	if len(result.Kept) != 1 || result.Kept[0] != "Add" {
+		t.Errorf("Expected kept [Add], got %v", result.Kept)
+	}
+	if len(result.Stale) != 1 || result.Stale[0] != "TestRemovedAssociativity" {
+		t.Errorf("Expected stale [TestRemovedAssociativity], got %v", result.Stale)
+	}
+}
+
+func TestMergeTestFileUpToDate(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"examples/slice_example.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newGenerator(pkgs[0])
+	generated, err := g.generateTestFile(pkgs[0].Name, pkgs[0].Candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mergeTestFile(generated, generated, g.tests, otherTests{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != generated || len(result.Added) != 0 || len(result.Stale) != 0 {
+		t.Errorf("Expected unchanged file, got added=%v stale=%v", result.Added, result.Stale)
+	}
+}
+
+func TestMergeTestFileOtherTests(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/coverage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := loadTestFiles([]string{"./testdata/coverage"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := pkgs[0]
+	g := newGenerator(pkg)
+	generated, err := g.generateTestFile(pkg.Name, pkg.Candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ops_test.go declares SetWrapper, WrapUnion and genSet, and tests Add
+	// and Union
+	testFilename := strings.TrimSuffix(pkg.Candidates[0].Pos.Filename, ".go") + "_law_test.go"
+	result, err := mergeTestFile("", generated, g.tests, others(files, testFilename, pkg.Candidates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"SetWrapper", "WrapUnion", "genSet", "TestAddAssociativity", "TestUnionImmutability"} {
+		if strings.Contains(result.Content, " "+name+"(") || strings.Contains(result.Content, "type "+name+" ") {
+			t.Errorf("Expected %s, declared in ops_test.go, not to be redeclared\n%s", name, result.Content)
+		}
+	}
+	if !strings.Contains(result.Content, "func TestSubImmutability(t *testing.T)") {
+		t.Errorf("Expected tests for Sub, which ops_test.go doesn't cover\n%s", result.Content)
+	}
+	if !slices.Equal(result.Kept, []string{"Add", "Union"}) {
+		t.Errorf("Expected kept [Add Union], got %v", result.Kept)
+	}
+	if !result.Decls["TestSubImmutability"] || !result.Decls["genInt"] || result.Decls["genSet"] {
+		t.Errorf("Expected the declarations of the merged file, got %v", result.Decls)
+	}
+	typeCheck(t, pkg.Dir, map[string][]byte{testFilename: []byte(result.Content)})
+}
+
+func TestDeclNames(t *testing.T) {
+	src := `package p
+
+type Set[T comparable] map[T]bool
+
+func (s Set[T]) Equal(o Set[T]) bool { return len(s) == len(o) }
+
+type SetWrapper struct{ set Set[int] }
+
+func (w *SetWrapper) String() string { return "" }
+
+func genSet() *SetWrapper { return nil }
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Sorted(maps.Keys(declNames(file)))
+	want := []string{"Set", "Set.Equal", "SetWrapper", "SetWrapper.String", "genSet"}
+	if !slices.Equal(got, want) {
+		t.Errorf("declNames() = %v, want %v", got, want)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	new := "a\nb\nc\nD\ne\nf\ng\nh\ni\n"
+
+	want := `--- old
++++ new
+@@ -1,8 +1,9 @@
+ a
+ b
+ c
+-d
++D
+ e
+ f
+ g
+ h
++i
+`
+	if got := unifiedDiff("old", "new", old, new); got != want {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", want, got)
+	}
+	if got := unifiedDiff("old", "new", old, old); got != "" {
+		t.Errorf("Expected no diff for equal input, got:\n%s", got)
+	}
+}
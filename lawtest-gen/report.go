@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"strings"
+)
+
+// CandidateReport is the list of candidates printed by -format=json.
+type CandidateReport struct {
+	Candidates []ReportedCandidate `json:"candidates"`
+}
+
+// ReportedCandidate describes one candidate for dashboards and bots.
+type ReportedCandidate struct {
+	Package      string        `json:"package"`
+	Function     string        `json:"function"`
+	Receiver     string        `json:"receiver,omitempty"`
+	Type         string        `json:"type"`
+	TypeArgs     []string      `json:"typeArgs,omitempty"`
+	Comparable   bool          `json:"comparable"`
+	NeedsWrapper bool          `json:"needsWrapper"`
+	Fold         string        `json:"fold,omitempty"` // "combine" or "action"
+	Transition   bool          `json:"transition,omitempty"`
+	TakesContext bool          `json:"takesContext,omitempty"`
+	ReturnsError bool          `json:"returnsError,omitempty"`
+	File         string        `json:"file"`
+	Line         int           `json:"line"`
+	Laws         []ReportedLaw `json:"laws"`
+	Suggested    []ReportedLaw `json:"suggested,omitempty"` // hinted at, not tested
+
+	pkgName string // qualifies the function in Markdown
+}
+
+// ReportedLaw is a proposed law and the reason it was proposed.
+type ReportedLaw struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// candidateReport flattens the candidates of pkgs.
+func candidateReport(pkgs []Package) CandidateReport {
+	report := CandidateReport{Candidates: []ReportedCandidate{}}
+	for _, pkg := range pkgs {
+		for _, c := range pkg.Candidates {
+			r := ReportedCandidate{
+				Package:      pkg.Path,
+				Function:     c.FuncName,
+				Receiver:     c.Receiver,
+				Type:         c.TypeName,
+				Comparable:   c.IsComparable,
+				NeedsWrapper: c.NeedsWrapper,
+				Transition:   c.Transition != nil,
+				TakesContext: c.TakesContext,
+				ReturnsError: c.ReturnsError,
+				File:         relPath(c.Pos.Filename),
+				Line:         c.Pos.Line,
+				Laws:         []ReportedLaw{},
+
+				pkgName: pkg.Name,
+			}
+			for _, t := range c.TypeArgs {
+				r.TypeArgs = append(r.TypeArgs, t.String())
+			}
+			switch {
+			case c.Fold != nil && c.Fold.Action:
+				r.Fold = "action"
+			case c.Fold != nil:
+				r.Fold = "combine"
+			}
+			for _, law := range c.Laws {
+				r.Laws = append(r.Laws, ReportedLaw{Name: law.Name, Reason: law.Reason})
+			}
+			for _, law := range c.Suggested {
+				r.Suggested = append(r.Suggested, ReportedLaw{Name: law.Name, Reason: law.Reason})
+			}
+			report.Candidates = append(report.Candidates, r)
+		}
+	}
+	return report
+}
+
+// writeCandidates lists the candidates of pkgs as text for people, or as
+// JSON or Markdown for tools.
+func writeCandidates(w io.Writer, pkgs []Package, format string) error {
+	switch format {
+	case "text":
+		writeCandidatesText(w, pkgs)
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(candidateReport(pkgs))
+	case "markdown":
+		writeCandidatesMarkdown(w, candidateReport(pkgs))
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (want text, json or markdown)", format)
+}
+
+func writeCandidatesText(w io.Writer, pkgs []Package) {
+	if len(pkgs) == 0 {
+		fmt.Fprintln(w, "No lawtest candidates found.")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "lawtest works with:")
+		fmt.Fprintln(w, "  • func(T, T) T                - Binary functions")
+		fmt.Fprintln(w, "  • func (T) Method(T) T        - Methods on types")
+		fmt.Fprintln(w, "  • func ([]T) Method([]T) []T  - Methods on slices")
+		fmt.Fprintln(w, "  • func(...T) T                - Variadic folds")
+		fmt.Fprintln(w, "  • func (T) Apply(...Op) T     - Applying updates to a state")
+		fmt.Fprintln(w, "  • func (T) Set(k, v) T        - State transitions")
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "═══════════════════════════════════════════════════════════")
+	fmt.Fprintln(w, "  lawtest Candidates Found")
+	fmt.Fprintln(w, "═══════════════════════════════════════════════════════════")
+	fmt.Fprintln(w)
+
+	i := 0
+	for _, pkg := range pkgs {
+		for _, c := range pkg.Candidates {
+			i++
+			fmt.Fprintf(w, "%d. %s.%s\n", i, pkg.Name, displayName(c))
+			fmt.Fprintf(w, "   Type: %s\n", c.TypeName)
+			if len(c.TypeArgs) > 0 {
+				fmt.Fprintf(w, "   Generic, tested as: %s\n", instanceName(c))
+			}
+			if c.TakesContext {
+				fmt.Fprintf(w, "   Takes a context.Context: tests pass context.Background()\n")
+			}
+			if c.ReturnsError {
+				fmt.Fprintf(w, "   Returns an error: laws are checked where it succeeds\n")
+			}
+			if c.Fold != nil && c.Fold.Action {
+				fmt.Fprintf(w, "   Fold: applies ...%s to a %s\n", types.TypeString(c.Fold.Elem, types.RelativeTo(pkg.Types)), c.TypeName)
+			} else if c.Fold != nil {
+				fmt.Fprintf(w, "   Fold: combines ...%s\n", c.TypeName)
+			}
+			if c.Transition != nil {
+				var args []string
+				for _, v := range c.Transition.Args {
+					args = append(args, types.TypeString(v.Type(), types.RelativeTo(pkg.Types)))
+				}
+				if len(args) == 0 {
+					fmt.Fprintf(w, "   Transition: maps a %s to an updated one\n", c.TypeName)
+				} else {
+					fmt.Fprintf(w, "   Transition: updates a %s with (%s)\n", c.TypeName, strings.Join(args, ", "))
+				}
+			}
+			fmt.Fprintf(w, "   At:   %s:%d\n", c.Pos.Filename, c.Pos.Line)
+			if c.NeedsWrapper {
+				fmt.Fprintf(w, "   ⚠️  Type is NOT comparable - needs wrapper (see example)\n")
+			} else if c.IsComparable {
+				fmt.Fprintf(w, "   ✅ Type is comparable\n")
+			} else {
+				fmt.Fprintf(w, "   ❓ Comparability unknown - may need wrapper\n")
+			}
+			if c.ComparabilityVaries {
+				fmt.Fprintf(w, "   ℹ️  Comparability depends on the type arguments; checked for %s\n", c.TypeName)
+			}
+			fmt.Fprintln(w, "   Proposed laws:")
+			for _, law := range c.Laws {
+				fmt.Fprintf(w, "     • %s - %s\n", law.Name, law.Reason)
+			}
+			if len(c.Suggested) > 0 {
+				fmt.Fprintln(w, "   Suggested, not tested:")
+				for _, law := range c.Suggested {
+					fmt.Fprintf(w, "     • %s - %s\n", law.Name, law.Reason)
+				}
+			}
+			fmt.Fprintln(w)
+		}
+		for _, c := range pkg.Contracts {
+			i++
+			fmt.Fprintf(w, "%d. %s.%s (interface)\n", i, pkg.Name, c.Name)
+			fmt.Fprintf(w, "   At:   %s:%d\n", c.Pos.Filename, c.Pos.Line)
+			var observers []string
+			for _, m := range c.Observers {
+				observers = append(observers, m.Name())
+			}
+			fmt.Fprintf(w, "   Compared through: %s\n", strings.Join(observers, ", "))
+			fmt.Fprintln(w, "   Proposed laws:")
+			for _, m := range append(c.Binary, c.Transitions...) {
+				for _, law := range m.Laws {
+					fmt.Fprintf(w, "     • %s %s - %s\n", m.Method.Name(), law.Name, law.Reason)
+				}
+			}
+			if c.Parallel {
+				fmt.Fprintln(w, "     • parallel-safe - the documentation says it is safe for concurrent use")
+			}
+			for _, impl := range c.Impls {
+				fmt.Fprintf(w, "   Implemented by %s, built with %s\n", impl.TypeName, impl.Factory.Name())
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// writeCandidatesMarkdown writes a summary table followed by the reasons
+// for each proposed law, for posting as a review comment.
+func writeCandidatesMarkdown(w io.Writer, report CandidateReport) {
+	fmt.Fprintln(w, "## lawtest candidates")
+	fmt.Fprintln(w)
+	if len(report.Candidates) == 0 {
+		fmt.Fprintln(w, "No lawtest candidates found.")
+		return
+	}
+
+	fmt.Fprintln(w, "| Function | Type | Comparable | Location | Laws |")
+	fmt.Fprintln(w, "|---|---|---|---|---|")
+	for _, r := range report.Candidates {
+		comparable := "unknown"
+		switch {
+		case r.NeedsWrapper:
+			comparable = "no, needs wrapper"
+		case r.Comparable:
+			comparable = "yes"
+		}
+		var laws []string
+		for _, law := range r.Laws {
+			laws = append(laws, law.Name)
+		}
+		fmt.Fprintf(w, "| `%s` | `%s` | %s | %s:%d | %s |\n",
+			mdCell(reportedName(r)), mdCell(r.Type), comparable, mdCell(r.File), r.Line, mdCell(strings.Join(laws, ", ")))
+	}
+
+	for _, r := range report.Candidates {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "### `%s`\n", reportedName(r))
+		fmt.Fprintln(w)
+		for _, law := range r.Laws {
+			fmt.Fprintf(w, "- **%s**: %s\n", law.Name, law.Reason)
+		}
+		for _, law := range r.Suggested {
+			fmt.Fprintf(w, "- %s (suggested, not tested): %s\n", law.Name, law.Reason)
+		}
+	}
+}
+
+// reportedName qualifies the function by its package name and receiver.
+func reportedName(r ReportedCandidate) string {
+	if r.Receiver == "" {
+		return r.pkgName + "." + r.Function
+	}
+	return r.pkgName + ".(" + r.Receiver + ")." + r.Function
+}
+
+// mdCell escapes the characters that would end a Markdown table cell.
+func mdCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
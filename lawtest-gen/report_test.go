@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteCandidatesJSON(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/fold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCandidates(&buf, pkgs, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var report CandidateReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Candidates) != 4 {
+		t.Fatalf("Expected 4 candidates, got %s", buf.String())
+	}
+	merge := report.Candidates[0]
+	if merge.Function != "Merge" || merge.Type != "Config" || !merge.NeedsWrapper || merge.File != "testdata/fold/fold.go" || merge.Line != 7 {
+		t.Errorf("Unexpected Merge entry: %+v", merge)
+	}
+	if len(merge.Laws) == 0 || merge.Laws[0].Name != lawImmutable || merge.Laws[0].Reason == "" {
+		t.Errorf("Expected Merge laws with reasons, got %+v", merge.Laws)
+	}
+	if apply := report.Candidates[3]; apply.Receiver != "Counter" || apply.Fold != "action" {
+		t.Errorf("Expected Apply as an action on Counter, got %+v", apply)
+	}
+}
+
+func TestWriteCandidates(t *testing.T) {
+	tests := []struct {
+		pattern, format string
+		want            []string
+	}{
+		{"./testdata/fold", "markdown", []string{
+			"| `fold.Merge` | `Config` | no, needs wrapper | testdata/fold/fold.go:7 |",
+			"### `fold.(Counter).Apply`",
+			"- **pairwise**: MergeAll folds Config values like repeated Merge",
+		}},
+		// Names are qualified by the package name, not the directory
+		{"./examples", "markdown", []string{
+			"### `example.Add`",
+			"- idempotent (suggested, not tested): the name Merge suggests it",
+		}},
+		{"./examples", "text", []string{
+			"4. example.(State).Merge",
+			"   Suggested, not tested:\n     • idempotent - the name Merge suggests it",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.format, func(t *testing.T) {
+			pkgs, err := loadCandidates([]string{tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := writeCandidates(&buf, pkgs, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Expected %q in:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+
+	if err := writeCandidates(io.Discard, nil, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
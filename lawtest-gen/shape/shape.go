@@ -0,0 +1,169 @@
+// Package shape recognizes the signatures lawtest-gen treats as law
+// candidates: binary operations, variadic folds and actions, optionally
+// taking a context.Context first and returning an error last, and state
+// transitions.
+//
+// It is shared by the test generator and the inputmutation analyzer, so
+// both agree on what a candidate is.
+package shape
+
+import "go/types"
+
+// Kind is the form of a candidate operation.
+type Kind int
+
+const (
+	// Binary is func(T, T) T or func (T) M(T) T.
+	Binary Kind = iota
+	// Fold is func(...T) T, func(T, ...T) T or func (T) M(...T) T.
+	Fold
+	// Action is func (T) M(...E) T: updates of type E applied to a state T.
+	Action
+	// Transition is func (T) M(args) T or func F(T, args) T, where no
+	// argument is a T: a state updated in one step, such as
+	// (State).Set(key, value string) or Normalize(c Config) Config.
+	Transition
+)
+
+// Operation describes a function matching one of the candidate forms.
+type Operation struct {
+	Kind Kind
+	// Type is the operand type T.
+	Type types.Type
+	// Elem is the type of the variadic arguments of folds and actions.
+	Elem types.Type
+	// Inputs are the receiver and parameters holding operands or updates;
+	// a leading context.Context is not an input. For transitions the
+	// first is the state and the others are the arguments.
+	Inputs []*types.Var
+
+	TakesContext bool
+	ReturnsError bool
+}
+
+// Match returns the Operation sig describes, or nil if it is not a
+// candidate. Types are compared with the type checker, so aliases, grouped
+// parameters and a named type paired with its underlying type are handled.
+// Generic signatures match as written, with their type parameters.
+func Match(sig *types.Signature) *Operation {
+	op := &Operation{}
+	var result types.Type
+	switch {
+	case sig.Results().Len() == 1:
+		result = sig.Results().At(0).Type()
+	case sig.Results().Len() == 2 && isError(sig.Results().At(1).Type()):
+		result = sig.Results().At(0).Type()
+		op.ReturnsError = true
+	default:
+		return nil
+	}
+
+	var params []types.Type
+	if sig.Recv() != nil {
+		op.Inputs = append(op.Inputs, sig.Recv())
+	}
+	for i := range sig.Params().Len() {
+		p := sig.Params().At(i)
+		if i == 0 && isContext(p.Type()) {
+			op.TakesContext = true
+			continue
+		}
+		params = append(params, p.Type())
+		op.Inputs = append(op.Inputs, p)
+	}
+
+	if sig.Variadic() {
+		// Folds with a context or an error are not supported
+		if op.TakesContext || op.ReturnsError {
+			return nil
+		}
+		return matchFold(op, sig, params, result)
+	}
+
+	switch {
+	case sig.Recv() == nil && len(params) == 2 && sameOperand(params[0], result) && sameOperand(params[1], result):
+		op.Type = result
+	case sig.Recv() != nil && len(params) == 1 &&
+		types.Identical(sig.Recv().Type(), params[0]) && types.Identical(sig.Recv().Type(), result):
+		op.Type = sig.Recv().Type()
+	default:
+		return matchTransition(op, sig, params, result)
+	}
+	op.Kind = Binary
+	return op
+}
+
+// matchTransition matches methods returning their receiver type and
+// functions returning the type of their first parameter, when no other
+// argument has that type. Functions only match on named types, so that
+// func(string) string and the like are left alone. Transitions with a
+// context or an error are not supported.
+func matchTransition(op *Operation, sig *types.Signature, params []types.Type, result types.Type) *Operation {
+	if op.TakesContext || op.ReturnsError {
+		return nil
+	}
+	state := params
+	if sig.Recv() != nil {
+		state = []types.Type{sig.Recv().Type()}
+	} else if len(params) == 0 || !isNamed(result) {
+		return nil
+	} else {
+		params = params[1:]
+	}
+	if !types.Identical(state[0], result) {
+		return nil
+	}
+	for _, p := range params {
+		if sameOperand(p, result) {
+			return nil
+		}
+	}
+	op.Type, op.Kind = result, Transition
+	return op
+}
+
+func matchFold(op *Operation, sig *types.Signature, params []types.Type, result types.Type) *Operation {
+	elem := params[len(params)-1].(*types.Slice).Elem()
+	op.Type, op.Elem, op.Kind = result, elem, Fold
+
+	switch {
+	case sig.Recv() == nil && len(params) == 1 && sameOperand(elem, result):
+	case sig.Recv() == nil && len(params) == 2 && sameOperand(params[0], result) && sameOperand(elem, result):
+	case sig.Recv() != nil && len(params) == 1 && types.Identical(sig.Recv().Type(), result):
+		if !types.Identical(elem, result) {
+			op.Kind = Action
+		}
+	default:
+		return nil
+	}
+	return op
+}
+
+// sameOperand reports whether a value of type a can be passed where b is
+// expected and vice versa. This accepts identical types as well as a named
+// type paired with its unnamed underlying type, such as Config and
+// map[string]any.
+func sameOperand(a, b types.Type) bool {
+	if types.Identical(a, b) {
+		return true
+	}
+	return types.AssignableTo(a, b) && types.AssignableTo(b, a)
+}
+
+// isNamed reports whether t is a named type or a pointer to one.
+func isNamed(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	_, ok := t.(*types.Named)
+	return ok
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
@@ -0,0 +1,50 @@
+package annotated
+
+//go:generate lawtest-gen -q
+
+// Set is a set of strings.
+type Set map[string]struct{}
+
+// Empty returns the empty set.
+func Empty() Set { return Set{} }
+
+// Union returns the elements in a or b.
+//
+//lawtest:laws associative,commutative,idempotent identity=Empty
+func Union(a, b Set) Set {
+	out := make(Set, len(a)+len(b))
+	for k := range a {
+		out[k] = struct{}{}
+	}
+	for k := range b {
+		out[k] = struct{}{}
+	}
+	return out
+}
+
+// Intersect returns the elements in both a and b.
+//
+//lawtest:laws associative commutative absorption=Union
+func Intersect(a, b Set) Set {
+	out := make(Set)
+	for k := range a {
+		if _, ok := b[k]; ok {
+			out[k] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Sub is not meant to obey any law.
+//
+//lawtest:ignore
+func Sub(a, b int) int {
+	return a - b
+}
+
+// Concat joins two strings.
+//
+//lawtest:laws associative identity=""
+func Concat(a, b string) string {
+	return a + b
+}
@@ -0,0 +1,86 @@
+// Package contract declares an interface with documented laws and an
+// implementation of it.
+package contract
+
+import (
+	"iter"
+	"maps"
+)
+
+// Store is a persistent map from keys to counters. Stores are immutable and
+// safe for concurrent use.
+type Store interface {
+	// Get returns the counter of key.
+	Get(key string) (int, bool)
+	// Put returns a store with key set to n.
+	Put(key string, n int) Store
+	// Delete returns a store without key.
+	Delete(key string) Store
+	// Join keeps the highest counter of each key. It is associative,
+	// commutative and idempotent, with the empty store as identity.
+	Join(other Store) Store
+	// Len returns the number of keys.
+	Len() int
+	// All returns the keys and their counters, in no particular order.
+	All() iter.Seq2[string, int]
+	// Keys returns the keys, in no particular order.
+	Keys() <-chan string
+}
+
+// MapStore implements Store with a map copied on every update.
+type MapStore struct {
+	m map[string]int
+}
+
+// NewMapStore returns a store holding a copy of m.
+func NewMapStore(m map[string]int) *MapStore {
+	s := &MapStore{m: make(map[string]int, len(m))}
+	for k, v := range m {
+		s.m[k] = v
+	}
+	return s
+}
+
+func (s *MapStore) Get(key string) (int, bool) {
+	n, ok := s.m[key]
+	return n, ok
+}
+
+func (s *MapStore) Put(key string, n int) Store {
+	t := NewMapStore(s.m)
+	t.m[key] = n
+	return t
+}
+
+func (s *MapStore) Delete(key string) Store {
+	t := NewMapStore(s.m)
+	delete(t.m, key)
+	return t
+}
+
+func (s *MapStore) Join(other Store) Store {
+	t := NewMapStore(s.m)
+	for k, v := range other.(*MapStore).m {
+		if n, ok := t.m[k]; !ok || v > n {
+			t.m[k] = v
+		}
+	}
+	return t
+}
+
+func (s *MapStore) Len() int {
+	return len(s.m)
+}
+
+func (s *MapStore) All() iter.Seq2[string, int] {
+	return maps.All(s.m)
+}
+
+func (s *MapStore) Keys() <-chan string {
+	ch := make(chan string, len(s.m))
+	for k := range s.m {
+		ch <- k
+	}
+	close(ch)
+	return ch
+}
@@ -0,0 +1,31 @@
+package coverage
+
+// Set is a set of strings
+type Set map[string]bool
+
+// Add adds two integers
+func Add(a, b int) int {
+	return a + b
+}
+
+// Sub subtracts b from a
+func Sub(a, b int) int {
+	return a - b
+}
+
+// Union returns the elements of both sets
+func Union(a, b Set) Set {
+	result := make(Set, len(a)+len(b))
+	for k := range a {
+		result[k] = true
+	}
+	for k := range b {
+		result[k] = true
+	}
+	return result
+}
+
+// Max returns the larger of two integers
+func Max(a, b int) int {
+	return max(a, b)
+}
@@ -0,0 +1,39 @@
+package coverage
+
+import (
+	"testing"
+
+	"github.com/alexshd/lawtest"
+)
+
+type SetWrapper struct {
+	set Set
+}
+
+func WrapUnion(a, b *SetWrapper) *SetWrapper {
+	return &SetWrapper{set: Union(a.set, b.set)}
+}
+
+func genSet() *SetWrapper {
+	return &SetWrapper{set: Set{lawtest.StringGen(3)(): true}}
+}
+
+// genNatural uses Max to build values, which doesn't cover it
+func genNatural() int {
+	return Max(lawtest.IntGen(-100, 100)(), 0)
+}
+
+func TestAddAssociativity(t *testing.T) {
+	lawtest.Associative(t, Add, genNatural)
+}
+
+func TestUnionImmutability(t *testing.T) {
+	lawtest.ImmutableOp(t, WrapUnion, genSet)
+}
+
+// TestSubExample doesn't use lawtest, so it doesn't cover Sub
+func TestSubExample(t *testing.T) {
+	if Sub(3, 1) != 2 {
+		t.Error("Expected 2")
+	}
+}
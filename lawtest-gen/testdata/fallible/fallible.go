@@ -0,0 +1,46 @@
+package fallible
+
+import (
+	"context"
+	"errors"
+)
+
+// Limits caps resource usage; zero means unlimited
+type Limits struct {
+	CPU    int
+	Memory int
+}
+
+// ErrConflict is returned when two limits can't be combined
+var ErrConflict = errors.New("conflicting limits")
+
+// Tighten combines two limits, keeping the lower of each
+func Tighten(a, b Limits) (Limits, error) {
+	if a.CPU < 0 || b.CPU < 0 {
+		return Limits{}, ErrConflict
+	}
+	return Limits{CPU: min(a.CPU, b.CPU), Memory: min(a.Memory, b.Memory)}, nil
+}
+
+// Tags is a set of labels
+type Tags map[string]struct{}
+
+// Union merges two tag sets, checking ctx for cancellation
+func Union(ctx context.Context, a, b Tags) (Tags, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := make(Tags, len(a)+len(b))
+	for k := range a {
+		result[k] = struct{}{}
+	}
+	for k := range b {
+		result[k] = struct{}{}
+	}
+	return result, nil
+}
+
+// Add sums two counters; ctx is only used for tracing
+func Add(ctx context.Context, a, b int) int {
+	return a + b
+}
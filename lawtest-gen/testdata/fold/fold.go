@@ -0,0 +1,54 @@
+package fold
+
+// Config is a set of settings
+type Config map[string]string
+
+// Merge returns a with the settings of b on top
+func Merge(a, b Config) Config {
+	result := make(Config, len(a)+len(b))
+	for k, v := range a {
+		result[k] = v
+	}
+	for k, v := range b {
+		result[k] = v
+	}
+	return result
+}
+
+// MergeAll merges configs from left to right
+func MergeAll(cs ...Config) Config {
+	result := Config{}
+	for _, c := range cs {
+		result = Merge(result, c)
+	}
+	return result
+}
+
+// Sum adds first and every number in rest
+func Sum(first int, rest ...int) int {
+	for _, n := range rest {
+		first += n
+	}
+	return first
+}
+
+// Counter counts events
+type Counter struct {
+	Total int
+}
+
+// Op is a change to a Counter
+type Op int
+
+// Apply applies ops in order
+func (c Counter) Apply(ops ...Op) Counter {
+	for _, op := range ops {
+		c.Total += int(op)
+	}
+	return c
+}
+
+// Join formats parts, not a fold: the result is not a part
+func Join(sep string, parts ...int) string {
+	return sep
+}
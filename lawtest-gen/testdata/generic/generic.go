@@ -0,0 +1,60 @@
+package generic
+
+// Number is satisfied by integers and floats
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+// Sum adds two numbers
+func Sum[T Number](a, b T) T {
+	return a + b
+}
+
+// MergeMaps returns the union of a and b; b takes precedence
+func MergeMaps[K comparable, V any](a, b map[K]V) map[K]V {
+	result := make(map[K]V, len(a)+len(b))
+	for k, v := range a {
+		result[k] = v
+	}
+	for k, v := range b {
+		result[k] = v
+	}
+	return result
+}
+
+// Concat appends b to a
+func Concat[S ~[]E, E any](a, b S) S {
+	result := make(S, 0, len(a)+len(b))
+	result = append(result, a...)
+	return append(result, b...)
+}
+
+// Set is a set of comparable values
+type Set[T comparable] map[T]struct{}
+
+// NewSet returns an empty set
+func NewSet[T comparable]() Set[T] {
+	return Set[T]{}
+}
+
+// Union returns the elements of both sets
+func (s Set[T]) Union(o Set[T]) Set[T] {
+	result := NewSet[T]()
+	for k := range s {
+		result[k] = struct{}{}
+	}
+	for k := range o {
+		result[k] = struct{}{}
+	}
+	return result
+}
+
+// Pair holds two values; it is comparable only when T is
+type Pair[T any] struct {
+	First, Second T
+}
+
+// Zip pairs the first element of p with the second of o
+func (p Pair[T]) Zip(o Pair[T]) Pair[T] {
+	return Pair[T]{First: p.First, Second: o.Second}
+}
@@ -0,0 +1,37 @@
+package lattice
+
+// Max returns the larger of a and b
+func Max(a, b int) int {
+	return max(a, b)
+}
+
+// Min returns the smaller of a and b
+func Min(a, b int) int {
+	return min(a, b)
+}
+
+// Sub subtracts b from a
+func Sub(a, b int) int {
+	return a - b
+}
+
+// Scores maps players to scores
+type Scores map[string]int
+
+// NewScores creates Scores from the given entries
+func NewScores(entries map[string]int) Scores {
+	s := make(Scores, len(entries))
+	for k, v := range entries {
+		s[k] = v
+	}
+	return s
+}
+
+// Merge combines two Scores, with the other's values taking precedence
+func (s Scores) Merge(other Scores) Scores {
+	result := NewScores(s)
+	for k, v := range other {
+		result[k] = v
+	}
+	return result
+}
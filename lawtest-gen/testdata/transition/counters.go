@@ -0,0 +1,51 @@
+// Package transition has state transitions next to the merge they should
+// commute with.
+package transition
+
+import "maps"
+
+// Counters maps names to counts.
+type Counters map[string]int
+
+// Merge combines two sets of counters, the other's counts taking precedence.
+func (c Counters) Merge(other Counters) Counters {
+	out := maps.Clone(c)
+	if out == nil {
+		out = make(Counters)
+	}
+	maps.Copy(out, other)
+	return out
+}
+
+// Set returns a copy of c with name counting n.
+//
+//lawtest:laws deterministic,commutation=Merge
+func (c Counters) Set(name string, n int) Counters {
+	out := maps.Clone(c)
+	if out == nil {
+		out = make(Counters)
+	}
+	out[name] = n
+	return out
+}
+
+// Delete returns a copy of c without name. It doesn't commute with Merge:
+// deleting from the other counters before merging leaves c's count.
+//
+//lawtest:laws deterministic
+func (c Counters) Delete(name string) Counters {
+	out := maps.Clone(c)
+	delete(out, name)
+	return out
+}
+
+// Normalize returns a copy of c without zero counts.
+func Normalize(c Counters) Counters {
+	out := make(Counters)
+	for k, v := range c {
+		if v != 0 {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package unexported
+
+import "slices"
+
+// Tree is a sorted set of ints.
+type Tree struct {
+	root *node
+}
+
+// Union returns the ints of both trees.
+func (t Tree) Union(other Tree) Tree {
+	return Tree{root: t.root.union(other.root)}
+}
+
+// Equal reports whether both trees hold the same ints, whatever their
+// shape.
+func (t Tree) Equal(other Tree) bool {
+	return slices.Equal(t.root.values(nil), other.root.values(nil))
+}
+
+// node must stay sorted, which random nodes are not.
+type node struct {
+	value       int
+	left, right *node
+}
+
+// newNode returns a tree holding v alone.
+func newNode(v int) *node {
+	return &node{value: v}
+}
+
+func (n *node) union(other *node) *node {
+	if other == nil {
+		return n
+	}
+	return n.insert(other.value).union(other.left).union(other.right)
+}
+
+func (n *node) insert(v int) *node {
+	switch {
+	case n == nil:
+		return newNode(v)
+	case v < n.value:
+		return &node{n.value, n.left.insert(v), n.right}
+	case v > n.value:
+		return &node{n.value, n.left, n.right.insert(v)}
+	}
+	return n
+}
+
+// values appends the ints of n to dst in order.
+func (n *node) values(dst []int) []int {
+	if n == nil {
+		return dst
+	}
+	return n.right.values(append(n.left.values(dst), n.value))
+}
+
+// Merge is exported, but node is not.
+func (n *node) Merge(other *node) *node {
+	return n.union(other)
+}
+
+func maxInt(a, b int) int {
+	return max(a, b)
+}
+
+//lawtest:laws associative,commutative,idempotent
+func minInt(a, b int) int {
+	return min(a, b)
+}
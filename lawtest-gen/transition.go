@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// Transition describes a method or function updating a state in one step:
+// func (T) M(args) T or func F(T, args) T where no argument is a T, such as
+// (SudokuState).PlaceNumber(row, col, num int) SudokuState or
+// (*State).Set(key, value string) *State.
+type Transition struct {
+	// Args are the parameters besides the state; none for unary
+	// operations such as Normalize(c Config) Config.
+	Args []*types.Var
+}
+
+// transitionReserved are the names the generated tests use, which argument
+// variables must not shadow.
+var transitionReserved = map[string]bool{
+	"t": true, "s": true, "a": true, "b": true, "got": true, "want": true,
+	"before": true, "after": true, "left": true, "right": true, "fmt": true, "lawtest": true, "reflect": true, "testing": true,
+}
+
+// inferTransitionLaws proposes the laws every transition should obey: it
+// must return the updated state rather than modify it, and depend only on
+// the state and its arguments.
+func inferTransitionLaws(c *Candidate) {
+	c.addLaw(lawImmutable, c.FuncName+" returns the updated "+c.TypeName+", so it must leave the one it updates untouched (Law I)")
+	c.addLaw(lawDeterministic, "the result of "+c.FuncName+" should depend only on the state and arguments it is given")
+}
+
+// precedence returns which operand of a binary operation wins conflicts
+// according to its comments: "right" when the argument does, as in
+// "the other state's values take precedence", "left" when the receiver
+// does, and "" when the comments don't tell.
+func precedence(comments string) string {
+	switch {
+	case !mentions(comments, precedenceWords...):
+		return ""
+	case mentions(comments, "other", "second", "argument", "right", "last"):
+		return "right"
+	case mentions(comments, "receiver", "first", "left", "existing"):
+		return "left"
+	}
+	return ""
+}
+
+// inferCommutation proposes that transitions taking arguments commute with
+// the merge on their type (the binary operation named like Merge, or the
+// only one there is) when the merge says which side wins conflicts, or is
+// commutative. Updating the winning side before merging should then equal
+// updating the merged result: a.Merge(b.Set(k, v)) = a.Merge(b).Set(k, v)
+// when the argument wins, a.Set(k, v).Merge(b) = a.Merge(b).Set(k, v)
+// otherwise. Transitions declaring commutation=Merge get the side from the
+// merge in the same way, or the receiver when it doesn't tell.
+func inferCommutation(candidates []Candidate) {
+	for i := range candidates {
+		c := &candidates[i]
+		if c.Transition == nil || len(c.Transition.Args) == 0 {
+			continue
+		}
+		if c.Annotated {
+			for j := range c.Laws {
+				if law := &c.Laws[j]; law.Name == lawCommutation {
+					if merge := binaryOn(candidates, c.Type, law.Merge); merge != nil {
+						law.MergeRight = mergeSide(*merge) == "right"
+					}
+				}
+			}
+			continue
+		}
+		merge := binaryOn(candidates, c.Type, "")
+		if merge == nil {
+			continue
+		}
+		law := Law{Name: lawCommutation, Merge: merge.FuncName}
+		switch mergeSide(*merge) {
+		case "right":
+			law.MergeRight = true
+			law.Reason = "comments give precedence to the argument of " + merge.FuncName + ", so " + c.FuncName + " on it before merging should equal " + c.FuncName + " after"
+		case "left":
+			law.Reason = "comments give precedence to the receiver of " + merge.FuncName + ", so " + c.FuncName + " on it before merging should equal " + c.FuncName + " after"
+		case "commutative":
+			law.Reason = merge.FuncName + " is proposed to be commutative, so like a union it should keep what " + c.FuncName + " does whether it runs before or after"
+		default:
+			continue
+		}
+		c.Laws = append(c.Laws, law)
+	}
+}
+
+// mergeSide returns "commutative" for merges proposed to be commutative,
+// otherwise which side wins conflicts (see precedence).
+func mergeSide(merge Candidate) string {
+	if merge.Law(lawCommutative) != nil {
+		return "commutative"
+	}
+	return merge.precedence
+}
+
+// binaryOn returns the binary operation on t named name or, when name is
+// empty, the one named like Merge or the only one there is.
+func binaryOn(candidates []Candidate, t types.Type, name string) *Candidate {
+	var found []*Candidate
+	for i := range candidates {
+		d := &candidates[i]
+		if !d.binary() || d.TakesContext || d.ReturnsError || !types.Identical(d.Type, t) {
+			continue
+		}
+		if name != "" && d.FuncName == name || name == "" && strings.Contains(strings.ToLower(d.FuncName), "merge") {
+			return d
+		}
+		found = append(found, d)
+	}
+	if name == "" && len(found) == 1 {
+		return found[0]
+	}
+	return nil
+}
+
+// generateTransitionTests writes the test of law for the transition c,
+// working on unwrapped values like the tests of folds. Receivers are
+// compared before and after the call through their %#v formatting, which
+// shows the contents of maps and slices they share with the result.
+func (g *generator) generateTransitionTests(c Candidate, op operand, law Law) {
+	switch law.Name {
+	case lawImmutable:
+		g.imports["fmt"] = "fmt"
+		g.openTest(c, "Immutability")
+		g.loop("s := %s", g.rawGen(op))
+		args := g.transitionArgs(c)
+		call := transitionCall(c, "s", args)
+		g.sb.WriteString("before := fmt.Sprintf(\"%#v\", s)\n")
+		fmt.Fprintf(g.sb, "_ = %s\n", call)
+		g.sb.WriteString("if after := fmt.Sprintf(\"%#v\", s); after != before {\n")
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Immutability failed: %s modified s\\n  before: %%s\\n  after:  %%s\", before, after)\n}\n", call)
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawDeterministic:
+		g.lawHeader(c, law, "Determinism", "be deterministic")
+		g.loop("s := %s", g.rawGen(op))
+		call := transitionCall(c, "s", g.transitionArgs(c))
+		fmt.Fprintf(g.sb, "if got, want := %s, %s; %s {\n", call, call, g.rawDiffer(op, "got", "want"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Determinism failed: %s gave different results\\n  s=%%v\\n  first=%%v, second=%%v\", s, want, got)\n}\n", call)
+		g.sb.WriteString("}\n}\n\n")
+
+	case lawCommutation:
+		merge := binaryOn(g.candidates, c.Type, law.Merge)
+		if merge == nil {
+			return
+		}
+		g.lawHeader(c, law, upperFirst(law.Merge)+"Commutation", "commute with "+law.Merge)
+		g.loop("a, b := %s, %s", g.rawGen(op), g.rawGen(op))
+		args := g.transitionArgs(c)
+		after := transitionCall(c, binaryCall(*merge, "a", "b"), args)
+		before := binaryCall(*merge, transitionCall(c, "a", args), "b")
+		if law.MergeRight {
+			before = binaryCall(*merge, "a", transitionCall(c, "b", args))
+		}
+		fmt.Fprintf(g.sb, "if left, right := %s, %s; %s {\n", before, after, g.rawDiffer(op, "left", "right"))
+		fmt.Fprintf(g.sb, "t.Fatalf(\"Commutation failed: %s != %s\\n  a=%%v, b=%%v\\n  left=%%v, right=%%v\", a, b, left, right)\n}\n", before, after)
+		g.sb.WriteString("}\n}\n\n")
+	}
+}
+
+// transitionArgs declares random arguments for the transition c and
+// returns their names. The first is drawn like a map key, from a small
+// domain, so that updates often hit the same key or cell as the state.
+func (g *generator) transitionArgs(c Candidate) []string {
+	var names, exprs []string
+	for i, v := range c.Transition.Args {
+		name := v.Name()
+		if name == "" || name == "_" || transitionReserved[name] {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		names = append(names, name)
+		if i == 0 {
+			exprs = append(exprs, g.keyExpr(v.Type(), 0))
+		} else {
+			exprs = append(exprs, g.genExpr(v.Type(), 0))
+		}
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(g.sb, "%s := %s\n", strings.Join(names, ", "), strings.Join(exprs, ", "))
+	}
+	return names
+}
+
+// transitionCall applies the transition c to the state s.
+func transitionCall(c Candidate, s string, args []string) string {
+	return foldCall(c, append([]string{s}, args...)...)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadTransitions(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"../faulttest/state.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set *Candidate
+	for i, c := range pkgs[0].Candidates {
+		if displayName(c) == "(*State).Set" {
+			set = &pkgs[0].Candidates[i]
+		}
+	}
+	if set == nil || set.Transition == nil {
+		t.Fatalf("(*State).Set not detected as a transition: %+v", pkgs[0].Candidates)
+	}
+	if len(set.Transition.Args) != 2 {
+		t.Errorf("Set has %d arguments besides the state, want 2", len(set.Transition.Args))
+	}
+	for _, name := range []string{lawImmutable, lawDeterministic, lawCommutation} {
+		if set.Law(name) == nil {
+			t.Errorf("Set: missing law %s", name)
+		}
+	}
+	// Merge gives precedence to its argument
+	if law := set.Law(lawCommutation); law != nil && (law.Merge != "Merge" || !law.MergeRight) {
+		t.Errorf("Set commutes with %s (right %v), want the argument of Merge", law.Merge, law.MergeRight)
+	}
+}
+
+func TestLoadAnnotatedTransitions(t *testing.T) {
+	pkgs, err := loadCandidates([]string{"./testdata/transition"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	laws := make(map[string]string)
+	for _, c := range pkgs[0].Candidates {
+		var names []string
+		for _, law := range c.Laws {
+			names = append(names, law.Name)
+		}
+		laws[displayName(c)] = strings.Join(names, ",")
+	}
+	for name, want := range map[string]string{
+		"(Counters).Set":    "immutable,deterministic,commutation",
+		"(Counters).Delete": "immutable,deterministic",
+		"Normalize":         "immutable,deterministic",
+	} {
+		if laws[name] != want {
+			t.Errorf("%s laws = %q, want %q", name, laws[name], want)
+		}
+	}
+	if law := pkgs[0].Candidates[1].Law(lawCommutation); law == nil || !law.MergeRight {
+		t.Errorf("declared commutation of Set should apply to the argument of Merge, got %+v", law)
+	}
+}
@@ -4,9 +4,9 @@ go 1.25.4
 
 replace github.com/alexshd/lawtest => ../../lawtest
 
-require github.com/alexshd/lawtest v0.0.0-00010101000000-000000000000
-
 require (
-	github.com/a-h/templ v0.3.960 // indirect
-	maragu.dev/gomponents v1.2.0 // indirect
+	github.com/alexshd/lawtest v0.0.0-00010101000000-000000000000
+	maragu.dev/gomponents v1.2.0
 )
+
+require github.com/a-h/templ v0.3.960 // indirect